@@ -9,16 +9,17 @@ import (
 )
 
 type PublishedAnnouncementResp struct {
-	Id         int64   `json:"newsid"`
-	Title      *string `json:"title"`
-	Content    *string `json:"content"`
-	Obj        *string `json:"obj"`
-	UpdateTime *string `json:"updateTime"`
+	Id         int64              `json:"newsid"`
+	Title      *string            `json:"title"`
+	Content    *string            `json:"content"`
+	Obj        *string            `json:"obj"`
+	UpdateTime *string            `json:"updateTime"`
+	Experiment *ExperimentTagResp `json:"experiment,omitempty"` // 客户端分到的实验变体，不在实验中时没有这个字段
 }
 
 func getPublishedAnnouncement(c *gin.Context) {
 	platform := c.GetHeader("Platform")
-	announcements, err := srv.GetPublishedAnnouncement(platform)
+	announcements, assignments, err := srv.GetPublishedAnnouncement(platform, getAudienceClient(c))
 	if err != nil {
 		responseEcode(c, err)
 		return
@@ -33,6 +34,7 @@ func getPublishedAnnouncement(c *gin.Context) {
 			Content:    announcement.Content,
 			Obj:        announcement.Target,
 			UpdateTime: &updateTime,
+			Experiment: _getExperimentTag(assignments, announcement.Id),
 		}
 	}
 
@@ -40,10 +42,11 @@ func getPublishedAnnouncement(c *gin.Context) {
 }
 
 type AnnouncementAddReq struct {
-	Title    string    `json:"title" binging:"required"`
-	Content  string    `json:"content" binging:"required"`
-	Obj      string    `json:"obj" binging:"required"`
-	Platform *[]string `json:"platform"`
+	Title    string              `json:"title" binging:"required"`
+	Content  string              `json:"content" binging:"required"`
+	Obj      string              `json:"obj" binging:"required"`
+	Platform *[]string           `json:"platform"`
+	Audience *model.AudienceRule `json:"audience"` // 投放规则，为空时对所有人展示
 }
 
 func addAnnouncement(c *gin.Context) {
@@ -54,9 +57,10 @@ func addAnnouncement(c *gin.Context) {
 	}
 
 	announcement := service.AnnouncementAddParam{
-		Title:   &req.Title,
-		Content: &req.Content,
-		Target:  &req.Obj,
+		Title:    &req.Title,
+		Content:  &req.Content,
+		Target:   &req.Obj,
+		Audience: req.Audience,
 	}
 
 	// 平台参数为空，则默认全部平台
@@ -79,13 +83,14 @@ func addAnnouncement(c *gin.Context) {
 }
 
 type AnnouncementAdminResp struct {
-	Id         int64  `json:"newsid"`
-	Title      string `json:"title"`
-	Content    string `json:"content"`
-	Obj        string `json:"obj"`
-	Status     int8   `json:"status"`
-	Platform   string `json:"platform"`
-	UpdateTime string `json:"updateTime"`
+	Id         int64               `json:"newsid"`
+	Title      string              `json:"title"`
+	Content    string              `json:"content"`
+	Obj        string              `json:"obj"`
+	Audience   *model.AudienceRule `json:"audience"`
+	Status     int8                `json:"status"`
+	Platform   string              `json:"platform"`
+	UpdateTime string              `json:"updateTime"`
 }
 
 func getAnnouncement(c *gin.Context) {
@@ -113,6 +118,7 @@ func getAnnouncement(c *gin.Context) {
 			Title:      *announcement.Title,
 			Content:    *announcement.Content,
 			Obj:        *announcement.Target,
+			Audience:   announcement.Audience,
 			Status:     _internalAnnouncementStatus2ApiDefineStatus(*announcement.Status),
 			Platform:   *announcement.Platform,
 			UpdateTime: announcement.UpdateTime.Format(_defaultDateTimeFormat),
@@ -137,12 +143,13 @@ func _internalAnnouncementStatus2ApiDefineStatus(internalStatus int8) int8 {
 }
 
 type AnnouncementModifyReq struct {
-	Id       int64   `json:"newsid" binging:"required"`
-	Title    *string `json:"title"`
-	Content  *string `json:"content"`
-	Obj      *string `json:"obj"`
-	Platform *string `json:"platform"`
-	Status   *int8   `json:"status"`
+	Id       int64               `json:"newsid" binging:"required"`
+	Title    *string             `json:"title"`
+	Content  *string             `json:"content"`
+	Obj      *string             `json:"obj"`
+	Platform *string             `json:"platform"`
+	Audience *model.AudienceRule `json:"audience"` // 传空对象时取消投放规则
+	Status   *int8               `json:"status"`
 }
 
 func modifyAnnouncement(c *gin.Context) {
@@ -158,6 +165,7 @@ func modifyAnnouncement(c *gin.Context) {
 		Content:  req.Content,
 		Target:   req.Obj,
 		Platform: req.Platform,
+		Audience: req.Audience,
 		Status:   _announcementApiDefineStatus2InternalStatus(req.Status),
 	}
 
@@ -3,9 +3,11 @@ package http
 import (
 	"github.com/dustin/go-humanize"
 	"github.com/gin-gonic/gin"
+	jsoniter "github.com/json-iterator/go"
 	"go.uber.org/zap"
 	"io"
 	"mime/multipart"
+	"time"
 	"wusthelper-manager-go/app/model"
 	"wusthelper-manager-go/app/service"
 	"wusthelper-manager-go/common"
@@ -14,23 +16,82 @@ import (
 )
 
 type BannerInfoResp struct {
-	Actid      int64  `json:"actid"`
-	Title      string `json:"title"`
-	Content    string `json:"content"`
-	ImgUrl     string `json:"imgUrl"`
-	Status     int8   `json:"status"`
-	Platform   string `json:"platform"`
-	UpdateTime string `json:"updateTime"`
+	Actid      int64               `json:"actid"`
+	Title      string              `json:"title"`
+	Content    string              `json:"content"`
+	LinkType   string              `json:"linkType"`
+	ImgUrl     string              `json:"imgUrl"`
+	Status     int8                `json:"status"`
+	Platform   string              `json:"platform"`
+	Slot       string              `json:"slot"`
+	SortWeight int                 `json:"sortWeight"`
+	StartAt    string              `json:"startAt"` // 为空时不限制
+	EndAt      string              `json:"endAt"`
+	Audience   *model.AudienceRule `json:"audience"`
+	UpdateTime string              `json:"updateTime"`
+}
+
+// _formatBannerTime 没有设置的时间返回空字符串
+func _formatBannerTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+
+	return t.Format(_defaultDateTimeFormat)
+}
+
+// _parseBannerTime 空字符串返回nil
+func _parseBannerTime(s string) (*time.Time, error) {
+	if s == "" {
+		return nil, nil
+	}
+
+	t, err := time.ParseInLocation(_defaultDateTimeFormat, s, time.Local)
+	if err != nil {
+		return nil, err
+	}
+
+	return &t, nil
+}
+
+// _parseBannerModifyTime 没有传时返回nil表示不修改，传空字符串时返回零值表示取消
+func _parseBannerModifyTime(s *string) (*time.Time, error) {
+	if s == nil {
+		return nil, nil
+	} else if *s == "" {
+		return &time.Time{}, nil
+	}
+
+	return _parseBannerTime(*s)
+}
+
+// _parseAudienceRule 解析json格式的投放规则，空字符串返回nil
+func _parseAudienceRule(text string) (*model.AudienceRule, error) {
+	if text == "" {
+		return nil, nil
+	}
+
+	rule := new(model.AudienceRule)
+	if err := jsoniter.UnmarshalFromString(text, rule); err != nil {
+		return nil, err
+	}
+
+	return rule, nil
+}
+
+type BannerListReq struct {
+	PlatformPaginationReq
+	Slot string `form:"slot"`
 }
 
 func getBannerList(c *gin.Context) {
-	req := new(PlatformPaginationReq)
+	req := new(BannerListReq)
 	if err := c.ShouldBind(req); err != nil {
 		responseEcode(c, ecode.ParamWrong)
 		return
 	}
 
-	bannerList, total, err := srv.GetBannerList(common.Pagination{Page: req.Page, PageSize: req.Size}, req.Platform)
+	bannerList, total, err := srv.GetBannerList(common.Pagination{Page: req.Page, PageSize: req.Size}, req.Platform, req.Slot)
 
 	if err != nil {
 		responseEcode(c, err)
@@ -43,9 +104,15 @@ func getBannerList(c *gin.Context) {
 			Actid:      banner.ID,
 			Title:      *banner.Title,
 			Content:    *banner.Link,
+			LinkType:   srv.BannerLinkType(&banner),
 			ImgUrl:     _getPicUrl(*banner.Img),
 			Status:     _internalBannerStatus2ApiDefineStatus(*banner.Status),
 			Platform:   *banner.Platform,
+			Slot:       _deref(banner.Slot),
+			SortWeight: _deref(banner.SortWeight),
+			StartAt:    _formatBannerTime(banner.StartAt),
+			EndAt:      _formatBannerTime(banner.EndAt),
+			Audience:   banner.Audience,
 			UpdateTime: banner.UpdateTime.Format(_defaultDateTimeFormat),
 		}
 	}
@@ -57,21 +124,60 @@ func getBannerList(c *gin.Context) {
 }
 
 type PublishedBannerResp struct {
-	Actid      int64  `json:"actid"`
-	Title      string `json:"title"`
-	Content    string `json:"content"`
-	ImgUrl     string `json:"imgUrl"`
-	UpdateTime string `json:"updateTime"`
+	Actid      int64              `json:"actid"`
+	Title      string             `json:"title"`
+	Content    string             `json:"content"`  // url类型的链接会替换为短链接，用于统计点击
+	LinkType   string             `json:"linkType"` // 见 model.BannerLinkTypeXxx，旧数据推断不出类型时为空
+	ImgUrl     string             `json:"imgUrl"`
+	Srcset     []BannerImgSrcResp `json:"srcset"` // 图片的各宽度和格式版本，按宽度从小到大排列，客户端按需选择
+	UpdateTime string             `json:"updateTime"`
+	Experiment *ExperimentTagResp `json:"experiment,omitempty"` // 客户端分到的实验变体，不在实验中时没有这个字段
 }
 
+type BannerImgSrcResp struct {
+	Url    string `json:"url"`
+	Width  int    `json:"width"`
+	Format string `json:"format"` // jpg、webp
+}
+
+// _getBannerSrcset 旧数据没有生成各版本，返回空列表
+func _getBannerSrcset(banner *model.Banner) []BannerImgSrcResp {
+	result := make([]BannerImgSrcResp, 0, len(banner.ImgVariants))
+	if _deref(banner.Img) == "" {
+		return result
+	}
+
+	for _, variant := range banner.ImgVariants {
+		result = append(result, BannerImgSrcResp{
+			Url:    srv.GetFileUrl(srv.BannerImgVariantKey(*banner.Img, variant)),
+			Width:  variant.Width,
+			Format: variant.Format,
+		})
+	}
+
+	return result
+}
+
+type PublishedBannerReq struct {
+	Slot string `form:"slot"` // 展示位，为空时为首页轮播
+}
+
+// getPublishedBannerList 获取展示位当前正在展示的轮播图，按排序权重排列
 func getPublishedBannerList(c *gin.Context) {
+	req := new(PublishedBannerReq)
+	if err := c.ShouldBind(req); err != nil {
+		responseEcode(c, ecode.ParamWrong)
+		return
+	}
+
 	platform := getPlatform(c)
 	var resultList *[]model.Banner
+	var assignments service.ExperimentAssignments
 	var err error
 	if platform == "" {
-		resultList, err = srv.GetPublishedBanner()
+		resultList, assignments, err = srv.GetPublishedBanner(req.Slot, getAudienceClient(c))
 	} else {
-		resultList, err = srv.GetPublishedBanner(platform)
+		resultList, assignments, err = srv.GetPublishedBanner(req.Slot, getAudienceClient(c), platform)
 	}
 
 	if err != nil {
@@ -79,14 +185,22 @@ func getPublishedBannerList(c *gin.Context) {
 		return
 	}
 
+	// 计数失败不影响获取
+	if err = srv.RecordBannerImpression(*resultList...); err != nil {
+		log.Warn("记录轮播图展示次数失败", zap.Error(err))
+	}
+
 	publishedBannerList := make([]PublishedBannerResp, len(*resultList))
 	for i, banner := range *resultList {
 		publishedBannerList[i] = PublishedBannerResp{
 			Actid:      int64(i),
 			Title:      *banner.Title,
-			Content:    *banner.Link,
+			Content:    _getBannerShortLinkUrl(c, &banner),
+			LinkType:   srv.BannerLinkType(&banner),
 			ImgUrl:     _getPicUrl(*banner.Img),
+			Srcset:     _getBannerSrcset(&banner),
 			UpdateTime: banner.UpdateTime.Format(_defaultDateTimeFormat),
+			Experiment: _getExperimentTag(assignments, banner.ID),
 		}
 	}
 
@@ -125,11 +239,38 @@ func publishBanner(c *gin.Context) {
 	responseData(c, nil)
 }
 
+type BannerReorderReq struct {
+	Actid []int64 `json:"actid" binding:"required"` // 拖拽排序后的顺序，排在前边的展示在前边
+}
+
+func reorderBanner(c *gin.Context) {
+	req := new(BannerReorderReq)
+	if err := c.ShouldBind(req); err != nil {
+		responseEcode(c, ecode.ParamWrong)
+		return
+	}
+
+	err := srv.ReorderBanner(req.Actid...)
+	if err != nil {
+		responseEcode(c, err)
+		return
+	}
+
+	responseData(c, nil)
+}
+
 type BannerAddReq struct {
-	Title    string                `json:"title" form:"title" binding:"required"`
-	Content  string                `json:"content" form:"content" binding:"required"`
-	Platform []string              `json:"platform" form:"platform" binding:"required"`
-	File     *multipart.FileHeader `json:"file" form:"file" binding:"required"`
+	Title      string                `json:"title" form:"title" binding:"required"`
+	Content    string                `json:"content" form:"content"`   // 链接，没有链接时为空
+	LinkType   string                `json:"linkType" form:"linkType"` // 见 model.BannerLinkTypeXxx，为空时只能推断出url和none类型
+	Platform   []string              `json:"platform" form:"platform" binding:"required"`
+	Slot       string                `json:"slot" form:"slot"` // 展示位，见 model.BannerSlotXxx，为空时为首页轮播
+	SortWeight int                   `json:"sortWeight" form:"sortWeight"`
+	StartAt    string                `json:"startAt" form:"startAt"` // 展示时间段，为空时不限制
+	EndAt      string                `json:"endAt" form:"endAt"`
+	Audience   string                `json:"audience" form:"audience"` // json格式的投放规则，见 model.AudienceRule，为空时对所有人展示
+	File       *multipart.FileHeader `json:"file" form:"file"`
+	UploadId   string                `json:"uploadId" form:"uploadId"` // 分片上传完成的uploadId，和file二选一
 }
 
 func addBanner(c *gin.Context) {
@@ -139,6 +280,22 @@ func addBanner(c *gin.Context) {
 		return
 	}
 
+	startAt, err := _parseBannerTime(req.StartAt)
+	if err != nil {
+		responseEcode(c, ecode.ParamWrong)
+		return
+	}
+	endAt, err := _parseBannerTime(req.EndAt)
+	if err != nil {
+		responseEcode(c, ecode.ParamWrong)
+		return
+	}
+	audience, err := _parseAudienceRule(req.Audience)
+	if err != nil {
+		responseEcode(c, ecode.AudienceRuleInvalid)
+		return
+	}
+
 	var uploadFile *service.File = nil
 	if req.File != nil {
 		// 限制文件100mb以内
@@ -169,16 +326,32 @@ func addBanner(c *gin.Context) {
 			Data:     &fileData,
 			FileName: fileName,
 		}
+	} else if req.UploadId != "" {
+		var err error
+		uploadFile, err = srv.GetUploadedFile(req.UploadId)
+		if err != nil {
+			responseEcode(c, err)
+			return
+		}
+	} else {
+		responseEcode(c, ecode.ParamWrong)
+		return
 	}
 
 	banner := service.BannerAddParam{
-		Title:    req.Title,
-		Link:     req.Content,
-		Img:      uploadFile,
-		Platform: req.Platform,
+		Title:      req.Title,
+		Link:       req.Content,
+		LinkType:   req.LinkType,
+		Img:        uploadFile,
+		Platform:   req.Platform,
+		Slot:       req.Slot,
+		SortWeight: req.SortWeight,
+		StartAt:    startAt,
+		EndAt:      endAt,
+		Audience:   audience,
 	}
 
-	err := srv.AddBanner(&banner)
+	err = srv.AddBanner(&banner)
 	if err != nil {
 		responseEcode(c, err)
 		return
@@ -188,11 +361,18 @@ func addBanner(c *gin.Context) {
 }
 
 type BannerModifyReq struct {
-	Actid    int64   `json:"actid" form:"actid" binding:"required"`
-	Title    *string `json:"title" form:"title"`
-	Content  *string `json:"content" form:"content"`
-	Platform *string `json:"platform" form:"platform"`
-	Status   *int8   `json:"status" form:"status"`
+	Actid      int64   `json:"actid" form:"actid" binding:"required"`
+	Title      *string `json:"title" form:"title"`
+	Content    *string `json:"content" form:"content"`
+	LinkType   *string `json:"linkType" form:"linkType"`
+	Platform   *string `json:"platform" form:"platform"`
+	Slot       *string `json:"slot" form:"slot"`
+	SortWeight *int    `json:"sortWeight" form:"sortWeight"`
+	StartAt    *string `json:"startAt" form:"startAt"`   // 传空字符串时取消开始时间
+	EndAt      *string `json:"endAt" form:"endAt"`       // 传空字符串时取消结束时间
+	Audience   *string `json:"audience" form:"audience"` // json格式的投放规则，传空字符串时取消
+	Status     *int8   `json:"status" form:"status"`
+	UploadId   string  `json:"uploadId" form:"uploadId"` // 分片上传完成的uploadId，需要替换图片时传
 }
 
 func modifyBanner(c *gin.Context) {
@@ -202,15 +382,52 @@ func modifyBanner(c *gin.Context) {
 		return
 	}
 
+	startAt, err := _parseBannerModifyTime(req.StartAt)
+	if err != nil {
+		responseEcode(c, ecode.ParamWrong)
+		return
+	}
+	endAt, err := _parseBannerModifyTime(req.EndAt)
+	if err != nil {
+		responseEcode(c, ecode.ParamWrong)
+		return
+	}
+
+	var audience *model.AudienceRule
+	if req.Audience != nil {
+		if audience, err = _parseAudienceRule(*req.Audience); err != nil {
+			responseEcode(c, ecode.AudienceRuleInvalid)
+			return
+		} else if audience == nil {
+			audience = new(model.AudienceRule)
+		}
+	}
+
+	var uploadFile *service.File = nil
+	if req.UploadId != "" {
+		uploadFile, err = srv.GetUploadedFile(req.UploadId)
+		if err != nil {
+			responseEcode(c, err)
+			return
+		}
+	}
+
 	banner := service.BannerModifyParam{
-		Id:       req.Actid,
-		Title:    req.Title,
-		Link:     req.Content,
-		Platform: req.Platform,
-		Status:   req.Status,
+		Id:         req.Actid,
+		Title:      req.Title,
+		Link:       req.Content,
+		LinkType:   req.LinkType,
+		Img:        uploadFile,
+		Platform:   req.Platform,
+		Slot:       req.Slot,
+		SortWeight: req.SortWeight,
+		StartAt:    startAt,
+		EndAt:      endAt,
+		Audience:   audience,
+		Status:     req.Status,
 	}
 
-	err := srv.ModifyBanner(&banner)
+	err = srv.ModifyBanner(&banner)
 	if err != nil {
 		responseEcode(c, err)
 		return
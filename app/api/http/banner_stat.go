@@ -0,0 +1,135 @@
+package http
+
+import (
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+	"wusthelper-manager-go/app/model"
+	"wusthelper-manager-go/library/ecode"
+	"wusthelper-manager-go/library/log"
+)
+
+// _getBannerShortLinkUrl 轮播图链接对应的短链接，优先使用配置的地址前缀，没有配置时根据请求地址推断。
+// 只有url类型的链接可以通过短链接跳转，其他类型（如小程序页面路径）原样返回给客户端
+func _getBannerShortLinkUrl(c *gin.Context, banner *model.Banner) string {
+	link := _deref(banner.Link)
+	if srv.BannerLinkType(banner) != model.BannerLinkTypeUrl {
+		return link
+	}
+
+	code := srv.BannerShortCode(banner.ID)
+	if baseUrl := config.Server.BannerOption.ShortLinkBaseUrl; baseUrl != "" {
+		return strings.TrimSuffix(baseUrl, "/") + "/" + code
+	}
+
+	return _requestOrigin(c) + path.Join("/", config.Server.BaseUrl, "s", code)
+}
+
+type BannerShortLinkReq struct {
+	Code string `uri:"code" binding:"required"`
+}
+
+// redirectBannerShortLink 记录轮播图的点击次数，并重定向到轮播图的链接
+func redirectBannerShortLink(c *gin.Context) {
+	req := new(BannerShortLinkReq)
+	if err := c.ShouldBindUri(req); err != nil {
+		responseEcode(c, ecode.ParamWrong)
+		return
+	}
+
+	banner, err := srv.GetBannerByShortCode(req.Code)
+	if err != nil {
+		responseEcode(c, err)
+		return
+	} else if srv.BannerLinkType(banner) != model.BannerLinkTypeUrl {
+		responseEcode(c, ecode.InvalidId)
+		return
+	}
+
+	// 计数失败不影响跳转
+	if err = srv.RecordBannerClick(banner); err != nil {
+		log.Warn("记录轮播图点击次数失败", zap.Int64("id", banner.ID), zap.Error(err))
+	}
+
+	c.Redirect(http.StatusFound, *banner.Link)
+}
+
+type BannerStatReq struct {
+	Actid     int64  `form:"actid"`     // 为0时不限制
+	Platform  string `form:"platform"`  // 为空时不限制
+	StartDate string `form:"startDate"` // yyyy-MM-dd，默认为30天前
+	EndDate   string `form:"endDate"`   // yyyy-MM-dd，默认为今天
+}
+
+type BannerStatResp struct {
+	Actid       int64   `json:"actid"`
+	Title       string  `json:"title"`
+	Slot        string  `json:"slot"`
+	Platform    string  `json:"platform"`
+	Impressions int64   `json:"impressions"`
+	Clicks      int64   `json:"clicks"`
+	Ctr         float64 `json:"ctr"` // 点击率，没有展示时为0
+	Deleted     bool    `json:"deleted"`
+}
+
+// getBannerStat 获取各轮播图的展示、点击次数和点击率，数据定期从redis写入，最近几分钟的数据可能还没有统计进来
+func getBannerStat(c *gin.Context) {
+	req := new(BannerStatReq)
+	if err := c.ShouldBind(req); err != nil {
+		responseEcode(c, ecode.ParamWrong)
+		return
+	}
+
+	end := time.Now()
+	if req.EndDate != "" {
+		var err error
+		if end, err = time.ParseInLocation(_defaultDateFormat, req.EndDate, time.Local); err != nil {
+			responseEcode(c, ecode.ParamWrong)
+			return
+		}
+	}
+
+	start := end.AddDate(0, 0, -30)
+	if req.StartDate != "" {
+		var err error
+		if start, err = time.ParseInLocation(_defaultDateFormat, req.StartDate, time.Local); err != nil {
+			responseEcode(c, ecode.ParamWrong)
+			return
+		}
+	}
+
+	report, err := srv.GetBannerStatReport(req.Actid, req.Platform, start, end)
+	if err != nil {
+		responseEcode(c, err)
+		return
+	}
+
+	totalImpressions, totalClicks := int64(0), int64(0)
+	resultList := make([]BannerStatResp, len(report))
+	for i, item := range report {
+		resultList[i] = BannerStatResp{
+			Actid:       item.BannerId,
+			Platform:    item.Platform,
+			Impressions: item.Impressions,
+			Clicks:      item.Clicks,
+			Ctr:         item.Ctr,
+			Deleted:     item.Banner == nil || _deref(item.Banner.Status) == model.DeletedStatus,
+		}
+		if item.Banner != nil {
+			resultList[i].Title = _deref(item.Banner.Title)
+			resultList[i].Slot = _deref(item.Banner.Slot)
+		}
+
+		totalImpressions += item.Impressions
+		totalClicks += item.Clicks
+	}
+
+	responseData(c, map[string]any{
+		"actList":     resultList,
+		"impressions": totalImpressions,
+		"clicks":      totalClicks,
+	})
+}
@@ -0,0 +1,264 @@
+package http
+
+import (
+	"github.com/dustin/go-humanize"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"io"
+	"mime/multipart"
+	"wusthelper-manager-go/app/model"
+	"wusthelper-manager-go/app/service"
+	"wusthelper-manager-go/common"
+	"wusthelper-manager-go/library/ecode"
+	"wusthelper-manager-go/library/log"
+)
+
+type BundleResp struct {
+	Id               int64  `json:"id"`
+	Name             string `json:"name"`
+	Version          string `json:"version"`
+	Platform         string `json:"platform"`
+	MinNativeVersion string `json:"minNativeVersion"`
+	MaxNativeVersion string `json:"maxNativeVersion"`
+	Summary          string `json:"summary"`
+	FileCount        int    `json:"fileCount"`
+	TotalSize        int64  `json:"totalSize"`
+	Ready            bool   `json:"ready"`
+	Published        bool   `json:"published"`
+	CreateTime       string `json:"createTime"`
+}
+
+type BundleListReq struct {
+	PlatformPaginationReq
+	Name string `form:"name"`
+}
+
+func getBundleList(c *gin.Context) {
+	req := new(BundleListReq)
+	if err := c.ShouldBind(req); err != nil {
+		responseEcode(c, ecode.ParamWrong)
+		return
+	}
+
+	bundleList, total, err := srv.GetBundleList(common.Pagination{Page: req.Page, PageSize: req.Size}, req.Platform, req.Name)
+	if err != nil {
+		responseEcode(c, err)
+		return
+	}
+
+	resultList := make([]BundleResp, len(*bundleList))
+	for i, bundle := range *bundleList {
+		resultList[i] = BundleResp{
+			Id:               bundle.ID,
+			Name:             *bundle.Name,
+			Version:          *bundle.VersionText,
+			Platform:         *bundle.Platform,
+			MinNativeVersion: _deref(bundle.MinNativeVersion),
+			MaxNativeVersion: _deref(bundle.MaxNativeVersion),
+			Summary:          _deref(bundle.Summary),
+			FileCount:        _deref(bundle.FileCount),
+			TotalSize:        _deref(bundle.TotalSize),
+			Ready:            _deref(bundle.Ready),
+			Published:        _deref(bundle.Status) == model.BundlePublishedStatus,
+			CreateTime:       bundle.CreateTime.Format(_defaultDateTimeFormat),
+		}
+	}
+
+	responseData(c, map[string]any{
+		"bundles": resultList,
+		"num":     total,
+	})
+}
+
+type BundleAddReq struct {
+	Name             string                `form:"name" binding:"required"`
+	Version          string                `form:"version" binding:"required"`
+	Platform         string                `form:"platform" binding:"required"`
+	MinNativeVersion string                `form:"minNativeVersion"` // 兼容的原生版本范围，为空时不限制
+	MaxNativeVersion string                `form:"maxNativeVersion"`
+	Summary          string                `form:"summary"`
+	File             *multipart.FileHeader `form:"file"`     // zip文件
+	UploadId         string                `form:"uploadId"` // 分片上传完成的uploadId，和file二选一
+}
+
+func addBundle(c *gin.Context) {
+	req := new(BundleAddReq)
+	if err := c.ShouldBind(req); err != nil {
+		responseEcode(c, ecode.ParamWrong)
+		return
+	}
+
+	var uploadFile *service.File = nil
+	if req.File != nil {
+		// 限制文件100mb以内
+		if req.File.Size > 100*humanize.MByte {
+			responseEcode(c, ecode.BundleOperationFailed)
+			return
+		}
+
+		file, err := req.File.Open()
+		if err != nil {
+			responseEcode(c, ecode.InternalError)
+			return
+		}
+
+		fileData, err := io.ReadAll(file)
+		if err != nil {
+			log.Error("读取上传文件时出现错误", zap.Error(err))
+			responseEcode(c, ecode.InternalError)
+			return
+		}
+
+		uploadFile = &service.File{
+			Data:     &fileData,
+			FileName: req.File.Filename,
+		}
+	} else if req.UploadId != "" {
+		var err error
+		uploadFile, err = srv.GetUploadedFile(req.UploadId)
+		if err != nil {
+			responseEcode(c, err)
+			return
+		}
+	}
+
+	err := srv.AddBundle(&service.BundleAddParam{
+		Name:             req.Name,
+		Version:          req.Version,
+		Platform:         req.Platform,
+		MinNativeVersion: req.MinNativeVersion,
+		MaxNativeVersion: req.MaxNativeVersion,
+		Summary:          req.Summary,
+		UploadFile:       uploadFile,
+	})
+	if err != nil {
+		responseEcode(c, err)
+		return
+	}
+
+	responseData(c, nil)
+}
+
+type BundleIdReq struct {
+	Id int64 `json:"id" form:"id" binding:"required"`
+}
+
+func publishBundle(c *gin.Context) {
+	req := new(BundleIdReq)
+	if err := c.ShouldBind(req); err != nil {
+		responseEcode(c, ecode.ParamWrong)
+		return
+	}
+
+	err := srv.PublishBundle(req.Id)
+	if err != nil {
+		responseEcode(c, err)
+		return
+	}
+
+	responseData(c, nil)
+}
+
+func offlineBundle(c *gin.Context) {
+	req := new(BundleIdReq)
+	if err := c.ShouldBind(req); err != nil {
+		responseEcode(c, ecode.ParamWrong)
+		return
+	}
+
+	err := srv.OfflineBundle(req.Id)
+	if err != nil {
+		responseEcode(c, err)
+		return
+	}
+
+	responseData(c, nil)
+}
+
+func deleteBundle(c *gin.Context) {
+	req := new(BundleIdReq)
+	if err := c.ShouldBind(req); err != nil {
+		responseEcode(c, ecode.ParamWrong)
+		return
+	}
+
+	err := srv.DeleteBundle(req.Id)
+	if err != nil {
+		responseEcode(c, err)
+		return
+	}
+
+	responseData(c, nil)
+}
+
+type BundleFileResp struct {
+	Path   string `json:"path"`
+	Url    string `json:"url"`
+	Size   int64  `json:"size"`
+	Sha256 string `json:"sha256"`
+}
+
+type BundleUpdateResp struct {
+	Name     string           `json:"name"`
+	Version  string           `json:"version"`
+	Summary  string           `json:"summary"`
+	UpToDate bool             `json:"upToDate"`
+	Full     bool             `json:"full"` // 为true时added是完整的文件清单，客户端需要删除不在其中的文件
+	Added    []BundleFileResp `json:"added"`
+	Modified []BundleFileResp `json:"modified"`
+	Removed  []string         `json:"removed"`
+}
+
+func _toBundleFileRespList(entries []service.BundleFileEntry) []BundleFileResp {
+	result := make([]BundleFileResp, len(entries))
+	for i, entry := range entries {
+		result[i] = BundleFileResp{
+			Path:   entry.Path,
+			Url:    srv.GetFileUrl(srv.BundleObjectKey(entry.Sha256)),
+			Size:   entry.Size,
+			Sha256: entry.Sha256,
+		}
+	}
+
+	return result
+}
+
+type BundleUpdateReq struct {
+	Name          string `form:"name" binding:"required"`
+	BundleVersion string `form:"bundleVersion"` // 客户端当前的资源包版本，为空时返回完整的文件清单
+}
+
+// getBundleUpdate 客户端获取资源包更新，原生版本号放在header的Version或者query的version中，没有可用的资源包时返回null
+func getBundleUpdate(c *gin.Context) {
+	platform := getPlatform(c)
+	if platform == "" {
+		responseEcode(c, ecode.PlatformMissing)
+		return
+	}
+
+	req := new(BundleUpdateReq)
+	if err := c.ShouldBind(req); err != nil {
+		responseEcode(c, ecode.ParamWrong)
+		return
+	}
+
+	update, err := srv.GetBundleUpdate(req.Name, platform, getClientVersion(c), req.BundleVersion)
+	if err != nil {
+		responseEcode(c, err)
+		return
+	} else if update == nil {
+		responseData(c, nil)
+		return
+	}
+
+	responseData(c, BundleUpdateResp{
+		Name:     *update.Bundle.Name,
+		Version:  *update.Bundle.VersionText,
+		Summary:  _deref(update.Bundle.Summary),
+		UpToDate: update.UpToDate,
+		Full:     update.Full,
+		Added:    _toBundleFileRespList(update.Added),
+		Modified: _toBundleFileRespList(update.Modified),
+		Removed:  append([]string{}, update.Removed...),
+	})
+}
@@ -4,7 +4,10 @@ import (
 	"fmt"
 	"github.com/gin-gonic/gin"
 	"github.com/pkg/errors"
-	"net/url"
+	"net"
+	"path"
+	"strings"
+	"wusthelper-manager-go/app/service"
 	"wusthelper-manager-go/library/ecode"
 	"wusthelper-manager-go/library/log"
 )
@@ -27,6 +30,16 @@ type PlatformPaginationReq struct {
 	Platform string `json:"platform" form:"platform" query:"platform"`
 }
 
+// _deref 取指针的值，指针为空时返回零值，用于数据库中可为NULL的字段
+func _deref[T any](p *T) T {
+	if p == nil {
+		var zero T
+		return zero
+	}
+
+	return *p
+}
+
 func _convertBoolStr2Bool(str string) bool {
 	switch str {
 	case "true":
@@ -50,16 +63,8 @@ func _getPicUrl(imgId string) string {
 		return ""
 	}
 
-	fileStorageOption := config.Server.FileStorageOption
-	basePath := fileStorageOption.ResourceStorageOption.PicStorageBasePath
-	domain := fileStorageOption.AliyunOssOption.BucketBindDomain
-	u := url.URL{
-		Scheme: "https",
-		Host:   domain,
-		Path:   basePath,
-	}
-
-	return u.JoinPath(fmt.Sprintf("%s.jpg", imgId)).String()
+	basePath := config.Server.FileStorageOption.ResourceStorageOption.PicStorageBasePath
+	return srv.GetFileUrl(path.Join(basePath, fmt.Sprintf("%s.jpg", imgId)))
 }
 
 func _getFileUrl(fileKey string) string {
@@ -67,22 +72,106 @@ func _getFileUrl(fileKey string) string {
 		return ""
 	}
 
-	fileStorageOption := config.Server.FileStorageOption
-	basePath := fileStorageOption.ResourceStorageOption.VersionFileStorageBasePath
-	domain := fileStorageOption.AliyunOssOption.BucketBindDomain
-	u := url.URL{
-		Scheme: "https",
-		Host:   domain,
-		Path:   basePath,
+	basePath := config.Server.FileStorageOption.ResourceStorageOption.VersionFileStorageBasePath
+	return srv.GetFileUrl(path.Join(basePath, fileKey))
+}
+
+// _requestOrigin 请求的协议和域名，如 https://example.com。
+// 只有来自TrustedProxies的请求才使用X-Forwarded-Proto和X-Forwarded-Host，否则客户端可以随意伪造
+func _requestOrigin(c *gin.Context) string {
+	forwarded := _fromTrustedProxy(c)
+
+	scheme := ""
+	if forwarded {
+		scheme = c.GetHeader("X-Forwarded-Proto")
+	}
+	if scheme == "" {
+		scheme = "http"
+		if c.Request.TLS != nil {
+			scheme = "https"
+		}
 	}
 
-	return u.JoinPath(fileKey).String()
+	host := ""
+	if forwarded {
+		host = c.GetHeader("X-Forwarded-Host")
+	}
+	if host == "" {
+		host = c.Request.Host
+	}
+
+	return fmt.Sprintf("%s://%s", scheme, host)
+}
+
+// _fromTrustedProxy 请求是否直接来自配置的反向代理，和gin判断ClientIP时用的是同一份配置
+func _fromTrustedProxy(c *gin.Context) bool {
+	remoteIp := net.ParseIP(c.RemoteIP())
+	if remoteIp == nil {
+		return false
+	}
+
+	for _, proxy := range config.Server.TrustedProxies {
+		if strings.Contains(proxy, "/") {
+			_, cidr, err := net.ParseCIDR(proxy)
+			if err == nil && cidr.Contains(remoteIp) {
+				return true
+			}
+		} else if ip := net.ParseIP(proxy); ip != nil && ip.Equal(remoteIp) {
+			return true
+		}
+	}
+
+	return false
 }
 
 func getPlatform(c *gin.Context) string {
 	return c.GetHeader("Platform")
 }
 
+// getClientVersion 获取客户端当前的版本号，可以放在header的Version或者query的version中，都没有时返回空
+func getClientVersion(c *gin.Context) string {
+	if version := c.GetHeader("Version"); version != "" {
+		return version
+	}
+
+	return c.Query("version")
+}
+
+// getClientAbis 获取客户端支持的ABI，按优先级排列，放在header的Abi或者query的abi中，逗号分隔
+func getClientAbis(c *gin.Context) []string {
+	abiText := c.GetHeader("Abi")
+	if abiText == "" {
+		abiText = c.Query("abi")
+	}
+	if abiText == "" {
+		return nil
+	}
+
+	abis := strings.Split(abiText, ",")
+	for i := range abis {
+		abis[i] = strings.TrimSpace(abis[i])
+	}
+
+	return abis
+}
+
+// getAudienceClient 获取客户端的助手token、版本号和设备信息，用于匹配公告、轮播图的投放规则和实验分组，token放在header的Token中
+func getAudienceClient(c *gin.Context) service.AudienceClient {
+	client := getVersionClient(c)
+	return service.AudienceClient{Token: client.Token, Version: getClientVersion(c), Client: client}
+}
+
+// getVersionClient 获取客户端的设备id和助手token，用于匹配测试人员和灰度发布分桶，设备id优先从header中获取。
+// 学号由service通过token获取，不使用客户端传的学号
+func getVersionClient(c *gin.Context) service.VersionClient {
+	deviceId := c.GetHeader("Device-Id")
+	if deviceId == "" {
+		deviceId = c.Query("deviceId")
+	}
+
+	return service.VersionClient{DeviceId: deviceId, Token: c.GetHeader("Token")}
+}
+
 func getUid(c *gin.Context) (uint64, error) {
 	// 这里的值是在token校验（auth.UserTokenCheck）的时候设置的
 	_oid, ok := c.Get("uid")
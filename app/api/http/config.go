@@ -62,7 +62,7 @@ func getConfigListPublic(c *gin.Context) {
 	}
 
 	// 获取最新版本
-	latestVersion, err := srv.GetLatestVersion(platform)
+	latestVersion, err := srv.GetLatestVersionForClient(platform, getVersionClient(c))
 	if err != nil {
 		responseEcode(c, err)
 		return
@@ -0,0 +1,168 @@
+package http
+
+import (
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"time"
+	"wusthelper-manager-go/app/model"
+	"wusthelper-manager-go/library/ecode"
+	"wusthelper-manager-go/library/log"
+)
+
+type LatestVersionDownloadReq struct {
+	Platform string `uri:"platform" binding:"required"`
+}
+
+// downloadLatestVersion 重定向到平台最新版本的安装包，并记录下载次数
+func downloadLatestVersion(c *gin.Context) {
+	req := new(LatestVersionDownloadReq)
+	if err := c.ShouldBindUri(req); err != nil {
+		responseEcode(c, ecode.ParamWrong)
+		return
+	}
+
+	version, err := srv.GetDownloadVersion(0, req.Platform, getVersionClient(c))
+	if err != nil {
+		responseEcode(c, err)
+		return
+	}
+
+	redirectDownload(c, version)
+}
+
+type VersionDownloadReq struct {
+	Id int64 `uri:"id" binding:"required"`
+}
+
+// downloadVersion 重定向到指定版本的安装包，并记录下载次数
+func downloadVersion(c *gin.Context) {
+	req := new(VersionDownloadReq)
+	if err := c.ShouldBindUri(req); err != nil {
+		responseEcode(c, ecode.ParamWrong)
+		return
+	}
+
+	version, err := srv.GetDownloadVersion(req.Id, "", getVersionClient(c))
+	if err != nil {
+		responseEcode(c, err)
+		return
+	}
+
+	redirectDownload(c, version)
+}
+
+type ArtifactDownloadReq struct {
+	Id int64 `uri:"id" binding:"required"`
+}
+
+// downloadVersionArtifact 重定向到版本的某个文件（如某个ABI的安装包或应用商店链接），下载次数计入所属版本
+func downloadVersionArtifact(c *gin.Context) {
+	req := new(ArtifactDownloadReq)
+	if err := c.ShouldBindUri(req); err != nil {
+		responseEcode(c, ecode.ParamWrong)
+		return
+	}
+
+	artifact, version, err := srv.GetDownloadArtifact(req.Id, getVersionClient(c))
+	if err != nil {
+		responseEcode(c, err)
+		return
+	}
+
+	if err = srv.RecordDownload(version); err != nil {
+		log.Warn("记录下载次数失败", zap.Int64("id", version.ID), zap.Error(err))
+	}
+
+	c.Redirect(http.StatusFound, _getArtifactUrl(artifact))
+}
+
+// _versionDownloadUrl 版本计数下载的地址（见 downloadVersion），带上客户端的设备id，跳转时灰度中的版本仍然可见
+func _versionDownloadUrl(c *gin.Context, versionId int64) string {
+	return _downloadUrl(c, "version", versionId)
+}
+
+// _artifactDownloadUrl 版本文件计数下载的地址（见 downloadVersionArtifact）
+func _artifactDownloadUrl(c *gin.Context, artifactId int64) string {
+	return _downloadUrl(c, "artifact", artifactId)
+}
+
+func _downloadUrl(c *gin.Context, kind string, id int64) string {
+	downloadUrl := _requestOrigin(c) + path.Join("/", config.Server.BaseUrl, "wusthelper/download", kind, strconv.FormatInt(id, 10))
+	if deviceId := getVersionClient(c).DeviceId; deviceId != "" {
+		downloadUrl += "?deviceId=" + url.QueryEscape(deviceId)
+	}
+
+	return downloadUrl
+}
+
+// redirectDownload 计数失败不影响下载
+func redirectDownload(c *gin.Context, version *model.Version) {
+	if err := srv.RecordDownload(version); err != nil {
+		log.Warn("记录下载次数失败", zap.Int64("id", version.ID), zap.Error(err))
+	}
+
+	c.Redirect(http.StatusFound, _getFileUrl(*version.File))
+}
+
+type DownloadStatReq struct {
+	VersionId int64  `form:"versionId"` // 为0时不限制
+	Platform  string `form:"platform"`  // 为空时不限制
+	StartDate string `form:"startDate"` // yyyy-MM-dd，默认为30天前
+	EndDate   string `form:"endDate"`   // yyyy-MM-dd，默认为今天
+}
+
+type DownloadStatResp struct {
+	Date  string `json:"date"`
+	Count int64  `json:"count"`
+}
+
+// getDownloadStat 获取按天汇总的下载次数，数据定期从redis写入，最近几分钟的下载可能还没有统计进来
+func getDownloadStat(c *gin.Context) {
+	req := new(DownloadStatReq)
+	if err := c.ShouldBind(req); err != nil {
+		responseEcode(c, ecode.ParamWrong)
+		return
+	}
+
+	end := time.Now()
+	if req.EndDate != "" {
+		var err error
+		if end, err = time.ParseInLocation(_defaultDateFormat, req.EndDate, time.Local); err != nil {
+			responseEcode(c, ecode.ParamWrong)
+			return
+		}
+	}
+
+	start := end.AddDate(0, 0, -30)
+	if req.StartDate != "" {
+		var err error
+		if start, err = time.ParseInLocation(_defaultDateFormat, req.StartDate, time.Local); err != nil {
+			responseEcode(c, ecode.ParamWrong)
+			return
+		}
+	}
+
+	statList, err := srv.GetDownloadStatSeries(req.VersionId, req.Platform, start, end)
+	if err != nil {
+		responseEcode(c, err)
+		return
+	}
+
+	total, resultList := int64(0), make([]DownloadStatResp, len(statList))
+	for i, stat := range statList {
+		resultList[i] = DownloadStatResp{
+			Date:  stat.Date.Format(_defaultDateFormat),
+			Count: *stat.Count,
+		}
+		total += *stat.Count
+	}
+
+	responseData(c, map[string]any{
+		"series": resultList,
+		"total":  total,
+	})
+}
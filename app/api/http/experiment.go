@@ -0,0 +1,268 @@
+package http
+
+import (
+	"github.com/gin-gonic/gin"
+	"wusthelper-manager-go/app/model"
+	"wusthelper-manager-go/app/service"
+	"wusthelper-manager-go/common"
+	"wusthelper-manager-go/library/ecode"
+)
+
+// ExperimentTagResp 公开接口中标记客户端分到的实验变体，客户端点击时带上这两个id上报
+type ExperimentTagResp struct {
+	ExperimentId int64 `json:"experimentId"`
+	VariantId    int64 `json:"variantId"`
+}
+
+// _getExperimentTag 变体不在客户端分到的实验中时返回nil
+func _getExperimentTag(assignments service.ExperimentAssignments, targetId int64) *ExperimentTagResp {
+	experimentId, ok := assignments[targetId]
+	if !ok {
+		return nil
+	}
+
+	return &ExperimentTagResp{ExperimentId: experimentId, VariantId: targetId}
+}
+
+type ExperimentVariantReq struct {
+	TargetId int64 `json:"targetId" binding:"required"` // 轮播图或公告的id
+	Weight   int   `json:"weight" binding:"required"`
+}
+
+type ExperimentResp struct {
+	Id         int64                     `json:"id"`
+	Name       string                    `json:"name"`
+	TargetType string                    `json:"targetType"`
+	Variants   []model.ExperimentVariant `json:"variants"`
+	Status     int8                      `json:"status"` // 0为未开始，2为进行中，3为已结束
+	CreateTime string                    `json:"createTime"`
+	UpdateTime string                    `json:"updateTime"`
+}
+
+type ExperimentListReq struct {
+	Page       int    `form:"page,default=1"`
+	Size       int    `form:"size,default=10"`
+	TargetType string `form:"targetType"` // banner或announcement，为空时不限制
+}
+
+func getExperimentList(c *gin.Context) {
+	req := new(ExperimentListReq)
+	if err := c.ShouldBind(req); err != nil {
+		responseEcode(c, ecode.ParamWrong)
+		return
+	}
+
+	experimentList, total, err := srv.GetExperimentList(common.Pagination{Page: req.Page, PageSize: req.Size}, req.TargetType)
+	if err != nil {
+		responseEcode(c, err)
+		return
+	}
+
+	resultList := make([]ExperimentResp, len(*experimentList))
+	for i, experiment := range *experimentList {
+		resultList[i] = ExperimentResp{
+			Id:         experiment.ID,
+			Name:       _deref(experiment.Name),
+			TargetType: _deref(experiment.TargetType),
+			Variants:   experiment.Variants,
+			Status:     _deref(experiment.Status),
+			CreateTime: experiment.CreateTime.Format(_defaultDateTimeFormat),
+			UpdateTime: experiment.UpdateTime.Format(_defaultDateTimeFormat),
+		}
+	}
+
+	responseData(c, map[string]any{
+		"experiments": resultList,
+		"num":         total,
+	})
+}
+
+func _toExperimentVariants(variants []ExperimentVariantReq) []model.ExperimentVariant {
+	result := make([]model.ExperimentVariant, len(variants))
+	for i, variant := range variants {
+		result[i] = model.ExperimentVariant{TargetId: variant.TargetId, Weight: variant.Weight}
+	}
+
+	return result
+}
+
+type ExperimentAddReq struct {
+	Name       string                 `json:"name" binding:"required"`
+	TargetType string                 `json:"targetType" binding:"required,oneof=banner announcement"`
+	Variants   []ExperimentVariantReq `json:"variants" binding:"required,dive"` // 第一个为对照组
+}
+
+func addExperiment(c *gin.Context) {
+	req := new(ExperimentAddReq)
+	if err := c.ShouldBindJSON(req); err != nil {
+		responseEcode(c, ecode.ParamWrong)
+		return
+	}
+
+	err := srv.AddExperiment(&service.ExperimentAddParam{
+		Name:       req.Name,
+		TargetType: req.TargetType,
+		Variants:   _toExperimentVariants(req.Variants),
+	})
+	if err != nil {
+		responseEcode(c, err)
+		return
+	}
+
+	responseData(c, nil)
+}
+
+type ExperimentModifyReq struct {
+	Id       int64                  `json:"id" binding:"required"`
+	Name     *string                `json:"name"`
+	Variants []ExperimentVariantReq `json:"variants" binding:"dive"` // 为空时不修改
+}
+
+// modifyExperiment 只能修改未开始的实验
+func modifyExperiment(c *gin.Context) {
+	req := new(ExperimentModifyReq)
+	if err := c.ShouldBindJSON(req); err != nil {
+		responseEcode(c, ecode.ParamWrong)
+		return
+	}
+
+	param := &service.ExperimentModifyParam{Id: req.Id, Name: req.Name}
+	if len(req.Variants) > 0 {
+		param.Variants = _toExperimentVariants(req.Variants)
+	}
+
+	err := srv.ModifyExperiment(param)
+	if err != nil {
+		responseEcode(c, err)
+		return
+	}
+
+	responseData(c, nil)
+}
+
+type ExperimentIdReq struct {
+	Id int64 `json:"id" form:"id" binding:"required"`
+}
+
+func startExperiment(c *gin.Context) {
+	req := new(ExperimentIdReq)
+	if err := c.ShouldBind(req); err != nil {
+		responseEcode(c, ecode.ParamWrong)
+		return
+	}
+
+	err := srv.StartExperiment(req.Id)
+	if err != nil {
+		responseEcode(c, err)
+		return
+	}
+
+	responseData(c, nil)
+}
+
+func stopExperiment(c *gin.Context) {
+	req := new(ExperimentIdReq)
+	if err := c.ShouldBind(req); err != nil {
+		responseEcode(c, ecode.ParamWrong)
+		return
+	}
+
+	err := srv.StopExperiment(req.Id)
+	if err != nil {
+		responseEcode(c, err)
+		return
+	}
+
+	responseData(c, nil)
+}
+
+func deleteExperiment(c *gin.Context) {
+	req := new(ExperimentIdReq)
+	if err := c.ShouldBind(req); err != nil {
+		responseEcode(c, ecode.ParamWrong)
+		return
+	}
+
+	err := srv.DeleteExperiment(req.Id)
+	if err != nil {
+		responseEcode(c, err)
+		return
+	}
+
+	responseData(c, nil)
+}
+
+type ExperimentVariantReportResp struct {
+	TargetId   int64   `json:"targetId"`
+	Title      string  `json:"title"` // 变体已被删除时为空
+	Weight     int     `json:"weight"`
+	Control    bool    `json:"control"`
+	Exposures  int64   `json:"exposures"`
+	Clicks     int64   `json:"clicks"`
+	Conversion float64 `json:"conversion"`
+	Uplift     float64 `json:"uplift"` // 相对对照组点击率的提升
+	ZScore     float64 `json:"zScore"`
+	PValue     float64 `json:"pValue"` // 和对照组比较的p值，小于0.05时可以认为差异显著
+}
+
+// getExperimentReport 获取实验各变体的曝光、点击、点击率和显著性，数据定期从redis写入，最近几分钟的数据可能还没有统计进来
+func getExperimentReport(c *gin.Context) {
+	req := new(ExperimentIdReq)
+	if err := c.ShouldBind(req); err != nil {
+		responseEcode(c, ecode.ParamWrong)
+		return
+	}
+
+	report, err := srv.GetExperimentReport(req.Id)
+	if err != nil {
+		responseEcode(c, err)
+		return
+	}
+
+	variants := make([]ExperimentVariantReportResp, len(report.Variants))
+	for i, variant := range report.Variants {
+		variants[i] = ExperimentVariantReportResp{
+			TargetId:   variant.TargetId,
+			Title:      variant.Title,
+			Weight:     variant.Weight,
+			Control:    variant.Control,
+			Exposures:  variant.Exposures,
+			Clicks:     variant.Clicks,
+			Conversion: variant.Conversion,
+			Uplift:     variant.Uplift,
+			ZScore:     variant.ZScore,
+			PValue:     variant.PValue,
+		}
+	}
+
+	experiment := report.Experiment
+	responseData(c, map[string]any{
+		"id":         experiment.ID,
+		"name":       _deref(experiment.Name),
+		"targetType": _deref(experiment.TargetType),
+		"status":     _deref(experiment.Status),
+		"variants":   variants,
+	})
+}
+
+type ExperimentClickReq struct {
+	ExperimentId int64 `json:"experimentId" form:"experimentId" binding:"required"`
+	VariantId    int64 `json:"variantId" form:"variantId" binding:"required"`
+}
+
+// recordExperimentClick 客户端点击实验中的公告或轮播图时上报，url类型的轮播图通过短链接统计，不需要上报
+func recordExperimentClick(c *gin.Context) {
+	req := new(ExperimentClickReq)
+	if err := c.ShouldBind(req); err != nil {
+		responseEcode(c, ecode.ParamWrong)
+		return
+	}
+
+	err := srv.RecordExperimentClick(req.ExperimentId, req.VariantId)
+	if err != nil {
+		responseEcode(c, err)
+		return
+	}
+
+	responseData(c, nil)
+}
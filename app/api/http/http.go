@@ -3,11 +3,16 @@ package http
 import (
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
+	"net/http"
+	"path"
+	"path/filepath"
+	"strings"
 	"time"
 	"wusthelper-manager-go/app/conf"
 	"wusthelper-manager-go/app/middleware"
 	"wusthelper-manager-go/app/middleware/auth"
 	"wusthelper-manager-go/app/service"
+	"wusthelper-manager-go/library/storage"
 	"wusthelper-manager-go/library/token"
 )
 
@@ -20,6 +25,9 @@ var (
 func NewEngine(c *conf.Config, baseUrl string) (*gin.Engine, error) {
 	config = c
 	engine := gin.Default()
+	if err := engine.SetTrustedProxies(c.Server.TrustedProxies); err != nil {
+		return nil, err
+	}
 	//中间件在路由配置开始前才生效
 	engine.Use(middleware.GlobalPanicRecover)
 	corsConfig := cors.DefaultConfig()
@@ -34,6 +42,7 @@ func NewEngine(c *conf.Config, baseUrl string) (*gin.Engine, error) {
 	//rootRouter.Use(gin.LoggerWithWriter(*log.DefaultWriter().))
 
 	setupRouter(rootRouter)
+	setupLocalStorageRouter(engine, c)
 
 	var err error
 	srv, err = service.New(c)
@@ -52,6 +61,73 @@ func setupRouter(rootRouter *gin.RouterGroup) {
 	setupPublicApiRouter(rootRouter)
 }
 
+// setupLocalStorageRouter 使用本地文件存储时，在配置的路由下提供存储文件的静态访问。
+// 只提供版本文件、图片、热更新资源包目录和最新版本固定链接，存储清理归档的对象不对外提供
+func setupLocalStorageRouter(engine *gin.Engine, c *conf.Config) {
+	storageConfig := c.Server.FileStorageOption.Config
+	localOption := storageConfig.LocalStorageOption
+	if localOption.ServePath == "" || storageConfig.ActualType() != storage.TypeLocal {
+		return
+	}
+
+	resourceOption := c.Server.FileStorageOption.ResourceStorageOption
+	prefixes := make([]string, 0, 3)
+	for _, prefix := range []string{
+		resourceOption.VersionFileStorageBasePath,
+		resourceOption.PicStorageBasePath,
+		resourceOption.BundleStorageBasePath,
+	} {
+		if prefix = strings.Trim(prefix, "/"); prefix != "" {
+			prefixes = append(prefixes, prefix)
+		}
+	}
+
+	local := engine.Group(localOption.ServePath, denyStoragePrefix(localOption.ServePath, c.Server.StorageGcOption.ColdPrefix))
+	for _, prefix := range prefixes {
+		local.Static(prefix, filepath.Join(localOption.RootPath, prefix))
+	}
+
+	releaseFileKeys := []string{resourceOption.WusthelperReleaseFileKey}
+	for _, key := range resourceOption.ReleaseFileKeys {
+		releaseFileKeys = append(releaseFileKeys, key)
+	}
+	served := make(map[string]bool, len(releaseFileKeys))
+	for _, key := range releaseFileKeys {
+		key = strings.Trim(key, "/")
+		if key == "" || served[key] || underStoragePrefix(key, prefixes...) {
+			continue
+		}
+		served[key] = true
+		local.StaticFile(key, filepath.Join(localOption.RootPath, key))
+	}
+}
+
+// denyStoragePrefix 拒绝访问存储中某个前缀下的对象，前缀为空时不拒绝
+func denyStoragePrefix(servePath, prefix string) gin.HandlerFunc {
+	servePath, prefix = path.Clean("/"+servePath), strings.Trim(prefix, "/")
+	return func(ctx *gin.Context) {
+		if prefix == "" {
+			return
+		}
+
+		key := strings.Trim(strings.TrimPrefix(path.Clean(ctx.Request.URL.Path), servePath), "/")
+		if underStoragePrefix(key, prefix) {
+			ctx.AbortWithStatus(http.StatusNotFound)
+		}
+	}
+}
+
+// underStoragePrefix key是否为某个前缀或在其之下
+func underStoragePrefix(key string, prefixes ...string) bool {
+	for _, prefix := range prefixes {
+		if key == prefix || strings.HasPrefix(key, prefix+"/") {
+			return true
+		}
+	}
+
+	return false
+}
+
 func setupAdminRouter(rootRouter *gin.RouterGroup) {
 	admin := rootRouter.Group("/admin")
 	{
@@ -63,6 +139,8 @@ func setupAdminRouter(rootRouter *gin.RouterGroup) {
 			banner.PATCH("/chAct", modifyBanner)      // 修改活动
 			banner.GET("/getActs", getBannerList)     // 查询活动
 			banner.POST("/publishAct", publishBanner) // 发布活动
+			banner.POST("/reorderAct", reorderBanner) // 拖拽排序活动
+			banner.GET("/stat", getBannerStat)        // 活动展示和点击统计
 		}
 
 		// 管理员日志相关
@@ -143,6 +221,67 @@ func setupAdminRouter(rootRouter *gin.RouterGroup) {
 			versionConfigure.PUT("/add", addVersion)
 			versionConfigure.DELETE("/delete", deleteVersion)
 			versionConfigure.POST("/publish", publishVersion)
+			versionConfigure.GET("/policy", getVersionPolicyList)
+			versionConfigure.POST("/policy", setVersionPolicy)
+			versionConfigure.POST("/rollout", rolloutVersion)
+			versionConfigure.POST("/rollout/pause", pauseVersionRollout)
+			versionConfigure.POST("/rollout/resume", resumeVersionRollout)
+			versionConfigure.POST("/rollout/stop", stopVersionRollout)
+			versionConfigure.GET("/history", getVersionPublishHistoryList)
+			versionConfigure.POST("/rollback", rollbackVersion)
+			versionConfigure.GET("/tester", getVersionTesterList)
+			versionConfigure.POST("/tester", addVersionTester)
+			versionConfigure.DELETE("/tester", deleteVersionTester)
+			versionConfigure.GET("/download/stat", getDownloadStat)
+			versionConfigure.GET("/artifact", getVersionArtifactList)
+			versionConfigure.PUT("/artifact", addVersionArtifact)
+			versionConfigure.DELETE("/artifact", deleteVersionArtifact)
+		}
+
+		// 热更新资源包
+		bundle := admin.Group("/bundle", auth.AdminUserTokenCheck)
+		{
+			bundle.GET("", getBundleList)
+			bundle.PUT("", addBundle)
+			bundle.POST("/publish", publishBundle)
+			bundle.POST("/offline", offlineBundle)
+			bundle.DELETE("", deleteBundle)
+		}
+
+		// 轮播图和公告的A/B实验
+		experiment := admin.Group("/experiment", auth.AdminUserTokenCheck)
+		{
+			experiment.GET("", getExperimentList)
+			experiment.PUT("", addExperiment)
+			experiment.PATCH("", modifyExperiment)
+			experiment.POST("/start", startExperiment)
+			experiment.POST("/stop", stopExperiment)
+			experiment.DELETE("", deleteExperiment)
+			experiment.GET("/report", getExperimentReport)
+		}
+
+		// 清理存储中没有被引用的对象，GET只生成报告，POST实际清理
+		storageGc := admin.Group("/storage/gc", auth.AdminUserTokenCheck)
+		{
+			storageGc.GET("", getStorageGcReport)
+			storageGc.POST("", runStorageGc)
+		}
+
+		// 大文件分片上传，完成后的uploadId可以代替文件用在版本和活动接口中
+		upload := admin.Group("/upload", auth.AdminUserTokenCheck)
+		{
+			upload.POST("/init", initUpload)
+			upload.GET("/status", getUploadStatus)
+			upload.PUT("/part", uploadPart)
+			upload.POST("/complete", completeUpload)
+			upload.DELETE("/abort", abortUpload)
+		}
+
+		// 后台任务，文件上传等失败的任务可以在这里查看和手动重试
+		job := admin.Group("/job", auth.AdminUserTokenCheck)
+		{
+			job.GET("/list", getJobList)
+			job.POST("/retry", retryJob)
 		}
 
 		// 接口已弃用
@@ -180,6 +319,9 @@ func setupPublicApiRouter(rootRouter *gin.RouterGroup) {
 		joinUs.GET("/send")
 	}
 
+	// 轮播图短链接，记录点击后跳转
+	rootRouter.GET("/s/:code", redirectBannerShortLink)
+
 	wusthelper := rootRouter.Group("/wusthelper")
 	{
 		wusthelper.GET("/notice", getPublishedAnnouncement)
@@ -187,5 +329,13 @@ func setupPublicApiRouter(rootRouter *gin.RouterGroup) {
 		wusthelper.GET("/config", getConfigListPublic)
 		wusthelper.GET("/log", getPublishedLogList)
 		wusthelper.GET("/version", getLatestVersion)
+		wusthelper.GET("/version/check", checkVersion)
+		wusthelper.GET("/download/:platform", downloadLatestVersion)
+		wusthelper.GET("/download/version/:id", downloadVersion)
+		wusthelper.GET("/download/artifact/:id", downloadVersionArtifact)
+		wusthelper.GET("/landing/:platform", getLandingPage)
+		wusthelper.GET("/landing/:platform/qrcode", getLandingQrcode)
+		wusthelper.GET("/bundle", getBundleUpdate)
+		wusthelper.POST("/experiment/click", recordExperimentClick)
 	}
 }
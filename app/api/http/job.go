@@ -0,0 +1,128 @@
+package http
+
+import (
+	"github.com/gin-gonic/gin"
+	"wusthelper-manager-go/app/model"
+	"wusthelper-manager-go/common"
+	"wusthelper-manager-go/library/ecode"
+)
+
+const (
+	_jobStatusPending   = "pending"
+	_jobStatusRunning   = "running"
+	_jobStatusSucceeded = "succeeded"
+	_jobStatusFailed    = "failed"
+)
+
+type JobResp struct {
+	Id          int64  `json:"id"`
+	Type        string `json:"type"`
+	Payload     string `json:"payload"`
+	Status      string `json:"status"`
+	Attempts    int    `json:"attempts"`
+	MaxAttempts int    `json:"maxAttempts"`
+	LastError   string `json:"lastError"`
+	NextRunTime string `json:"nextRunTime"`
+	CreateTime  string `json:"createTime"`
+	UpdateTime  string `json:"updateTime"`
+}
+
+type JobListReq struct {
+	Page   int    `json:"page,default=1" form:"page,default=1" query:"page,default=1"`
+	Size   int    `json:"size,default=10" form:"size,default=10" query:"size,default=10"`
+	Status string `json:"status" form:"status" query:"status"`
+	Type   string `json:"type" form:"type" query:"type"`
+}
+
+func getJobList(c *gin.Context) {
+	req := new(JobListReq)
+	if err := c.ShouldBind(req); err != nil {
+		responseEcode(c, ecode.ParamWrong)
+		return
+	}
+
+	var status *int8
+	if req.Status != "" {
+		s, ok := _apiDefineJobStatus2InternalStatus(req.Status)
+		if !ok {
+			responseEcode(c, ecode.ParamWrong)
+			return
+		}
+		status = &s
+	}
+
+	jobList, total, err := srv.GetJobList(common.Pagination{Page: req.Page, PageSize: req.Size}, status, req.Type)
+	if err != nil {
+		responseEcode(c, err)
+		return
+	}
+
+	resultList := make([]JobResp, len(*jobList))
+	for i, job := range *jobList {
+		resultList[i] = JobResp{
+			Id:          job.ID,
+			Type:        *job.Type,
+			Payload:     *job.Payload,
+			Status:      _internalJobStatus2ApiDefineStatus(*job.Status),
+			Attempts:    *job.Attempts,
+			MaxAttempts: *job.MaxAttempts,
+			LastError:   *job.LastError,
+			NextRunTime: job.NextRunTime.Format(_defaultDateTimeFormat),
+			CreateTime:  job.CreateTime.Format(_defaultDateTimeFormat),
+			UpdateTime:  job.UpdateTime.Format(_defaultDateTimeFormat),
+		}
+	}
+
+	responseData(c, map[string]any{
+		"jobs": resultList,
+		"num":  total,
+	})
+}
+
+func _internalJobStatus2ApiDefineStatus(internalStatus int8) string {
+	switch internalStatus {
+	case model.JobRunningStatus:
+		return _jobStatusRunning
+	case model.JobSucceededStatus:
+		return _jobStatusSucceeded
+	case model.JobFailedStatus:
+		return _jobStatusFailed
+	default:
+		return _jobStatusPending
+	}
+}
+
+func _apiDefineJobStatus2InternalStatus(status string) (int8, bool) {
+	switch status {
+	case _jobStatusPending:
+		return model.JobPendingStatus, true
+	case _jobStatusRunning:
+		return model.JobRunningStatus, true
+	case _jobStatusSucceeded:
+		return model.JobSucceededStatus, true
+	case _jobStatusFailed:
+		return model.JobFailedStatus, true
+	default:
+		return 0, false
+	}
+}
+
+type JobRetryReq struct {
+	Id int64 `json:"id" form:"id" binding:"required"`
+}
+
+func retryJob(c *gin.Context) {
+	req := new(JobRetryReq)
+	if err := c.ShouldBind(req); err != nil {
+		responseEcode(c, ecode.ParamWrong)
+		return
+	}
+
+	err := srv.RetryJob(req.Id)
+	if err != nil {
+		responseEcode(c, err)
+		return
+	}
+
+	responseData(c, nil)
+}
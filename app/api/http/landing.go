@@ -0,0 +1,145 @@
+package http
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"github.com/dustin/go-humanize"
+	"github.com/gin-gonic/gin"
+	"github.com/skip2/go-qrcode"
+	"go.uber.org/zap"
+	"html/template"
+	"net/http"
+	"net/url"
+	"strings"
+	"wusthelper-manager-go/library/ecode"
+	"wusthelper-manager-go/library/log"
+)
+
+const (
+	_defaultQrcodeSize = 512
+	_minQrcodeSize     = 128
+	_maxQrcodeSize     = 2048
+)
+
+//go:embed template/landing.html
+var templateFS embed.FS
+
+var landingTemplate = template.Must(template.ParseFS(templateFS, "template/landing.html"))
+
+var _platformNames = map[string]string{
+	_platformAndroid: "Android",
+	_platformIos:     "iOS",
+	_platformMp:      "小程序",
+}
+
+type LandingReq struct {
+	Platform string `uri:"platform" binding:"required"`
+}
+
+type landingPageData struct {
+	PlatformName string
+	Version      string
+	Summary      string
+	Size         string
+	PublishDate  string
+	DownloadUrl  string
+	QrcodeUrl    string
+}
+
+// getLandingPage 下载落地页，展示平台稳定渠道的最新版本，下载走 downloadLatestVersion 以便统计
+func getLandingPage(c *gin.Context) {
+	req := new(LandingReq)
+	if err := c.ShouldBindUri(req); err != nil {
+		responseEcode(c, ecode.ParamWrong)
+		return
+	}
+
+	version, err := srv.GetLatestVersion(req.Platform)
+	if err != nil {
+		responseEcode(c, err)
+		return
+	}
+
+	platformName, ok := _platformNames[req.Platform]
+	if !ok {
+		platformName = req.Platform
+	}
+
+	// 用相对地址，不需要关心服务部署在哪个路径下
+	data := landingPageData{
+		PlatformName: platformName,
+		DownloadUrl:  fmt.Sprintf("../download/%s", url.PathEscape(req.Platform)),
+		QrcodeUrl:    fmt.Sprintf("%s/qrcode", url.PathEscape(req.Platform)),
+	}
+	if version != nil && version.File != nil && *version.File != "" {
+		data.Version = *version.VersionText
+		data.Summary = _deref(version.Summary)
+		if version.UpdateTime != nil {
+			data.PublishDate = version.UpdateTime.Format(_defaultDateFormat)
+		}
+		if size := _deref(version.FileSize); size > 0 {
+			data.Size = humanize.IBytes(uint64(size))
+		}
+	}
+
+	buf := new(bytes.Buffer)
+	if err = landingTemplate.Execute(buf, data); err != nil {
+		log.Error("渲染下载落地页时出现错误", zap.String("platform", req.Platform), zap.Error(err))
+		responseEcode(c, ecode.InternalError)
+		return
+	}
+
+	c.Data(http.StatusOK, "text/html; charset=utf-8", buf.Bytes())
+}
+
+type LandingQrcodeReq struct {
+	Platform string `uri:"platform" binding:"required"`
+	Size     int    `form:"size"` // 图片边长，单位像素，默认512
+}
+
+// getLandingQrcode 落地页地址的二维码png，用于印在海报上
+func getLandingQrcode(c *gin.Context) {
+	req := new(LandingQrcodeReq)
+	if err := c.ShouldBindUri(req); err != nil {
+		responseEcode(c, ecode.ParamWrong)
+		return
+	}
+	if err := c.ShouldBindQuery(req); err != nil {
+		responseEcode(c, ecode.ParamWrong)
+		return
+	}
+
+	size := req.Size
+	if size == 0 {
+		size = _defaultQrcodeSize
+	} else if size < _minQrcodeSize || size > _maxQrcodeSize {
+		responseEcode(c, ecode.ParamWrong)
+		return
+	}
+
+	pageUrl, configured := _landingPageUrl(c, req.Platform)
+	png, err := qrcode.Encode(pageUrl, qrcode.Medium, size)
+	if err != nil {
+		log.Error("生成落地页二维码时出现错误", zap.String("platform", req.Platform), zap.Error(err))
+		responseEcode(c, ecode.InternalError)
+		return
+	}
+
+	// 根据请求推断的地址随请求头变化，不能让共享缓存存下来给其他人用
+	if configured {
+		c.Header("Cache-Control", "public, max-age=86400")
+	} else {
+		c.Header("Cache-Control", "private, max-age=86400")
+	}
+	c.Data(http.StatusOK, "image/png", png)
+}
+
+// _landingPageUrl 落地页的完整地址，优先使用配置，没有配置时根据请求地址推断，configured表示是否使用的配置
+func _landingPageUrl(c *gin.Context, platform string) (pageUrl string, configured bool) {
+	if pageUrl = config.Server.VersionOption.LandingPageUrl; pageUrl != "" {
+		return fmt.Sprintf(pageUrl, url.PathEscape(platform)), true
+	}
+
+	return _requestOrigin(c) + strings.TrimSuffix(c.Request.URL.Path, "/qrcode"), false
+}
@@ -0,0 +1,69 @@
+package http
+
+import (
+	"github.com/gin-gonic/gin"
+	"wusthelper-manager-go/app/service"
+)
+
+type StorageGcObjectResp struct {
+	Key           string `json:"key"`
+	Size          int64  `json:"size"`
+	LastModified  string `json:"lastModified"`
+	FirstSeenTime string `json:"firstSeenTime"`
+	Expired       bool   `json:"expired"` // 已超过宽限期，清理时会被删除或移动
+	Collected     bool   `json:"collected"`
+}
+
+type StorageGcResp struct {
+	DryRun    bool                  `json:"dryRun"`
+	Scanned   int                   `json:"scanned"`
+	Collected int                   `json:"collected"`
+	Failed    int                   `json:"failed"`
+	Orphans   []StorageGcObjectResp `json:"orphans"`
+	Num       int                   `json:"num"`
+}
+
+func _toStorageGcResp(result *service.StorageGcResult) StorageGcResp {
+	orphans := make([]StorageGcObjectResp, len(result.Orphans))
+	for i, object := range result.Orphans {
+		orphans[i] = StorageGcObjectResp{
+			Key:           *object.Orphan.ObjectKey,
+			Size:          _deref(object.Orphan.Size),
+			LastModified:  object.Orphan.LastModified.Format(_defaultDateTimeFormat),
+			FirstSeenTime: object.Orphan.FirstSeenTime.Format(_defaultDateTimeFormat),
+			Expired:       object.Expired,
+			Collected:     object.Collected,
+		}
+	}
+
+	return StorageGcResp{
+		DryRun:    result.DryRun,
+		Scanned:   result.Scanned,
+		Collected: result.Collected,
+		Failed:    result.Failed,
+		Orphans:   orphans,
+		Num:       len(orphans),
+	}
+}
+
+// getStorageGcReport 扫描存储并报告没有被引用的对象，不实际清理，第一次扫描到的对象从此时开始计算宽限期
+func getStorageGcReport(c *gin.Context) {
+	result, err := srv.RunStorageGc(true)
+	if err != nil {
+		responseEcode(c, err)
+		return
+	}
+
+	responseData(c, _toStorageGcResp(result))
+}
+
+// runStorageGc 扫描存储，并删除或归档超过宽限期的无引用对象
+func runStorageGc(c *gin.Context) {
+	result, err := srv.RunStorageGc(false)
+	if err != nil {
+		responseEcode(c, err)
+		return
+	}
+
+	responseData(c, _toStorageGcResp(result))
+}
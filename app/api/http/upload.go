@@ -0,0 +1,132 @@
+package http
+
+import (
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"wusthelper-manager-go/app/model"
+	"wusthelper-manager-go/app/service"
+	"wusthelper-manager-go/library/ecode"
+)
+
+type UploadSessionResp struct {
+	UploadId      string `json:"uploadId"`
+	FileName      string `json:"fileName"`
+	Size          int64  `json:"size"`
+	PartSize      int64  `json:"partSize"`
+	PartCount     int    `json:"partCount"`
+	UploadedParts []int  `json:"uploadedParts"`
+	Completed     bool   `json:"completed"`
+}
+
+func _toUploadSessionResp(session *model.UploadSession, uploadedParts []int) UploadSessionResp {
+	if uploadedParts == nil {
+		uploadedParts = []int{}
+	}
+
+	return UploadSessionResp{
+		UploadId:      session.Id,
+		FileName:      session.FileName,
+		Size:          session.Size,
+		PartSize:      session.PartSize,
+		PartCount:     session.PartCount,
+		UploadedParts: uploadedParts,
+		Completed:     session.Completed,
+	}
+}
+
+type UploadInitReq struct {
+	FileName string `json:"fileName" binding:"required"`
+	Size     int64  `json:"size" binding:"required"`
+	PartSize int64  `json:"partSize"`
+}
+
+func initUpload(c *gin.Context) {
+	req := new(UploadInitReq)
+	if err := c.ShouldBindJSON(req); err != nil {
+		responseEcode(c, ecode.ParamWrong)
+		return
+	}
+
+	session, err := srv.InitUpload(req.FileName, req.Size, req.PartSize)
+	if err != nil {
+		responseEcode(c, err)
+		return
+	}
+
+	responseData(c, _toUploadSessionResp(session, nil))
+}
+
+type UploadIdReq struct {
+	UploadId string `json:"uploadId" form:"uploadId" binding:"required"`
+}
+
+func getUploadStatus(c *gin.Context) {
+	req := new(UploadIdReq)
+	if err := c.ShouldBindQuery(req); err != nil {
+		responseEcode(c, ecode.ParamWrong)
+		return
+	}
+
+	session, parts, err := srv.GetUploadStatus(req.UploadId)
+	if err != nil {
+		responseEcode(c, err)
+		return
+	}
+
+	responseData(c, _toUploadSessionResp(session, parts))
+}
+
+type UploadPartReq struct {
+	UploadId   string `form:"uploadId" binding:"required"`
+	PartNumber int    `form:"partNumber" binding:"required"`
+}
+
+// uploadPart 分片内容为请求体的原始数据，uploadId和partNumber通过query传递
+func uploadPart(c *gin.Context) {
+	req := new(UploadPartReq)
+	if err := c.ShouldBindQuery(req); err != nil {
+		responseEcode(c, ecode.ParamWrong)
+		return
+	}
+
+	body := http.MaxBytesReader(c.Writer, c.Request.Body, service.MaxUploadPartSize+1)
+	err := srv.UploadPart(req.UploadId, req.PartNumber, body)
+	if err != nil {
+		responseEcode(c, err)
+		return
+	}
+
+	responseData(c, nil)
+}
+
+func completeUpload(c *gin.Context) {
+	req := new(UploadIdReq)
+	if err := c.ShouldBindJSON(req); err != nil {
+		responseEcode(c, ecode.ParamWrong)
+		return
+	}
+
+	session, err := srv.CompleteUpload(req.UploadId)
+	if err != nil {
+		responseEcode(c, err)
+		return
+	}
+
+	responseData(c, _toUploadSessionResp(session, nil))
+}
+
+func abortUpload(c *gin.Context) {
+	req := new(UploadIdReq)
+	if err := c.ShouldBind(req); err != nil {
+		responseEcode(c, ecode.ParamWrong)
+		return
+	}
+
+	err := srv.AbortUpload(req.UploadId)
+	if err != nil {
+		responseEcode(c, err)
+		return
+	}
+
+	responseData(c, nil)
+}
@@ -17,8 +17,62 @@ type LatestVersionResp struct {
 	Version       string `json:"version"`
 	UpdateContent string `json:"updateContent"`
 	ApkUrl        string `json:"apkUrl"`
+	Size          int64  `json:"size"`
+	Sha256        string `json:"sha256"`
+	Mandatory     bool   `json:"mandatory"`
+
+	Patch *VersionPatchResp `json:"patch,omitempty"` // 从客户端当前版本升级的增量包，没有时不返回
+
+	ArtifactType string   `json:"artifactType,omitempty"` // 按客户端ABI选中的文件类型，使用主文件时不返回
+	Mirrors      []string `json:"mirrors,omitempty"`
+	InstallUrl   string   `json:"installUrl,omitempty"` // ios的itms-services安装地址，没有manifest.plist时不返回
+}
+
+type VersionPatchResp struct {
+	Url         string `json:"url"`
+	Size        int64  `json:"size"`
+	Sha256      string `json:"sha256"`
+	BaseVersion string `json:"baseVersion"` // 增量包需要打在这个版本的安装包上
+	BaseSha256  string `json:"baseSha256"`
 }
 
+func _toVersionPatchResp(patch *model.VersionPatch) *VersionPatchResp {
+	if patch == nil {
+		return nil
+	}
+
+	return &VersionPatchResp{
+		Url:         _getFileUrl(*patch.File),
+		Size:        *patch.FileSize,
+		Sha256:      *patch.FileSha256,
+		BaseVersion: *patch.BaseVersionText,
+		BaseSha256:  *patch.BaseSha256,
+	}
+}
+
+// _toLatestVersionResp 下载地址为计数下载的地址（见 downloadVersion）
+func _toLatestVersionResp(c *gin.Context, version *model.Version) *LatestVersionResp {
+	if version == nil {
+		return nil
+	}
+
+	apkUrl := ""
+	if _deref(version.File) != "" {
+		apkUrl = _versionDownloadUrl(c, version.ID)
+	}
+
+	return &LatestVersionResp{
+		Version:       *version.VersionText,
+		UpdateContent: *version.Summary,
+		ApkUrl:        apkUrl,
+		Size:          _deref(version.FileSize),
+		Sha256:        _deref(version.FileSha256),
+		Mandatory:     _deref(version.Mandatory),
+	}
+}
+
+// getLatestVersion 获取最新版本，客户端带上当前版本号时（见 getClientVersion），有对应的增量包会一起返回，
+// 版本有多个下载文件时按客户端的ABI（见 getClientAbis）选择
 func getLatestVersion(c *gin.Context) {
 	platform := getPlatform(c)
 	if platform == "" {
@@ -26,29 +80,83 @@ func getLatestVersion(c *gin.Context) {
 		return
 	}
 
-	result, err := srv.GetLatestVersion(platform)
+	result, err := srv.GetLatestVersionForClient(platform, getVersionClient(c))
+	if err != nil {
+		responseEcode(c, err)
+		return
+	}
+
+	patch, err := srv.GetVersionPatch(result, getClientVersion(c))
 	if err != nil {
 		responseEcode(c, err)
 		return
 	}
 
-	resp := LatestVersionResp{
-		Version:       *result.VersionText,
-		UpdateContent: *result.Summary,
-		ApkUrl:        _getFileUrl(*result.File),
+	artifact, err := srv.SelectVersionArtifact(result, getClientAbis(c))
+	if err != nil {
+		responseEcode(c, err)
+		return
+	}
+
+	resp := _toLatestVersionResp(c, result)
+	if resp != nil {
+		resp.Patch = _toVersionPatchResp(patch)
+		if artifact != nil && *artifact.Type == model.VersionArtifactIosManifest {
+			// manifest.plist不是安装包本身，apkUrl仍然是主文件
+			resp.ArtifactType, resp.InstallUrl = *artifact.Type, _itmsServicesUrl(_getArtifactUrl(artifact))
+		} else if artifact != nil {
+			resp.ApkUrl, resp.Size, resp.Sha256 = _artifactDownloadUrl(c, artifact.ID), *artifact.FileSize, *artifact.FileSha256
+			resp.ArtifactType, resp.Mirrors = *artifact.Type, artifact.Mirrors
+		}
 	}
 
 	responseData(c, resp)
 }
 
 type VersionInfoResp struct {
-	Id            int64  `json:"id"`
-	Version       string `json:"version"`
-	UpdateContent string `json:"updateContent"`
-	ApkUrl        string `json:"apkUrl"`
-	Status        int8   `json:"status"`
-	Platform      string `json:"platform"`
-	CreateTime    string `json:"createTime"`
+	Id             int64  `json:"id"`
+	Version        string `json:"version"`
+	UpdateContent  string `json:"updateContent"`
+	ApkUrl         string `json:"apkUrl"`
+	Status         int8   `json:"status"`
+	Platform       string `json:"platform"`
+	Channel        string `json:"channel"`
+	PackageName    string `json:"packageName"`
+	VersionCode    int32  `json:"versionCode"`
+	MinSdk         int32  `json:"minSdk"`
+	Size           int64  `json:"size"`
+	Sha256         string `json:"sha256"`
+	Mandatory      bool   `json:"mandatory"`
+	RolloutPercent int    `json:"rolloutPercent"`
+	RolloutPaused  bool   `json:"rolloutPaused"`
+	CreateTime     string `json:"createTime"`
+}
+
+// _toVersionInfoResp 已发布版本的下载地址为计数下载的地址，草稿等客户端不可见的版本通过计数下载的地址无法下载，仍为文件地址
+func _toVersionInfoResp(c *gin.Context, version *model.Version) VersionInfoResp {
+	apkUrl := _getFileUrl(_deref(version.File))
+	if apkUrl != "" && *version.Status == model.VersionPublishedStatus {
+		apkUrl = _versionDownloadUrl(c, version.ID)
+	}
+
+	return VersionInfoResp{
+		Id:             version.ID,
+		Version:        *version.VersionText,
+		UpdateContent:  *version.Summary,
+		ApkUrl:         apkUrl,
+		Status:         _internalVersionStatus2ApiDefineStatus(*version.Status),
+		Platform:       *version.Platform,
+		Channel:        _deref(version.Channel),
+		PackageName:    _deref(version.PackageName),
+		VersionCode:    _deref(version.VersionCode),
+		MinSdk:         _deref(version.MinSdk),
+		Size:           _deref(version.FileSize),
+		Sha256:         _deref(version.FileSha256),
+		Mandatory:      _deref(version.Mandatory),
+		RolloutPercent: _deref(version.RolloutPercent),
+		RolloutPaused:  _deref(version.RolloutPaused),
+		CreateTime:     version.CreateTime.Format(_defaultDateTimeFormat),
+	}
 }
 
 func getVersionList(c *gin.Context) {
@@ -67,15 +175,7 @@ func getVersionList(c *gin.Context) {
 
 	resultList := make([]VersionInfoResp, len(*versionList))
 	for i, version := range *versionList {
-		resultList[i] = VersionInfoResp{
-			Id:            version.ID,
-			Version:       *version.VersionText,
-			UpdateContent: *version.Summary,
-			ApkUrl:        _getFileUrl(*version.File),
-			Status:        _internalVersionStatus2ApiDefineStatus(*version.Status),
-			Platform:      *version.Platform,
-			CreateTime:    version.CreateTime.Format(_defaultDateTimeFormat),
-		}
+		resultList[i] = _toVersionInfoResp(c, &version)
 	}
 
 	responseData(c, map[string]any{
@@ -90,6 +190,8 @@ func _internalVersionStatus2ApiDefineStatus(internalStatus int8) int8 {
 		return 0
 	case model.VersionPublishedStatus:
 		return 1
+	case model.VersionRollingStatus:
+		return 2
 	default:
 		return 0
 	}
@@ -120,7 +222,10 @@ type VersionAddReq struct {
 	Version       string                `form:"version" binding:"required"`
 	UpdateContent string                `form:"updateContent" binding:"required"`
 	Platform      string                `form:"platform" binding:"required"`
+	Channel       string                `form:"channel"`   // 发布渠道，stable、beta或internal，默认为stable
+	Mandatory     bool                  `form:"mandatory"` // 是否强制更新
 	File          *multipart.FileHeader `form:"file"`
+	UploadId      string                `form:"uploadId"` // 分片上传完成的uploadId，和file二选一
 }
 
 func addVersion(c *gin.Context) {
@@ -160,12 +265,21 @@ func addVersion(c *gin.Context) {
 			Data:     &fileData,
 			FileName: fileName,
 		}
+	} else if req.UploadId != "" {
+		var err error
+		uploadFile, err = srv.GetUploadedFile(req.UploadId)
+		if err != nil {
+			responseEcode(c, err)
+			return
+		}
 	}
 
 	version := service.VersionAddParam{
 		Version:    req.Version,
 		Summary:    req.UpdateContent,
 		Platform:   req.Platform,
+		Channel:    req.Channel,
+		Mandatory:  req.Mandatory,
 		UploadFile: uploadFile,
 	}
 
@@ -183,7 +297,10 @@ type VersionModifyReq struct {
 	Version       *string               `form:"version"`
 	UpdateContent *string               `form:"updateContent"`
 	Platform      *string               `form:"platform"`
+	Channel       *string               `form:"channel"`
+	Mandatory     *bool                 `form:"mandatory"`
 	File          *multipart.FileHeader `form:"file"`
+	UploadId      string                `form:"uploadId"` // 分片上传完成的uploadId，和file二选一
 }
 
 func modifyVersion(c *gin.Context) {
@@ -219,6 +336,13 @@ func modifyVersion(c *gin.Context) {
 			Data:     &fileData,
 			FileName: fileName,
 		}
+	} else if req.UploadId != "" {
+		var err error
+		uploadFile, err = srv.GetUploadedFile(req.UploadId)
+		if err != nil {
+			responseEcode(c, err)
+			return
+		}
 	}
 
 	version := service.VersionModifyParam{
@@ -226,6 +350,8 @@ func modifyVersion(c *gin.Context) {
 		Version:    req.Version,
 		Summary:    req.UpdateContent,
 		Platform:   req.Platform,
+		Channel:    req.Channel,
+		Mandatory:  req.Mandatory,
 		UploadFile: uploadFile,
 	}
 
@@ -0,0 +1,163 @@
+package http
+
+import (
+	"github.com/dustin/go-humanize"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"io"
+	"mime/multipart"
+	"net/url"
+	"wusthelper-manager-go/app/model"
+	"wusthelper-manager-go/app/service"
+	"wusthelper-manager-go/library/ecode"
+	"wusthelper-manager-go/library/log"
+)
+
+type VersionArtifactResp struct {
+	Id         int64    `json:"id"`
+	VersionId  int64    `json:"versionId"`
+	Type       string   `json:"type"`
+	Url        string   `json:"url"`
+	Size       int64    `json:"size"`
+	Sha256     string   `json:"sha256"`
+	Mirrors    []string `json:"mirrors"`
+	CreateTime string   `json:"createTime"`
+}
+
+// _getArtifactUrl 有文件时为文件地址，否则为外部链接
+func _getArtifactUrl(artifact *model.VersionArtifact) string {
+	if file := _deref(artifact.File); file != "" {
+		return _getFileUrl(file)
+	}
+
+	return _deref(artifact.Url)
+}
+
+// _itmsServicesUrl ios设备上点击即可安装的地址，manifestUrl需要是https地址
+func _itmsServicesUrl(manifestUrl string) string {
+	return "itms-services://?action=download-manifest&url=" + url.QueryEscape(manifestUrl)
+}
+
+type VersionArtifactListReq struct {
+	VersionId int64 `form:"versionId" binding:"required"`
+}
+
+func getVersionArtifactList(c *gin.Context) {
+	req := new(VersionArtifactListReq)
+	if err := c.ShouldBind(req); err != nil {
+		responseEcode(c, ecode.ParamWrong)
+		return
+	}
+
+	artifactList, err := srv.GetVersionArtifactList(req.VersionId)
+	if err != nil {
+		responseEcode(c, err)
+		return
+	}
+
+	resultList := make([]VersionArtifactResp, len(*artifactList))
+	for i, artifact := range *artifactList {
+		mirrors := artifact.Mirrors
+		if mirrors == nil {
+			mirrors = []string{}
+		}
+
+		resultList[i] = VersionArtifactResp{
+			Id:         artifact.ID,
+			VersionId:  *artifact.VersionId,
+			Type:       *artifact.Type,
+			Url:        _getArtifactUrl(&artifact),
+			Size:       _deref(artifact.FileSize),
+			Sha256:     _deref(artifact.FileSha256),
+			Mirrors:    mirrors,
+			CreateTime: artifact.CreateTime.Format(_defaultDateTimeFormat),
+		}
+	}
+
+	responseData(c, resultList)
+}
+
+type VersionArtifactAddReq struct {
+	VersionId int64                 `form:"versionId" binding:"required"`
+	Type      string                `form:"type" binding:"required"` // 见 model.VersionArtifactXxx
+	Url       string                `form:"url"`                     // store_link类型的外部链接，只能是http(s)或应用商店链接
+	Mirrors   []string              `form:"mirrors"`                 // 镜像下载地址，可以有多个
+	File      *multipart.FileHeader `form:"file"`
+	UploadId  string                `form:"uploadId"` // 分片上传完成的uploadId，和file二选一
+}
+
+func addVersionArtifact(c *gin.Context) {
+	req := new(VersionArtifactAddReq)
+	if err := c.ShouldBind(req); err != nil {
+		responseEcode(c, ecode.ParamWrong)
+		return
+	}
+
+	var uploadFile *service.File = nil
+	if req.File != nil {
+		// 限制文件100mb以内
+		if req.File.Size > 100*humanize.MByte {
+			responseEcode(c, ecode.VersionOperationFailed)
+			return
+		}
+
+		file, err := req.File.Open()
+		if err != nil {
+			responseEcode(c, ecode.InternalError)
+			return
+		}
+
+		fileData, err := io.ReadAll(file)
+		if err != nil {
+			log.Error("读取上传文件时出现错误", zap.Error(err))
+			responseEcode(c, ecode.InternalError)
+			return
+		}
+
+		uploadFile = &service.File{
+			Data:     &fileData,
+			FileName: req.File.Filename,
+		}
+	} else if req.UploadId != "" {
+		var err error
+		uploadFile, err = srv.GetUploadedFile(req.UploadId)
+		if err != nil {
+			responseEcode(c, err)
+			return
+		}
+	}
+
+	err := srv.AddVersionArtifact(&service.VersionArtifactAddParam{
+		VersionId:  req.VersionId,
+		Type:       req.Type,
+		Url:        req.Url,
+		Mirrors:    req.Mirrors,
+		UploadFile: uploadFile,
+	})
+	if err != nil {
+		responseEcode(c, err)
+		return
+	}
+
+	responseData(c, nil)
+}
+
+type VersionArtifactDeleteReq struct {
+	Id int64 `form:"id" binding:"required"`
+}
+
+func deleteVersionArtifact(c *gin.Context) {
+	req := new(VersionArtifactDeleteReq)
+	if err := c.ShouldBind(req); err != nil {
+		responseEcode(c, ecode.ParamWrong)
+		return
+	}
+
+	err := srv.DeleteVersionArtifact(req.Id)
+	if err != nil {
+		responseEcode(c, err)
+		return
+	}
+
+	responseData(c, nil)
+}
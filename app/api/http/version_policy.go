@@ -0,0 +1,87 @@
+package http
+
+import (
+	"github.com/gin-gonic/gin"
+	"wusthelper-manager-go/library/ecode"
+)
+
+type VersionPolicyResp struct {
+	Platform   string `json:"platform"`
+	MinVersion string `json:"minVersion"`
+	UpdateTime string `json:"updateTime"`
+}
+
+func getVersionPolicyList(c *gin.Context) {
+	policyList, err := srv.GetVersionPolicyList()
+	if err != nil {
+		responseEcode(c, err)
+		return
+	}
+
+	resultList := make([]VersionPolicyResp, len(*policyList))
+	for i, policy := range *policyList {
+		resultList[i] = VersionPolicyResp{
+			Platform:   *policy.Platform,
+			MinVersion: *policy.MinVersion,
+			UpdateTime: policy.UpdateTime.Format(_defaultDateTimeFormat),
+		}
+	}
+
+	responseData(c, resultList)
+}
+
+type VersionPolicySetReq struct {
+	Platform   string `json:"platform" form:"platform" binding:"required"`
+	MinVersion string `json:"minVersion" form:"minVersion"` // 为空时取消最低版本限制
+}
+
+func setVersionPolicy(c *gin.Context) {
+	req := new(VersionPolicySetReq)
+	if err := c.ShouldBind(req); err != nil {
+		responseEcode(c, ecode.ParamWrong)
+		return
+	}
+
+	err := srv.SetVersionPolicy(req.Platform, req.MinVersion)
+	if err != nil {
+		responseEcode(c, err)
+		return
+	}
+
+	responseData(c, nil)
+}
+
+type VersionCheckResp struct {
+	Update     string             `json:"update"` // none、optional、forced
+	Reason     string             `json:"reason"`
+	MinVersion string             `json:"minVersion"`
+	Latest     *LatestVersionResp `json:"latest"`
+}
+
+// checkVersion 客户端检查更新，当前版本可以放在header的Version或者query的version中
+func checkVersion(c *gin.Context) {
+	platform := getPlatform(c)
+	if platform == "" {
+		responseEcode(c, ecode.PlatformMissing)
+		return
+	}
+
+	currentVersion := getClientVersion(c)
+	if currentVersion == "" {
+		responseEcode(c, ecode.ParamWrong)
+		return
+	}
+
+	result, err := srv.CheckVersion(platform, currentVersion, getVersionClient(c))
+	if err != nil {
+		responseEcode(c, err)
+		return
+	}
+
+	responseData(c, VersionCheckResp{
+		Update:     result.Update,
+		Reason:     result.Reason,
+		MinVersion: result.MinVersion,
+		Latest:     _toLatestVersionResp(c, result.Latest),
+	})
+}
@@ -0,0 +1,83 @@
+package http
+
+import (
+	"github.com/gin-gonic/gin"
+	"wusthelper-manager-go/app/model"
+	"wusthelper-manager-go/common"
+	"wusthelper-manager-go/library/ecode"
+)
+
+type VersionPublishHistoryResp struct {
+	Id                int64  `json:"id"`
+	Platform          string `json:"platform"`
+	Channel           string `json:"channel"`
+	VersionId         int64  `json:"versionId"`
+	PreviousVersionId int64  `json:"previousVersionId"`
+	Action            string `json:"action"` // publish或rollback
+	CreateTime        string `json:"createTime"`
+}
+
+type VersionPublishHistoryListReq struct {
+	PlatformPaginationReq
+	Channel string `json:"channel" form:"channel" query:"channel"`
+}
+
+func getVersionPublishHistoryList(c *gin.Context) {
+	req := new(VersionPublishHistoryListReq)
+	if err := c.ShouldBind(req); err != nil {
+		responseEcode(c, ecode.ParamWrong)
+		return
+	}
+
+	pagination := common.Pagination{Page: req.Page, PageSize: req.Size}
+	historyList, total, err := srv.GetVersionPublishHistoryList(pagination, req.Platform, req.Channel)
+	if err != nil {
+		responseEcode(c, err)
+		return
+	}
+
+	resultList := make([]VersionPublishHistoryResp, len(*historyList))
+	for i, history := range *historyList {
+		resultList[i] = VersionPublishHistoryResp{
+			Id:                history.ID,
+			Platform:          *history.Platform,
+			Channel:           _deref(history.Channel),
+			VersionId:         *history.VersionId,
+			PreviousVersionId: *history.PreviousVersionId,
+			Action:            *history.Action,
+			CreateTime:        history.CreateTime.Format(_defaultDateTimeFormat),
+		}
+	}
+
+	responseData(c, map[string]any{
+		"histories": resultList,
+		"num":       total,
+	})
+}
+
+type VersionRollbackReq struct {
+	Platform string `json:"platform" form:"platform" binding:"required"`
+	Channel  string `json:"channel" form:"channel"` // 默认为stable
+}
+
+// rollbackVersion 回滚到上一次发布的版本，发布文件链接确认修改成功后才返回成功，返回恢复后的版本
+func rollbackVersion(c *gin.Context) {
+	req := new(VersionRollbackReq)
+	if err := c.ShouldBind(req); err != nil {
+		responseEcode(c, ecode.ParamWrong)
+		return
+	}
+
+	channel := req.Channel
+	if channel == "" {
+		channel = model.VersionChannelStable
+	}
+
+	version, err := srv.RollbackVersion(req.Platform, channel)
+	if err != nil {
+		responseEcode(c, err)
+		return
+	}
+
+	responseData(c, _toVersionInfoResp(c, version))
+}
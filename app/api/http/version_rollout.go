@@ -0,0 +1,79 @@
+package http
+
+import (
+	"github.com/gin-gonic/gin"
+	"wusthelper-manager-go/library/ecode"
+)
+
+type VersionRolloutReq struct {
+	Id      int64 `json:"id" form:"id" binding:"required"`
+	Percent int   `json:"percent" form:"percent" binding:"required"` // 1-100，100时转为正式发布
+}
+
+func rolloutVersion(c *gin.Context) {
+	req := new(VersionRolloutReq)
+	if err := c.ShouldBind(req); err != nil {
+		responseEcode(c, ecode.ParamWrong)
+		return
+	}
+
+	err := srv.RolloutVersion(req.Id, req.Percent)
+	if err != nil {
+		responseEcode(c, err)
+		return
+	}
+
+	responseData(c, nil)
+}
+
+type VersionRolloutIdReq struct {
+	Id int64 `json:"id" form:"id" binding:"required"`
+}
+
+func pauseVersionRollout(c *gin.Context) {
+	req := new(VersionRolloutIdReq)
+	if err := c.ShouldBind(req); err != nil {
+		responseEcode(c, ecode.ParamWrong)
+		return
+	}
+
+	err := srv.PauseVersionRollout(req.Id)
+	if err != nil {
+		responseEcode(c, err)
+		return
+	}
+
+	responseData(c, nil)
+}
+
+func resumeVersionRollout(c *gin.Context) {
+	req := new(VersionRolloutIdReq)
+	if err := c.ShouldBind(req); err != nil {
+		responseEcode(c, ecode.ParamWrong)
+		return
+	}
+
+	err := srv.ResumeVersionRollout(req.Id)
+	if err != nil {
+		responseEcode(c, err)
+		return
+	}
+
+	responseData(c, nil)
+}
+
+func stopVersionRollout(c *gin.Context) {
+	req := new(VersionRolloutIdReq)
+	if err := c.ShouldBind(req); err != nil {
+		responseEcode(c, ecode.ParamWrong)
+		return
+	}
+
+	err := srv.StopVersionRollout(req.Id)
+	if err != nil {
+		responseEcode(c, err)
+		return
+	}
+
+	responseData(c, nil)
+}
@@ -0,0 +1,93 @@
+package http
+
+import (
+	"github.com/gin-gonic/gin"
+	"wusthelper-manager-go/common"
+	"wusthelper-manager-go/library/ecode"
+)
+
+type VersionTesterResp struct {
+	Id         int64  `json:"id"`
+	Identifier string `json:"identifier"`
+	Channel    string `json:"channel"`
+	Remark     string `json:"remark"`
+	CreateTime string `json:"createTime"`
+}
+
+type VersionTesterListReq struct {
+	Page       int    `json:"page,default=1" form:"page,default=1" query:"page,default=1"`
+	Size       int    `json:"size,default=10" form:"size,default=10" query:"size,default=10"`
+	Identifier string `json:"identifier" form:"identifier" query:"identifier"`
+}
+
+func getVersionTesterList(c *gin.Context) {
+	req := new(VersionTesterListReq)
+	if err := c.ShouldBind(req); err != nil {
+		responseEcode(c, ecode.ParamWrong)
+		return
+	}
+
+	testerList, total, err := srv.GetVersionTesterList(common.Pagination{Page: req.Page, PageSize: req.Size}, req.Identifier)
+	if err != nil {
+		responseEcode(c, err)
+		return
+	}
+
+	resultList := make([]VersionTesterResp, len(*testerList))
+	for i, tester := range *testerList {
+		resultList[i] = VersionTesterResp{
+			Id:         tester.ID,
+			Identifier: *tester.Identifier,
+			Channel:    *tester.Channel,
+			Remark:     _deref(tester.Remark),
+			CreateTime: tester.CreateTime.Format(_defaultDateTimeFormat),
+		}
+	}
+
+	responseData(c, map[string]any{
+		"testers": resultList,
+		"num":     total,
+	})
+}
+
+type VersionTesterAddReq struct {
+	Identifier string `json:"identifier" form:"identifier" binding:"required"` // 学号或设备id
+	Channel    string `json:"channel" form:"channel" binding:"required"`       // beta或internal
+	Remark     string `json:"remark" form:"remark"`
+}
+
+func addVersionTester(c *gin.Context) {
+	req := new(VersionTesterAddReq)
+	if err := c.ShouldBind(req); err != nil {
+		responseEcode(c, ecode.ParamWrong)
+		return
+	}
+
+	err := srv.AddVersionTester(req.Identifier, req.Channel, req.Remark)
+	if err != nil {
+		responseEcode(c, err)
+		return
+	}
+
+	responseData(c, nil)
+}
+
+type VersionTesterDeleteReq struct {
+	Id int64 `json:"id" form:"id" binding:"required"`
+}
+
+func deleteVersionTester(c *gin.Context) {
+	req := new(VersionTesterDeleteReq)
+	if err := c.ShouldBind(req); err != nil {
+		responseEcode(c, ecode.ParamWrong)
+		return
+	}
+
+	err := srv.DeleteVersionTester(req.Id)
+	if err != nil {
+		responseEcode(c, err)
+		return
+	}
+
+	responseData(c, nil)
+}
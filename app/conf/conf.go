@@ -7,6 +7,7 @@ import (
 	"time"
 	"wusthelper-manager-go/library/cache/redis"
 	"wusthelper-manager-go/library/database"
+	"wusthelper-manager-go/library/storage"
 )
 
 const (
@@ -33,33 +34,98 @@ type ServerConf struct {
 	TokenSecret  string
 	TokenTimeout time.Duration
 	LogLocation  string
+	// 反向代理的ip或cidr，只有来自这些地址的请求才使用X-Forwarded-*等请求头，为空时不信任任何代理
+	TrustedProxies []string
 
 	FileStorageOption FileStorageOption
+	JobOption         JobOption
+	VersionOption     VersionOption
+	StorageGcOption   StorageGcOption
+	BannerOption      BannerOption
+}
+
+// VersionOption 版本文件上传校验和增量更新配置
+type VersionOption struct {
+	AndroidPackageName string // android安装包的包名，上传的apk包名不一致时拒绝，为空时不校验
+	// android安装包签名证书的sha256指纹（十六进制，可带冒号），apk需由其中的证书签名，为空时不校验签名
+	AndroidSignerFingerprints []string
+	IosBundleId               string // ios安装包的bundle id，上传的ipa不一致时拒绝，为空时不校验
+	PatchBaseCount            int    // 上传新版本时，对最近几个已发布的版本生成增量包，为0时使用默认值，小于0时不生成
+	PatchMaxFileSize          int    // 生成增量包的安装包大小上限，单位MB，新旧版本文件有一个超过时不生成，为0时使用默认值
+	// 下载落地页的公开地址，%s为平台，用于生成二维码，为空时根据请求地址推断
+	LandingPageUrl string
+}
+
+// BannerOption 轮播图图片处理配置，上传的图片会按Widths生成多个宽度的jpeg和webp
+type BannerOption struct {
+	Widths []int // 生成的图片宽度，为空时使用默认值，大于原图宽度的不生成，原图宽度总会生成
+	// 不生成webp，只生成jpeg。没有cgo的构建不支持webp编码，不设置时服务无法启动
+	DisableWebp bool
+	// 各平台展示位对图片尺寸的要求，key依次为平台和展示位（见 model.BannerSlotXxx），没有配置的不校验
+	Slots map[string]map[string]BannerSlot
+
+	// 上传图片的宽、高和像素数上限，解码前按图片头校验，为0时使用默认值（8192、8192、4000万）
+	MaxWidth  int
+	MaxHeight int
+	MaxPixels int64
+
+	// 轮播图短链接的公开地址前缀，如 https://example.com/s，为空时根据请求地址推断
+	ShortLinkBaseUrl string
+
+	Link BannerLinkOption
+}
+
+// BannerLinkOption 轮播图链接的校验配置，为空的项不校验
+type BannerLinkOption struct {
+	Types      map[string][]string // 各平台允许的链接类型（见 model.BannerLinkTypeXxx），key为平台，没有配置的平台允许所有类型
+	UrlDomains []string            // url类型允许的域名，包括其子域名
+	MpPages    []string            // 小程序的页面路径，如 pages/index/index
+	AppRoutes  []string            // app内已注册的页面路由，如 /schedule
+}
+
+// BannerSlot 展示位对图片尺寸的要求，为0的项不校验
+type BannerSlot struct {
+	AspectRatio     float64 // 宽高比，如 2.5 表示宽是高的2.5倍
+	AspectTolerance float64 // 宽高比允许的相对误差，为0时使用默认值0.02
+	MinWidth        int
+	MinHeight       int
+}
+
+// StorageGcOption 清理存储中版本文件和图片目录下没有被数据库引用的对象
+type StorageGcOption struct {
+	Interval    time.Duration // 自动清理的间隔，单位小时，为0时不自动清理，仍可在管理端手动触发
+	GracePeriod time.Duration // 对象持续无引用超过该时长后才清理，单位小时，为0时使用默认值（7天）
+	ColdPrefix  string        // 不为空时将对象移动到该前缀下归档，否则直接删除
+	DryRun      bool          // 自动清理时只生成报告，不实际删除或移动
+}
+
+// JobOption 后台任务配置，为0时使用默认值
+type JobOption struct {
+	Workers           int           // worker数量
+	MaxAttempts       int           // 最多执行次数
+	RetryBaseInterval time.Duration // 重试的基础间隔，单位秒，第n次重试间隔为 RetryBaseInterval * 2^(n-1)
+	PollInterval      time.Duration // 没有任务时的轮询间隔，单位秒
 }
 
 type FileStorageOption struct {
 	UploadFileLocalTmpPath string
 
 	ResourceStorageOption ResourceStorageOption
-	AliyunOssOption       AliyunOssOption
+
+	// Type、AliyunOssOption、LocalStorageOption等存储后端配置
+	storage.Config `mapstructure:",squash"`
 }
 
 type ResourceStorageOption struct {
-	WusthelperReleaseFileKey string
+	WusthelperReleaseFileKey string            // android最新版本文件的固定链接，ReleaseFileKeys中配置了android时不使用
+	ReleaseFileKeys          map[string]string // 各平台最新版本文件的固定链接，key为平台，没有配置的平台不维护固定链接
 
 	VersionFileStorageBasePath string
 	PicStorageBasePath         string
+	BundleStorageBasePath      string // 热更新资源包的文件按sha256存放在该目录的objects下，不同版本间相同的文件只存一份
 	DefaultPicUrl              string
 }
 
-type AliyunOssOption struct {
-	AccessKeyId      string
-	AccessKeySecret  string
-	Endpoint         string
-	Bucket           string
-	BucketBindDomain string
-}
-
 type WusthelperConf struct {
 	Upstream     string
 	Timeout      time.Duration
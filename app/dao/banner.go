@@ -2,22 +2,29 @@ package dao
 
 import (
 	"go.uber.org/zap"
+	"time"
 	"wusthelper-manager-go/app/model"
 	"wusthelper-manager-go/common"
 	"wusthelper-manager-go/library/ecode"
 	"wusthelper-manager-go/library/log"
+	"xorm.io/xorm"
 )
 
-func (d *Dao) GetPublishedBanner(platform ...string) (*[]model.Banner, error) {
+// GetPublishedBanner 获取展示位当前正在展示的轮播图，不包括未到开始时间和已过结束时间的，按排序权重从大到小排列
+func (d *Dao) GetPublishedBanner(slot string, platform ...string) (*[]model.Banner, error) {
+	now := time.Now()
 	result := make([]model.Banner, 0)
-	session := d.db.Where("status = ?", model.VersionPublishedStatus)
+	session := d.db.Where("status = ?", model.BannerPublishedStatus).
+		And("slot = ?", slot).
+		And("(start_at IS NULL OR start_at <= ?)", now).
+		And("(end_at IS NULL OR end_at > ?)", now)
 	if platform != nil && len(platform) > 0 {
 		session.In("platform", platform)
 	}
 
-	err := session.Desc("id").Find(&result)
+	err := session.Desc("sort_weight", "id").Find(&result)
 	if err != nil {
-		log.Error("获取轮播图时出现错误", zap.Any("platform", platform), zap.String("err", err.Error()))
+		log.Error("获取轮播图时出现错误", zap.String("slot", slot), zap.Any("platform", platform), zap.String("err", err.Error()))
 		return nil, ecode.InternalError
 	}
 
@@ -39,11 +46,30 @@ func (d *Dao) GetBanner(id int64) (*model.Banner, error) {
 	return result, nil
 }
 
-func (d *Dao) GetBannerList(paging common.Pagination, platform string) (*[]model.Banner, int64, error) {
+// GetBannerListByIds 按id获取轮播图，包括已删除的
+func (d *Dao) GetBannerListByIds(id ...int64) (*[]model.Banner, error) {
+	result := make([]model.Banner, 0, len(id))
+	if len(id) == 0 {
+		return &result, nil
+	}
+
+	err := d.db.In("id", id).Find(&result)
+	if err != nil {
+		log.Error("获取轮播图时出现错误", zap.Any("id", id), zap.String("err", err.Error()))
+		return nil, ecode.InternalError
+	}
+
+	return &result, nil
+}
+
+func (d *Dao) GetBannerList(paging common.Pagination, platform, slot string) (*[]model.Banner, int64, error) {
 	countSession := d.db.Where("status != ?", model.DeletedStatus)
 	if platform != "" {
 		countSession.And("platform = ?", platform)
 	}
+	if slot != "" {
+		countSession.And("slot = ?", slot)
+	}
 
 	total, err := countSession.Count(&model.Banner{})
 	if err != nil {
@@ -56,7 +82,10 @@ func (d *Dao) GetBannerList(paging common.Pagination, platform string) (*[]model
 	if platform != "" {
 		querySession.And("platform = ?", platform)
 	}
-	err = querySession.Desc("status", "id").
+	if slot != "" {
+		querySession.And("slot = ?", slot)
+	}
+	err = querySession.Desc("status", "sort_weight", "id").
 		Limit(paging.PageSize, paging.PageSize*(paging.Page-1)).Find(&result)
 	if err != nil {
 		log.Error("获取轮播图列表时出现错误", zap.String("err", err.Error()))
@@ -66,29 +95,93 @@ func (d *Dao) GetBannerList(paging common.Pagination, platform string) (*[]model
 	return &result, total, nil
 }
 
-func (d *Dao) AddBanner(banner ...model.Banner) (int64, error) {
-	count, err := d.db.Insert(banner)
-	if err != nil {
-		log.Error("添加轮播图时出现错误", zap.Any("entity", banner), zap.String("err", err.Error()))
-		return 0, ecode.InternalError
+// AddBanner 添加轮播图，同时添加上传图片的后台任务
+func (d *Dao) AddBanner(banner []model.Banner, jobs ...model.Job) (int64, error) {
+	return d.withJobs("添加轮播图", jobs, func(session *xorm.Session) (int64, error) {
+		count, err := session.Insert(banner)
+		if err != nil {
+			log.Error("添加轮播图时出现错误", zap.Any("entity", banner), zap.String("err", err.Error()))
+			return 0, ecode.InternalError
+		}
+
+		return count, nil
+	})
+}
+
+// UpdateBanner 修改轮播图，有jobs时在同一个事务中添加后台任务
+func (d *Dao) UpdateBanner(banner *model.Banner, jobs ...model.Job) (int64, error) {
+	update := func(session *xorm.Session) (int64, error) {
+		count, err := session.Omit("id").
+			Where("id = ?", banner.ID).
+			And("status != ?", model.DeletedStatus).
+			Update(banner)
+		if err != nil {
+			log.Error("修改轮播图时出现错误", zap.Any("entity", banner), zap.String("err", err.Error()))
+			return 0, err
+		}
+
+		return count, nil
 	}
 
-	return count, nil
+	if len(jobs) == 0 {
+		session := d.db.NewSession()
+		defer session.Close()
+		return update(session)
+	}
+
+	return d.withJobs("修改轮播图", jobs, update)
 }
 
-func (d *Dao) UpdateBanner(banner *model.Banner) (int64, error) {
-	count, err := d.db.Omit("id").
-		Where("id = ?", banner.ID).
+// UpdateBannerSchedule 修改轮播图的展示时间段，为nil的时间会置空
+func (d *Dao) UpdateBannerSchedule(id int64, startAt, endAt *time.Time) (int64, error) {
+	now := time.Now()
+	count, err := d.db.Cols("start_at", "end_at", "update_time").
+		Where("id = ?", id).
 		And("status != ?", model.DeletedStatus).
-		Update(banner)
+		Update(&model.Banner{StartAt: startAt, EndAt: endAt, UpdateTime: &now})
 	if err != nil {
-		log.Error("修改轮播图时出现错误", zap.Any("entity", banner), zap.String("err", err.Error()))
-		return 0, err
+		log.Error("修改轮播图展示时间时出现错误", zap.Int64("id", id), zap.String("err", err.Error()))
+		return 0, ecode.InternalError
 	}
 
 	return count, nil
 }
 
+// ReorderBanner 按id的顺序重新设置排序权重，排在前边的权重大，没有传入的轮播图权重不变
+func (d *Dao) ReorderBanner(id ...int64) error {
+	transaction := d.db.NewSession()
+	defer func(transaction *xorm.Session) {
+		err := transaction.Close()
+		if err != nil {
+			log.Warn("轮播图排序时出现错误，事务session关闭时出现异常", zap.Error(err))
+		}
+	}(transaction)
+
+	if err := transaction.Begin(); err != nil {
+		log.Error("轮播图排序时出现错误，事务开启时出现异常", zap.Error(err))
+		return ecode.InternalError
+	}
+
+	for i, bannerId := range id {
+		sortWeight := len(id) - i
+		_, err := transaction.Cols("sort_weight").
+			Where("id = ?", bannerId).
+			And("status != ?", model.DeletedStatus).
+			Update(&model.Banner{SortWeight: &sortWeight})
+		if err != nil {
+			log.Error("轮播图排序时出现错误", zap.Int64("id", bannerId), zap.String("err", err.Error()))
+			return ecode.InternalError
+		}
+	}
+
+	if err := transaction.Commit(); err != nil {
+		log.Error("轮播图排序时出现错误，提交事务时出现异常", zap.Error(err))
+		return ecode.InternalError
+	}
+
+	return nil
+}
+
 func (d *Dao) PublishBanner(id int64) (int64, error) {
 	status := model.BannerPublishedStatus
 	count, err := d.db.Omit("id").
@@ -144,3 +237,31 @@ func (d *Dao) DeleteBanner(id int64) error {
 
 	return nil
 }
+
+// RestoreBannerImg 上传任务失败清空图片记录后手动重试成功时，恢复轮播图的图片记录，已经换了新图片的不修改
+func (d *Dao) RestoreBannerImg(id int64, imgId string) (int64, error) {
+	count, err := d.db.Omit("id").
+		Where("id = ?", id).And("status != ?", model.DeletedStatus).
+		And("img IS NULL OR img = ''").
+		Update(&model.Banner{Img: &imgId})
+	if err != nil {
+		log.Error("恢复轮播图图片记录时出现错误", zap.Int64("id", id), zap.String("err", err.Error()))
+		return 0, ecode.InternalError
+	}
+
+	return count, nil
+}
+
+// GetBannerImgList 获取所有未删除且有图片的轮播图，只包括图片字段，用于清理存储中没有被引用的对象
+func (d *Dao) GetBannerImgList() (*[]model.Banner, error) {
+	result := make([]model.Banner, 0)
+	err := d.db.Cols("id", "img", "img_variants").
+		Where("status != ?", model.DeletedStatus).And("img != ''").
+		Find(&result)
+	if err != nil {
+		log.Error("获取轮播图图片列表时出现错误", zap.String("err", err.Error()))
+		return nil, ecode.InternalError
+	}
+
+	return &result, nil
+}
@@ -0,0 +1,139 @@
+package dao
+
+import (
+	"context"
+	"fmt"
+	"go.uber.org/zap"
+	"time"
+	"wusthelper-manager-go/app/model"
+	"wusthelper-manager-go/library/ecode"
+	"wusthelper-manager-go/library/log"
+)
+
+// 轮播图各平台每天的展示和点击次数，桶为日期，field为 轮播图id:平台，两个计数共用记录日期的set
+var (
+	_bannerImpressionCounter = counter{
+		name:       "轮播图展示次数",
+		hashKey:    "wusthelper-manager:banner:impression:%s",
+		bucketsKey: "wusthelper-manager:banner:days",
+		expiration: time.Hour * 24 * 7,
+	}
+	_bannerClickCounter = counter{
+		name:       "轮播图点击次数",
+		hashKey:    "wusthelper-manager:banner:click:%s",
+		bucketsKey: "wusthelper-manager:banner:days",
+		expiration: time.Hour * 24 * 7,
+	}
+)
+
+// IncreaseBannerImpressionCount 轮播图在date这天的展示次数各+1，date为yyyy-MM-dd
+func (d *Dao) IncreaseBannerImpressionCount(c *context.Context, date string, banner ...model.Banner) error {
+	fields := make([]string, len(banner))
+	for i, b := range banner {
+		fields[i] = fmt.Sprintf("%d:%s", b.ID, *b.Platform)
+	}
+
+	return d.increaseCounter(c, _bannerImpressionCounter, date, fields...)
+}
+
+// IncreaseBannerClickCount 轮播图在date这天的点击次数+1，date为yyyy-MM-dd
+func (d *Dao) IncreaseBannerClickCount(c *context.Context, date string, bannerId int64, platform string) error {
+	return d.increaseCounter(c, _bannerClickCounter, date, fmt.Sprintf("%d:%s", bannerId, platform))
+}
+
+// GetBannerStatDays 获取redis中有轮播图计数的日期
+func (d *Dao) GetBannerStatDays(c *context.Context) ([]string, error) {
+	return d.getCounterBuckets(c, _bannerImpressionCounter)
+}
+
+// GetBannerStatCounts 获取redis中某天各轮播图各平台的展示和点击次数，只填充BannerId、Platform、Impressions和Clicks
+func (d *Dao) GetBannerStatCounts(c *context.Context, date string) ([]model.BannerStat, error) {
+	impressions, err := d.getCounts(c, _bannerImpressionCounter, date)
+	if err != nil {
+		return nil, err
+	}
+
+	clicks, err := d.getCounts(c, _bannerClickCounter, date)
+	if err != nil {
+		return nil, err
+	}
+
+	stats := map[string]*model.BannerStat{}
+	for _, counts := range []struct {
+		values map[string]int64
+		click  bool
+	}{{impressions, false}, {clicks, true}} {
+		for field, count := range counts.values {
+			bannerId, platform, ok := parseIdField(field)
+			if !ok {
+				log.Warn("轮播图计数格式不正确", zap.String("date", date), zap.String("field", field))
+				continue
+			}
+
+			stat, exists := stats[field]
+			if !exists {
+				stat = &model.BannerStat{BannerId: &bannerId, Platform: &platform, Impressions: new(int64), Clicks: new(int64)}
+				stats[field] = stat
+			}
+
+			if counts.click {
+				*stat.Clicks = count
+			} else {
+				*stat.Impressions = count
+			}
+		}
+	}
+
+	result := make([]model.BannerStat, 0, len(stats))
+	for _, stat := range stats {
+		result = append(result, *stat)
+	}
+
+	return result, nil
+}
+
+// RemoveBannerStatDay 删除redis中某天的轮播图计数
+func (d *Dao) RemoveBannerStatDay(c *context.Context, date string) error {
+	return d.removeCounterBucket(c, date, _bannerImpressionCounter, _bannerClickCounter)
+}
+
+// SaveBannerStat 写入轮播图统计，同一轮播图同一平台同一天已有记录时覆盖次数
+func (d *Dao) SaveBannerStat(stat *model.BannerStat) error {
+	_, err := d.db.Exec("INSERT INTO `banner_stat` (`id`, `banner_id`, `platform`, `date`, `impressions`, `clicks`, `update_time`) "+
+		"VALUES (?, ?, ?, ?, ?, ?, ?) "+
+		"ON DUPLICATE KEY UPDATE `impressions` = VALUES(`impressions`), `clicks` = VALUES(`clicks`), `update_time` = VALUES(`update_time`)",
+		stat.ID, *stat.BannerId, *stat.Platform, stat.Date.Format(time.DateOnly), *stat.Impressions, *stat.Clicks, *stat.UpdateTime,
+	)
+	if err != nil {
+		log.Error("保存轮播图统计时出现错误", zap.Any("entity", stat), zap.String("err", err.Error()))
+		return ecode.InternalError
+	}
+
+	return nil
+}
+
+// GetBannerStatSummary 按轮播图和平台汇总[start, end]内的展示和点击次数，bannerId为0、platform为空时不限制，
+// 只填充BannerId、Platform、Impressions和Clicks
+func (d *Dao) GetBannerStatSummary(bannerId int64, platform string, start, end time.Time) ([]model.BannerStat, error) {
+	session := d.db.Select("`banner_id`, `platform`, SUM(`impressions`) AS `impressions`, SUM(`clicks`) AS `clicks`").
+		Where("`date` BETWEEN ? AND ?", start.Format(time.DateOnly), end.Format(time.DateOnly))
+	if bannerId != 0 {
+		session.And("banner_id = ?", bannerId)
+	}
+	if platform != "" {
+		session.And("platform = ?", platform)
+	}
+
+	result := make([]model.BannerStat, 0)
+	err := session.GroupBy("`banner_id`, `platform`").Desc("impressions").Find(&result)
+	if err != nil {
+		log.Error("获取轮播图统计时出现错误",
+			zap.Int64("banner_id", bannerId),
+			zap.String("platform", platform),
+			zap.String("err", err.Error()),
+		)
+		return nil, ecode.InternalError
+	}
+
+	return result, nil
+}
@@ -0,0 +1,158 @@
+package dao
+
+import (
+	"go.uber.org/zap"
+	"wusthelper-manager-go/app/model"
+	"wusthelper-manager-go/common"
+	"wusthelper-manager-go/library/ecode"
+	"wusthelper-manager-go/library/log"
+	"xorm.io/xorm"
+)
+
+func (d *Dao) GetBundle(id int64) (*model.Bundle, error) {
+	result := new(model.Bundle)
+	has, err := d.db.
+		Where("id = ?", id).And("status != ?", model.DeletedStatus).
+		Get(result)
+	if err != nil {
+		log.Error("获取资源包时出现错误", zap.Int64("id", id), zap.String("err", err.Error()))
+		return nil, ecode.InternalError
+	} else if !has {
+		return nil, nil
+	}
+
+	return result, nil
+}
+
+// GetBundleByVersion 获取平台下某个资源包的某个版本，没有时返回nil
+func (d *Dao) GetBundleByVersion(name, platform, versionText string) (*model.Bundle, error) {
+	result := new(model.Bundle)
+	has, err := d.db.
+		Where("name = ?", name).And("platform = ?", platform).And("version_text = ?", versionText).
+		And("status != ?", model.DeletedStatus).
+		Get(result)
+	if err != nil {
+		log.Error("获取资源包时出现错误",
+			zap.String("name", name),
+			zap.String("platform", platform),
+			zap.String("version", versionText),
+			zap.String("err", err.Error()),
+		)
+		return nil, ecode.InternalError
+	} else if !has {
+		return nil, nil
+	}
+
+	return result, nil
+}
+
+// GetBundleList 获取资源包列表，不包括文件清单
+func (d *Dao) GetBundleList(paging common.Pagination, platform, name string) (*[]model.Bundle, int64, error) {
+	countSession := d.db.Where("status != ?", model.DeletedStatus)
+	if platform != "" {
+		countSession.And("platform = ?", platform)
+	}
+	if name != "" {
+		countSession.And("name = ?", name)
+	}
+
+	total, err := countSession.Count(&model.Bundle{})
+	if err != nil {
+		log.Error("获取资源包数量时出现错误", zap.String("err", err.Error()))
+		return nil, 0, ecode.InternalError
+	}
+
+	result := make([]model.Bundle, 0)
+	querySession := d.db.Omit("manifest").Where("status != ?", model.DeletedStatus)
+	if platform != "" {
+		querySession.And("platform = ?", platform)
+	}
+	if name != "" {
+		querySession.And("name = ?", name)
+	}
+	err = querySession.Desc("id").
+		Limit(paging.PageSize, paging.PageSize*(paging.Page-1)).Find(&result)
+	if err != nil {
+		log.Error("获取资源包列表时出现错误", zap.String("err", err.Error()))
+		return nil, 0, ecode.InternalError
+	}
+
+	return &result, total, nil
+}
+
+// GetPublishedBundleList 获取平台下某个资源包所有已发布的版本，不包括文件清单
+func (d *Dao) GetPublishedBundleList(name, platform string) (*[]model.Bundle, error) {
+	result := make([]model.Bundle, 0)
+	err := d.db.Omit("manifest").
+		Where("name = ?", name).And("platform = ?", platform).
+		And("status = ?", model.BundlePublishedStatus).
+		Find(&result)
+	if err != nil {
+		log.Error("获取已发布的资源包时出现错误",
+			zap.String("name", name),
+			zap.String("platform", platform),
+			zap.String("err", err.Error()),
+		)
+		return nil, ecode.InternalError
+	}
+
+	return &result, nil
+}
+
+// AddBundle 添加资源包，有jobs时在同一个事务中添加后台任务
+func (d *Dao) AddBundle(bundle *model.Bundle, jobs ...model.Job) (int64, error) {
+	return d.withJobs("添加资源包", jobs, func(session *xorm.Session) (int64, error) {
+		count, err := session.InsertOne(bundle)
+		if err != nil {
+			log.Error("添加资源包时出现错误",
+				zap.Int64("id", bundle.ID),
+				zap.Stringp("name", bundle.Name),
+				zap.Stringp("version", bundle.VersionText),
+				zap.String("err", err.Error()),
+			)
+			return 0, ecode.InternalError
+		}
+
+		return count, nil
+	})
+}
+
+// SetBundleReady 资源包的文件全部上传完成
+func (d *Dao) SetBundleReady(id int64) (int64, error) {
+	ready := true
+	count, err := d.db.Omit("id").
+		Where("id = ?", id).And("status != ?", model.DeletedStatus).
+		Update(&model.Bundle{Ready: &ready})
+	if err != nil {
+		log.Error("修改资源包时出现错误", zap.Int64("id", id), zap.String("err", err.Error()))
+		return 0, ecode.InternalError
+	}
+
+	return count, nil
+}
+
+// UpdateBundleStatus 修改资源包的状态，只修改未删除且文件已上传完成的资源包
+func (d *Dao) UpdateBundleStatus(id int64, status int8) (int64, error) {
+	count, err := d.db.Omit("id").
+		Where("id = ?", id).And("status != ?", model.DeletedStatus).And("ready = ?", true).
+		Update(&model.Bundle{Status: &status})
+	if err != nil {
+		log.Error("修改资源包状态时出现错误", zap.Int64("id", id), zap.Int8("status", status), zap.String("err", err.Error()))
+		return 0, ecode.InternalError
+	}
+
+	return count, nil
+}
+
+func (d *Dao) DeleteBundle(id int64) (int64, error) {
+	status := model.DeletedStatus
+	count, err := d.db.Omit("id").
+		Where("id = ?", id).And("status != ?", model.DeletedStatus).
+		Update(&model.Bundle{Status: &status})
+	if err != nil {
+		log.Error("删除资源包时出现错误", zap.Int64("id", id), zap.String("err", err.Error()))
+		return 0, ecode.InternalError
+	}
+
+	return count, nil
+}
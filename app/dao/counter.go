@@ -0,0 +1,104 @@
+package dao
+
+import (
+	"context"
+	"fmt"
+	"go.uber.org/zap"
+	"strconv"
+	"strings"
+	"time"
+	"wusthelper-manager-go/library/ecode"
+	"wusthelper-manager-go/library/log"
+)
+
+// counter redis中按桶（如日期、实验id）累计的计数，每个桶是一个hash，有计数的桶记录在一个set中，由service定期写入数据库
+type counter struct {
+	name       string        // 用于日志
+	hashKey    string        // 桶的hash，%s为桶
+	bucketsKey string        // 有计数的桶
+	expiration time.Duration // hash的过期时间，为0时不过期
+}
+
+// increaseCounter 桶中各field的计数+1，同一个field出现多次时加多次
+func (d *Dao) increaseCounter(c *context.Context, counter counter, bucket string, fields ...string) error {
+	if len(fields) == 0 {
+		return nil
+	}
+
+	key := fmt.Sprintf(counter.hashKey, bucket)
+	pipe := d.redis.TxPipeline()
+	for _, field := range fields {
+		pipe.HIncrBy(*c, key, field, 1)
+	}
+	if counter.expiration > 0 {
+		pipe.Expire(*c, key, counter.expiration)
+	}
+	pipe.SAdd(*c, counter.bucketsKey, bucket)
+	_, err := pipe.Exec(*c)
+	if err != nil {
+		log.Error("记录"+counter.name+"出现错误", zap.String("bucket", bucket), zap.Int("count", len(fields)), zap.Error(err))
+		return ecode.InternalError
+	}
+
+	return nil
+}
+
+// getCounterBuckets 获取有计数的桶
+func (d *Dao) getCounterBuckets(c *context.Context, counter counter) ([]string, error) {
+	buckets, err := d.redis.SMembers(*c, counter.bucketsKey).Result()
+	if err != nil {
+		log.Error("获取"+counter.name+"的桶出现错误", zap.Error(err))
+		return nil, ecode.InternalError
+	}
+
+	return buckets, nil
+}
+
+// getCounts 获取桶中各field的计数，计数格式不对的field会被跳过
+func (d *Dao) getCounts(c *context.Context, counter counter, bucket string) (map[string]int64, error) {
+	values, err := d.redis.HGetAll(*c, fmt.Sprintf(counter.hashKey, bucket)).Result()
+	if err != nil {
+		log.Error("获取"+counter.name+"出现错误", zap.String("bucket", bucket), zap.Error(err))
+		return nil, ecode.InternalError
+	}
+
+	result := make(map[string]int64, len(values))
+	for field, value := range values {
+		count, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			log.Warn(counter.name+"格式不正确", zap.String("bucket", bucket), zap.String("field", field), zap.String("value", value))
+			continue
+		}
+
+		result[field] = count
+	}
+
+	return result, nil
+}
+
+// removeCounterBucket 删除桶的计数，多个计数共用记录桶的set时一起删除
+func (d *Dao) removeCounterBucket(c *context.Context, bucket string, counters ...counter) error {
+	pipe := d.redis.TxPipeline()
+	for _, counter := range counters {
+		pipe.Del(*c, fmt.Sprintf(counter.hashKey, bucket))
+		pipe.SRem(*c, counter.bucketsKey, bucket)
+	}
+	_, err := pipe.Exec(*c)
+	if err != nil {
+		log.Error("删除计数出现错误", zap.String("bucket", bucket), zap.Error(err))
+		return ecode.InternalError
+	}
+
+	return nil
+}
+
+// parseIdField 解析 id:平台 格式的field
+func parseIdField(field string) (int64, string, bool) {
+	idText, platform, ok := strings.Cut(field, ":")
+	id, err := strconv.ParseInt(idText, 10, 64)
+	if !ok || err != nil {
+		return 0, "", false
+	}
+
+	return id, platform, true
+}
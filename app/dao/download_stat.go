@@ -0,0 +1,96 @@
+package dao
+
+import (
+	"context"
+	"fmt"
+	"go.uber.org/zap"
+	"time"
+	"wusthelper-manager-go/app/model"
+	"wusthelper-manager-go/library/ecode"
+	"wusthelper-manager-go/library/log"
+)
+
+// _downloadCounter 各版本各平台每天的下载次数，桶为日期，field为 版本id:平台
+var _downloadCounter = counter{
+	name:       "下载次数",
+	hashKey:    "wusthelper-manager:download:%s",
+	bucketsKey: "wusthelper-manager:download:days",
+	expiration: time.Hour * 24 * 7,
+}
+
+// IncreaseDownloadCount 版本在date这天的下载次数+1，date为yyyy-MM-dd
+func (d *Dao) IncreaseDownloadCount(c *context.Context, date string, versionId int64, platform string) error {
+	return d.increaseCounter(c, _downloadCounter, date, fmt.Sprintf("%d:%s", versionId, platform))
+}
+
+// GetDownloadCountDays 获取redis中有下载计数的日期
+func (d *Dao) GetDownloadCountDays(c *context.Context) ([]string, error) {
+	return d.getCounterBuckets(c, _downloadCounter)
+}
+
+// GetDownloadCounts 获取redis中某天各版本各平台的下载次数，只填充VersionId、Platform和Count
+func (d *Dao) GetDownloadCounts(c *context.Context, date string) ([]model.DownloadStat, error) {
+	counts, err := d.getCounts(c, _downloadCounter, date)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]model.DownloadStat, 0, len(counts))
+	for field, count := range counts {
+		versionId, platform, ok := parseIdField(field)
+		if !ok {
+			log.Warn("下载计数格式不正确", zap.String("date", date), zap.String("field", field))
+			continue
+		}
+
+		count := count
+		result = append(result, model.DownloadStat{VersionId: &versionId, Platform: &platform, Count: &count})
+	}
+
+	return result, nil
+}
+
+// RemoveDownloadCountDay 删除redis中某天的下载计数
+func (d *Dao) RemoveDownloadCountDay(c *context.Context, date string) error {
+	return d.removeCounterBucket(c, date, _downloadCounter)
+}
+
+// SaveDownloadStat 写入下载统计，同一版本同一平台同一天已有记录时覆盖次数
+func (d *Dao) SaveDownloadStat(stat *model.DownloadStat) error {
+	_, err := d.db.Exec("INSERT INTO `download_stat` (`id`, `version_id`, `platform`, `date`, `count`, `update_time`) "+
+		"VALUES (?, ?, ?, ?, ?, ?) "+
+		"ON DUPLICATE KEY UPDATE `count` = VALUES(`count`), `update_time` = VALUES(`update_time`)",
+		stat.ID, *stat.VersionId, *stat.Platform, stat.Date.Format(time.DateOnly), *stat.Count, *stat.UpdateTime,
+	)
+	if err != nil {
+		log.Error("保存下载统计时出现错误", zap.Any("entity", stat), zap.String("err", err.Error()))
+		return ecode.InternalError
+	}
+
+	return nil
+}
+
+// GetDownloadStatSeries 按天汇总[start, end]内的下载次数，versionId为0、platform为空时不限制，只填充Date和Count
+func (d *Dao) GetDownloadStatSeries(versionId int64, platform string, start, end time.Time) ([]model.DownloadStat, error) {
+	session := d.db.Select("`date`, SUM(`count`) AS `count`").
+		Where("`date` BETWEEN ? AND ?", start.Format(time.DateOnly), end.Format(time.DateOnly))
+	if versionId != 0 {
+		session.And("version_id = ?", versionId)
+	}
+	if platform != "" {
+		session.And("platform = ?", platform)
+	}
+
+	result := make([]model.DownloadStat, 0)
+	err := session.GroupBy("`date`").Asc("date").Find(&result)
+	if err != nil {
+		log.Error("获取下载统计时出现错误",
+			zap.Int64("version_id", versionId),
+			zap.String("platform", platform),
+			zap.String("err", err.Error()),
+		)
+		return nil, ecode.InternalError
+	}
+
+	return result, nil
+}
@@ -0,0 +1,225 @@
+package dao
+
+import (
+	"context"
+	"fmt"
+	"go.uber.org/zap"
+	"strconv"
+	"strings"
+	"wusthelper-manager-go/app/model"
+	"wusthelper-manager-go/common"
+	"wusthelper-manager-go/library/ecode"
+	"wusthelper-manager-go/library/log"
+)
+
+// _experimentCounter 实验各变体累计的曝光和点击次数，桶为实验id，field为 exposure:变体id 或 click:变体id。
+// 实验进行期间一直累计，不过期，实验删除时清掉
+var _experimentCounter = counter{
+	name:       "实验计数",
+	hashKey:    "wusthelper-manager:experiment:%s",
+	bucketsKey: "wusthelper-manager:experiment:ids",
+}
+
+func (d *Dao) GetExperiment(id int64) (*model.Experiment, error) {
+	result := new(model.Experiment)
+	exists, err := d.db.
+		Where("id = ?", id).And("status != ?", model.DeletedStatus).
+		Get(result)
+	if err != nil {
+		log.Error("获取实验时出现错误", zap.Int64("id", id), zap.String("err", err.Error()))
+		return nil, ecode.InternalError
+	} else if !exists {
+		return nil, nil
+	}
+
+	return result, nil
+}
+
+func (d *Dao) GetExperimentList(paging common.Pagination, targetType string) (*[]model.Experiment, int64, error) {
+	countSession := d.db.Where("status != ?", model.DeletedStatus)
+	if targetType != "" {
+		countSession.And("target_type = ?", targetType)
+	}
+
+	total, err := countSession.Count(&model.Experiment{})
+	if err != nil {
+		log.Error("获取实验数量时出现错误", zap.String("err", err.Error()))
+		return nil, 0, ecode.InternalError
+	}
+
+	result := make([]model.Experiment, 0)
+	querySession := d.db.Where("status != ?", model.DeletedStatus)
+	if targetType != "" {
+		querySession.And("target_type = ?", targetType)
+	}
+	err = querySession.Desc("id").
+		Limit(paging.PageSize, paging.PageSize*(paging.Page-1)).Find(&result)
+	if err != nil {
+		log.Error("获取实验列表时出现错误", zap.String("err", err.Error()))
+		return nil, 0, ecode.InternalError
+	}
+
+	return &result, total, nil
+}
+
+// GetRunningExperimentList 获取某类对象进行中的实验
+func (d *Dao) GetRunningExperimentList(targetType string) (*[]model.Experiment, error) {
+	result := make([]model.Experiment, 0)
+	err := d.db.
+		Where("target_type = ?", targetType).And("status = ?", model.ExperimentRunningStatus).
+		Asc("id").Find(&result)
+	if err != nil {
+		log.Error("获取进行中的实验时出现错误", zap.String("target_type", targetType), zap.String("err", err.Error()))
+		return nil, ecode.InternalError
+	}
+
+	return &result, nil
+}
+
+func (d *Dao) AddExperiment(experiment *model.Experiment) error {
+	_, err := d.db.InsertOne(experiment)
+	if err != nil {
+		log.Error("添加实验时出现错误", zap.Any("entity", experiment), zap.String("err", err.Error()))
+		return ecode.InternalError
+	}
+
+	return nil
+}
+
+// UpdateExperiment 修改未开始的实验
+func (d *Dao) UpdateExperiment(experiment *model.Experiment) (int64, error) {
+	count, err := d.db.Omit("id", "status").
+		Where("id = ?", experiment.ID).
+		And("status = ?", model.NormalStatus).
+		Update(experiment)
+	if err != nil {
+		log.Error("修改实验时出现错误", zap.Any("entity", experiment), zap.String("err", err.Error()))
+		return 0, ecode.InternalError
+	}
+
+	return count, nil
+}
+
+// UpdateExperimentStatus 实验状态为from时才修改为to
+func (d *Dao) UpdateExperimentStatus(id int64, from, to int8) (int64, error) {
+	count, err := d.db.Omit("id").
+		Where("id = ?", id).
+		And("status = ?", from).
+		Update(&model.Experiment{Status: &to})
+	if err != nil {
+		log.Error("修改实验状态时出现错误", zap.Int64("id", id), zap.Int8("status", to), zap.String("err", err.Error()))
+		return 0, ecode.InternalError
+	}
+
+	return count, nil
+}
+
+func (d *Dao) DeleteExperiment(id int64) error {
+	status := model.DeletedStatus
+	_, err := d.db.Omit("id").
+		Where("id = ?", id).
+		Update(&model.Experiment{Status: &status})
+	if err != nil {
+		log.Error("删除实验时出现错误", zap.Int64("id", id), zap.String("err", err.Error()))
+		return ecode.InternalError
+	}
+
+	return nil
+}
+
+// IncreaseExperimentCount 实验变体的曝光或点击次数+1，event见 model.ExperimentEventXxx
+func (d *Dao) IncreaseExperimentCount(c *context.Context, event string, experimentId, targetId int64) error {
+	return d.increaseCounter(c, _experimentCounter, strconv.FormatInt(experimentId, 10), fmt.Sprintf("%s:%d", event, targetId))
+}
+
+// GetExperimentCountIds 获取redis中有计数的实验id
+func (d *Dao) GetExperimentCountIds(c *context.Context) ([]int64, error) {
+	idTexts, err := d.getCounterBuckets(c, _experimentCounter)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]int64, 0, len(idTexts))
+	for _, idText := range idTexts {
+		id, err := strconv.ParseInt(idText, 10, 64)
+		if err != nil {
+			log.Warn("实验计数id格式不正确", zap.String("id", idText))
+			continue
+		}
+
+		result = append(result, id)
+	}
+
+	return result, nil
+}
+
+// GetExperimentCounts 获取redis中实验各变体累计的曝光和点击次数，只填充ExperimentId、TargetId、Exposures和Clicks
+func (d *Dao) GetExperimentCounts(c *context.Context, experimentId int64) ([]model.ExperimentStat, error) {
+	counts, err := d.getCounts(c, _experimentCounter, strconv.FormatInt(experimentId, 10))
+	if err != nil {
+		return nil, err
+	}
+
+	stats := map[int64]*model.ExperimentStat{}
+	for field, count := range counts {
+		event, idText, ok := strings.Cut(field, ":")
+		targetId, idErr := strconv.ParseInt(idText, 10, 64)
+		if !ok || idErr != nil {
+			log.Warn("实验计数格式不正确", zap.Int64("experiment_id", experimentId), zap.String("field", field))
+			continue
+		}
+
+		stat, exists := stats[targetId]
+		if !exists {
+			stat = &model.ExperimentStat{ExperimentId: &experimentId, TargetId: &targetId, Exposures: new(int64), Clicks: new(int64)}
+			stats[targetId] = stat
+		}
+
+		switch event {
+		case model.ExperimentEventExposure:
+			*stat.Exposures = count
+		case model.ExperimentEventClick:
+			*stat.Clicks = count
+		}
+	}
+
+	result := make([]model.ExperimentStat, 0, len(stats))
+	for _, stat := range stats {
+		result = append(result, *stat)
+	}
+
+	return result, nil
+}
+
+// RemoveExperimentCount 删除redis中实验的计数
+func (d *Dao) RemoveExperimentCount(c *context.Context, experimentId int64) error {
+	return d.removeCounterBucket(c, strconv.FormatInt(experimentId, 10), _experimentCounter)
+}
+
+// SaveExperimentStat 写入实验统计，同一实验同一变体已有记录时覆盖次数。redis中是累计值，
+// 取较大的值避免redis数据丢失后计数从0开始时覆盖掉之前的统计
+func (d *Dao) SaveExperimentStat(stat *model.ExperimentStat) error {
+	_, err := d.db.Exec("INSERT INTO `experiment_stat` (`id`, `experiment_id`, `target_id`, `exposures`, `clicks`, `update_time`) "+
+		"VALUES (?, ?, ?, ?, ?, ?) "+
+		"ON DUPLICATE KEY UPDATE `exposures` = GREATEST(`exposures`, VALUES(`exposures`)), `clicks` = GREATEST(`clicks`, VALUES(`clicks`)), "+
+		"`update_time` = VALUES(`update_time`)",
+		stat.ID, *stat.ExperimentId, *stat.TargetId, *stat.Exposures, *stat.Clicks, *stat.UpdateTime,
+	)
+	if err != nil {
+		log.Error("保存实验统计时出现错误", zap.Any("entity", stat), zap.String("err", err.Error()))
+		return ecode.InternalError
+	}
+
+	return nil
+}
+
+func (d *Dao) GetExperimentStatList(experimentId int64) (*[]model.ExperimentStat, error) {
+	result := make([]model.ExperimentStat, 0)
+	err := d.db.Where("experiment_id = ?", experimentId).Find(&result)
+	if err != nil {
+		log.Error("获取实验统计时出现错误", zap.Int64("experiment_id", experimentId), zap.String("err", err.Error()))
+		return nil, ecode.InternalError
+	}
+
+	return &result, nil
+}
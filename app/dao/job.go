@@ -0,0 +1,182 @@
+package dao
+
+import (
+	"go.uber.org/zap"
+	"time"
+	"wusthelper-manager-go/app/model"
+	"wusthelper-manager-go/common"
+	"wusthelper-manager-go/library/ecode"
+	"wusthelper-manager-go/library/log"
+	"xorm.io/xorm"
+)
+
+func (d *Dao) AddJob(job *model.Job) (int64, error) {
+	count, err := d.db.InsertOne(job)
+	if err != nil {
+		log.Error("添加后台任务时出现错误", zap.Any("entity", job), zap.String("err", err.Error()))
+		return 0, ecode.InternalError
+	}
+
+	return count, nil
+}
+
+// withJobs 在一个事务中执行写业务数据的fn并添加后台任务，保证业务数据和依赖它的任务要么都写入要么都不写入，
+// 避免记录指向一个永远不会上传的文件。action用于日志，如“添加版本信息”
+func (d *Dao) withJobs(action string, jobs []model.Job, fn func(session *xorm.Session) (int64, error)) (int64, error) {
+	transaction := d.db.NewSession()
+	defer func(transaction *xorm.Session) {
+		err := transaction.Close()
+		if err != nil {
+			log.Warn(action+"时出现错误，事务session关闭时出现异常", zap.Error(err))
+		}
+	}(transaction)
+
+	if err := transaction.Begin(); err != nil {
+		log.Error(action+"时出现错误，事务开启时出现异常", zap.Error(err))
+		return 0, ecode.InternalError
+	}
+
+	count, err := fn(transaction)
+	if err != nil {
+		return 0, err
+	}
+
+	if len(jobs) > 0 {
+		_, err = transaction.Insert(jobs)
+		if err != nil {
+			log.Error(action+"时出现错误，添加后台任务时出现异常", zap.Any("jobs", jobs), zap.String("err", err.Error()))
+			return 0, ecode.InternalError
+		}
+	}
+
+	err = transaction.Commit()
+	if err != nil {
+		log.Error(action+"时出现错误，提交事务时出现异常", zap.Error(err))
+		return 0, ecode.InternalError
+	}
+
+	return count, nil
+}
+
+func (d *Dao) GetJob(id int64) (*model.Job, error) {
+	result := new(model.Job)
+	exists, err := d.db.Where("id = ?", id).Get(result)
+	if err != nil {
+		log.Error("获取后台任务时出现错误", zap.Int64("id", id), zap.String("err", err.Error()))
+		return nil, ecode.InternalError
+	} else if !exists {
+		return nil, nil
+	}
+
+	return result, nil
+}
+
+func (d *Dao) GetJobList(paging common.Pagination, status *int8, jobType string) (*[]model.Job, int64, error) {
+	countSession := d.db.Where("1 = 1")
+	if status != nil {
+		countSession.And("status = ?", *status)
+	}
+	if jobType != "" {
+		countSession.And("type = ?", jobType)
+	}
+
+	total, err := countSession.Count(&model.Job{})
+	if err != nil {
+		log.Error("获取后台任务数量时出现错误", zap.String("err", err.Error()))
+		return nil, 0, ecode.InternalError
+	}
+
+	result := make([]model.Job, 0)
+	querySession := d.db.Where("1 = 1")
+	if status != nil {
+		querySession.And("status = ?", *status)
+	}
+	if jobType != "" {
+		querySession.And("type = ?", jobType)
+	}
+	err = querySession.Desc("id").
+		Limit(paging.PageSize, paging.PageSize*(paging.Page-1)).Find(&result)
+	if err != nil {
+		log.Error("获取后台任务列表时出现错误", zap.String("err", err.Error()))
+		return nil, 0, ecode.InternalError
+	}
+
+	return &result, total, nil
+}
+
+// ClaimDueJob 领取一个已到执行时间的待执行任务，并将其状态改为执行中，没有可领取的任务时返回nil
+func (d *Dao) ClaimDueJob(now time.Time) (*model.Job, error) {
+	job := new(model.Job)
+	has, err := d.db.
+		Where("status = ?", model.JobPendingStatus).And("next_run_time <= ?", now).
+		Asc("next_run_time").
+		Get(job)
+	if err != nil {
+		log.Error("获取待执行后台任务时出现错误", zap.String("err", err.Error()))
+		return nil, ecode.InternalError
+	} else if !has {
+		return nil, nil
+	}
+
+	// 用状态做条件更新，多个worker同时领取同一个任务时只有一个能成功
+	runningStatus := model.JobRunningStatus
+	count, err := d.db.Omit("id").
+		Where("id = ?", job.ID).And("status = ?", model.JobPendingStatus).
+		Update(&model.Job{Status: &runningStatus, UpdateTime: &now})
+	if err != nil {
+		log.Error("领取后台任务时出现错误", zap.Int64("id", job.ID), zap.String("err", err.Error()))
+		return nil, ecode.InternalError
+	} else if count == 0 {
+		return nil, nil
+	}
+
+	job.Status = &runningStatus
+	return job, nil
+}
+
+// UpdateJobResult 更新任务一次执行后的结果，status、attempts、last_error、next_run_time、update_time均需设置
+func (d *Dao) UpdateJobResult(job *model.Job) (int64, error) {
+	count, err := d.db.
+		Cols("status", "attempts", "last_error", "next_run_time", "update_time").
+		Where("id = ?", job.ID).
+		Update(job)
+	if err != nil {
+		log.Error("修改后台任务时出现错误", zap.Int64("id", job.ID), zap.String("err", err.Error()))
+		return 0, ecode.InternalError
+	}
+
+	return count, nil
+}
+
+// ResetRunningJobs 将执行中的任务重置为待执行，用于服务重启后恢复上次中断的任务
+func (d *Dao) ResetRunningJobs() (int64, error) {
+	now := time.Now()
+	pendingStatus := model.JobPendingStatus
+	count, err := d.db.Omit("id").
+		Where("status = ?", model.JobRunningStatus).
+		MustCols("status").
+		Update(&model.Job{Status: &pendingStatus, NextRunTime: &now, UpdateTime: &now})
+	if err != nil {
+		log.Error("重置执行中的后台任务时出现错误", zap.String("err", err.Error()))
+		return 0, ecode.InternalError
+	}
+
+	return count, nil
+}
+
+// RetryJob 将失败或待执行的任务重置为立即执行，并清空已执行次数
+func (d *Dao) RetryJob(id int64) (int64, error) {
+	now := time.Now()
+	pendingStatus := model.JobPendingStatus
+	attempts := 0
+	count, err := d.db.Omit("id").
+		Where("id = ?", id).In("status", model.JobFailedStatus, model.JobPendingStatus).
+		MustCols("status", "attempts").
+		Update(&model.Job{Status: &pendingStatus, Attempts: &attempts, NextRunTime: &now, UpdateTime: &now})
+	if err != nil {
+		log.Error("重试后台任务时出现错误", zap.Int64("id", id), zap.String("err", err.Error()))
+		return 0, ecode.InternalError
+	}
+
+	return count, nil
+}
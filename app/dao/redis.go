@@ -3,9 +3,11 @@ package dao
 import (
 	"context"
 	"fmt"
+	jsoniter "github.com/json-iterator/go"
 	"github.com/redis/go-redis/v9"
 	"go.uber.org/zap"
 	"time"
+	"wusthelper-manager-go/app/model"
 	"wusthelper-manager-go/library/ecode"
 	"wusthelper-manager-go/library/log"
 )
@@ -19,6 +21,8 @@ const (
 	_totalUserCacheKey = "wusthelper-mp:user:total"
 
 	_adminConfigCacheKey = "wusthelper-mp:admin:config"
+
+	_studentProfileCacheKey = "wusthelper-manager:student:v2:%s" // 助手token的sha256
 )
 
 func (d *Dao) StoreWusthelperTokenCache(c *context.Context, token, oid string, ex time.Duration) error {
@@ -106,3 +110,38 @@ func (d *Dao) IncreaseTotalUserCount(ctx *context.Context) (err error) {
 
 	return nil
 }
+
+// GetStudentProfileCache 获取助手token对应的学生信息缓存，没有缓存时返回nil
+func (d *Dao) GetStudentProfileCache(c *context.Context, tokenHash string) (*model.StudentProfile, error) {
+	data, err := d.redis.Get(*c, fmt.Sprintf(_studentProfileCacheKey, tokenHash)).Bytes()
+	if err != nil {
+		if err == redis.Nil {
+			return nil, nil
+		}
+		log.Error("获取学生信息缓存出现错误", zap.String("err", err.Error()))
+		return nil, ecode.InternalError
+	}
+
+	profile := new(model.StudentProfile)
+	if err = jsoniter.Unmarshal(data, profile); err != nil {
+		log.Warn("学生信息缓存格式不正确", zap.Error(err))
+		return nil, nil
+	}
+
+	return profile, nil
+}
+
+func (d *Dao) StoreStudentProfileCache(c *context.Context, tokenHash string, profile *model.StudentProfile, ex time.Duration) error {
+	data, err := jsoniter.Marshal(profile)
+	if err != nil {
+		return ecode.InternalError
+	}
+
+	err = d.redis.Set(*c, fmt.Sprintf(_studentProfileCacheKey, tokenHash), data, ex).Err()
+	if err != nil {
+		log.Error("缓存学生信息出现错误", zap.String("err", err.Error()))
+		return ecode.InternalError
+	}
+
+	return nil
+}
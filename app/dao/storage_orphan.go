@@ -0,0 +1,90 @@
+package dao
+
+import (
+	"context"
+	"go.uber.org/zap"
+	"time"
+	"wusthelper-manager-go/app/model"
+	"wusthelper-manager-go/library/ecode"
+	"wusthelper-manager-go/library/log"
+)
+
+const (
+	_storageGcLockKey        = "wusthelper-manager:storage-gc:lock"
+	_storageGcLockExpiration = time.Hour
+
+	// _storageOrphanBatchSize 批量写入和修改时每批的数量，避免sql过长
+	_storageOrphanBatchSize = 500
+)
+
+// GetStorageOrphanList 获取所有还未清理的无引用对象记录
+func (d *Dao) GetStorageOrphanList() (*[]model.StorageOrphan, error) {
+	result := make([]model.StorageOrphan, 0)
+	err := d.db.Where("status = ?", model.NormalStatus).Asc("id").Find(&result)
+	if err != nil {
+		log.Error("获取无引用对象列表时出现错误", zap.String("err", err.Error()))
+		return nil, ecode.InternalError
+	}
+
+	return &result, nil
+}
+
+func (d *Dao) AddStorageOrphan(orphans ...model.StorageOrphan) (int64, error) {
+	total := int64(0)
+	for start := 0; start < len(orphans); start += _storageOrphanBatchSize {
+		end := min(start+_storageOrphanBatchSize, len(orphans))
+		batch := orphans[start:end]
+		count, err := d.db.Insert(&batch)
+		if err != nil {
+			log.Error("添加无引用对象记录时出现错误", zap.Int("count", len(batch)), zap.String("err", err.Error()))
+			return total, ecode.InternalError
+		}
+		total += count
+	}
+
+	return total, nil
+}
+
+// UpdateStorageOrphanStatusBatch 修改还未清理的无引用对象记录的状态
+func (d *Dao) UpdateStorageOrphanStatusBatch(status int8, id ...int64) (int64, error) {
+	total, now := int64(0), time.Now()
+	for start := 0; start < len(id); start += _storageOrphanBatchSize {
+		end := min(start+_storageOrphanBatchSize, len(id))
+		count, err := d.db.Omit("id").
+			In("id", id[start:end]).
+			And("status = ?", model.NormalStatus).
+			Update(&model.StorageOrphan{Status: &status, UpdateTime: &now})
+		if err != nil {
+			log.Error("修改无引用对象记录状态时出现错误",
+				zap.Int("count", end-start),
+				zap.Int8("status", status),
+				zap.String("err", err.Error()),
+			)
+			return total, ecode.InternalError
+		}
+		total += count
+	}
+
+	return total, nil
+}
+
+// LockStorageGc 获取清理存储的锁，避免多个实例或手动触发时同时清理，已被占用时返回false
+func (d *Dao) LockStorageGc(c *context.Context) (bool, error) {
+	ok, err := d.redis.SetNX(*c, _storageGcLockKey, 1, _storageGcLockExpiration).Result()
+	if err != nil {
+		log.Error("获取清理存储的锁时出现错误", zap.Error(err))
+		return false, ecode.InternalError
+	}
+
+	return ok, nil
+}
+
+func (d *Dao) UnlockStorageGc(c *context.Context) error {
+	err := d.redis.Del(*c, _storageGcLockKey).Err()
+	if err != nil {
+		log.Error("释放清理存储的锁时出现错误", zap.Error(err))
+		return ecode.InternalError
+	}
+
+	return nil
+}
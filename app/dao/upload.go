@@ -0,0 +1,115 @@
+package dao
+
+import (
+	"context"
+	"fmt"
+	jsoniter "github.com/json-iterator/go"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+	"strconv"
+	"time"
+	"wusthelper-manager-go/app/model"
+	"wusthelper-manager-go/library/ecode"
+	"wusthelper-manager-go/library/log"
+)
+
+const (
+	_uploadSessionCacheKey     = "wusthelper-manager:upload:%s"
+	_uploadSessionPartCacheKey = "wusthelper-manager:upload:%s:parts"
+)
+
+func (d *Dao) StoreUploadSession(c *context.Context, session *model.UploadSession, ex time.Duration) error {
+	data, err := jsoniter.Marshal(session)
+	if err != nil {
+		log.Error("序列化上传会话出现错误", zap.String("id", session.Id), zap.Error(err))
+		return ecode.InternalError
+	}
+
+	pipe := d.redis.TxPipeline()
+	pipe.Set(*c, fmt.Sprintf(_uploadSessionCacheKey, session.Id), data, ex)
+	pipe.Expire(*c, fmt.Sprintf(_uploadSessionPartCacheKey, session.Id), ex)
+	_, err = pipe.Exec(*c)
+	if err != nil {
+		log.Error("缓存上传会话出现错误", zap.String("id", session.Id), zap.Error(err))
+		return ecode.InternalError
+	}
+
+	return nil
+}
+
+func (d *Dao) GetUploadSession(c *context.Context, id string) (*model.UploadSession, error) {
+	data, err := d.redis.Get(*c, fmt.Sprintf(_uploadSessionCacheKey, id)).Bytes()
+	if err != nil {
+		if err == redis.Nil {
+			return nil, nil
+		}
+		log.Error("获取上传会话出现错误", zap.String("id", id), zap.Error(err))
+		return nil, ecode.InternalError
+	}
+
+	session := new(model.UploadSession)
+	err = jsoniter.Unmarshal(data, session)
+	if err != nil {
+		log.Error("反序列化上传会话出现错误", zap.String("id", id), zap.Error(err))
+		return nil, ecode.InternalError
+	}
+
+	return session, nil
+}
+
+func (d *Dao) AddUploadedPart(c *context.Context, id string, partNumber int, ex time.Duration) error {
+	key := fmt.Sprintf(_uploadSessionPartCacheKey, id)
+	pipe := d.redis.TxPipeline()
+	pipe.SAdd(*c, key, partNumber)
+	pipe.Expire(*c, key, ex)
+	pipe.Expire(*c, fmt.Sprintf(_uploadSessionCacheKey, id), ex)
+	_, err := pipe.Exec(*c)
+	if err != nil {
+		log.Error("记录已上传分片出现错误", zap.String("id", id), zap.Int("part", partNumber), zap.Error(err))
+		return ecode.InternalError
+	}
+
+	return nil
+}
+
+func (d *Dao) GetUploadedParts(c *context.Context, id string) ([]int, error) {
+	members, err := d.redis.SMembers(*c, fmt.Sprintf(_uploadSessionPartCacheKey, id)).Result()
+	if err != nil {
+		log.Error("获取已上传分片出现错误", zap.String("id", id), zap.Error(err))
+		return nil, ecode.InternalError
+	}
+
+	parts := make([]int, 0, len(members))
+	for _, member := range members {
+		part, err := strconv.Atoi(member)
+		if err != nil {
+			continue
+		}
+		parts = append(parts, part)
+	}
+
+	return parts, nil
+}
+
+func (d *Dao) HasUploadSession(c *context.Context, id string) (bool, error) {
+	count, err := d.redis.Exists(*c, fmt.Sprintf(_uploadSessionCacheKey, id)).Result()
+	if err != nil {
+		log.Error("查询上传会话是否存在出现错误", zap.String("id", id), zap.Error(err))
+		return false, ecode.InternalError
+	}
+
+	return count > 0, nil
+}
+
+func (d *Dao) DeleteUploadSession(c *context.Context, id string) error {
+	err := d.redis.Del(*c,
+		fmt.Sprintf(_uploadSessionCacheKey, id),
+		fmt.Sprintf(_uploadSessionPartCacheKey, id),
+	).Err()
+	if err != nil {
+		log.Error("删除上传会话出现错误", zap.String("id", id), zap.Error(err))
+		return ecode.InternalError
+	}
+
+	return nil
+}
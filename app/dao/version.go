@@ -9,24 +9,25 @@ import (
 	"xorm.io/xorm"
 )
 
-func (d *Dao) GetLatestVersion(platform ...string) (*model.Version, error) {
-	result := new(model.Version)
-	session := d.db.Where("status = ?", model.VersionPublishedStatus).Desc("version_text")
-	if platform != nil && len(platform) > 0 {
-		session.In("platform", platform)
-	}
-
-	has, err := session.Get(result)
+// GetPublishedVersionList 获取平台下各渠道已发布的版本，按语义化版本号的数字部分降序，先行版本号的比较由service处理
+func (d *Dao) GetPublishedVersionList(platform string, channels ...string) (*[]model.Version, error) {
+	result := make([]model.Version, 0)
+	err := d.db.
+		Where("status = ?", model.VersionPublishedStatus).
+		And("platform = ?", platform).
+		In("channel", channels).
+		Desc("version_major", "version_minor", "version_patch").
+		Find(&result)
 	if err != nil {
-		log.Error("获取版本信息时出现错误", zap.Any("platform", platform), zap.String("err", err.Error()))
+		log.Error("获取版本信息时出现错误",
+			zap.String("platform", platform),
+			zap.Strings("channels", channels),
+			zap.String("err", err.Error()),
+		)
 		return nil, ecode.InternalError
 	}
 
-	if !has {
-		return nil, nil
-	}
-
-	return result, nil
+	return &result, nil
 }
 
 func (d *Dao) GetVersion(id int64) (*model.Version, error) {
@@ -70,29 +71,54 @@ func (d *Dao) GetVersionList(paging common.Pagination, platform string) (*[]mode
 	return &result, total, nil
 }
 
-func (d *Dao) AddVersion(version *model.Version) (int64, error) {
-	count, err := d.db.InsertOne(version)
-	if err != nil {
-		log.Error("添加版本信息时出现错误", zap.Any("entity", version), zap.String("err", err.Error()))
-		return 0, ecode.InternalError
-	}
+// AddVersion 添加版本信息，同时添加版本的下载文件（如ipa的manifest.plist）和上传版本文件等后台任务
+func (d *Dao) AddVersion(version *model.Version, artifacts []model.VersionArtifact, jobs ...model.Job) (int64, error) {
+	return d.withJobs("添加版本信息", jobs, func(session *xorm.Session) (int64, error) {
+		count, err := session.InsertOne(version)
+		if err != nil {
+			log.Error("添加版本信息时出现错误", zap.Any("entity", version), zap.String("err", err.Error()))
+			return 0, ecode.InternalError
+		}
 
-	return count, nil
+		err = replaceVersionArtifacts(session, version.ID, artifacts)
+		if err != nil {
+			return 0, err
+		}
+
+		return count, nil
+	})
 }
 
-func (d *Dao) UpdateVersion(version *model.Version) (int64, error) {
-	count, err := d.db.Omit("id").NoVersionCheck().
-		Where("id = ?", version.ID).And("status != ?", model.DeletedStatus).
-		Update(version)
-	if err != nil {
-		log.Error("修改版本信息时出现错误", zap.Any("entity", version), zap.String("err", err.Error()))
-		return 0, err
+// UpdateVersion 修改版本信息，有artifacts或jobs时在同一个事务中替换同类型的下载文件、添加后台任务
+func (d *Dao) UpdateVersion(version *model.Version, artifacts []model.VersionArtifact, jobs ...model.Job) (int64, error) {
+	update := func(session *xorm.Session) (int64, error) {
+		count, err := session.Omit("id").NoVersionCheck().
+			Where("id = ?", version.ID).And("status != ?", model.DeletedStatus).
+			Update(version)
+		if err != nil {
+			log.Error("修改版本信息时出现错误", zap.Any("entity", version), zap.String("err", err.Error()))
+			return 0, err
+		}
+
+		err = replaceVersionArtifacts(session, version.ID, artifacts)
+		if err != nil {
+			return 0, err
+		}
+
+		return count, nil
 	}
 
-	return count, nil
+	if len(jobs) == 0 && len(artifacts) == 0 {
+		session := d.db.NewSession()
+		defer session.Close()
+		return update(session)
+	}
+
+	return d.withJobs("修改版本信息", jobs, update)
 }
 
-func (d *Dao) PublishVersion(id int64) (int64, error) {
+// PublishVersion 发布版本，并记录发布历史，history需要填好ID和CreateTime，其余字段由这里填写
+func (d *Dao) PublishVersion(id int64, history *model.VersionPublishHistory) (int64, error) {
 	transaction := d.db.NewSession()
 	defer func(transaction *xorm.Session) {
 		err := transaction.Close()
@@ -106,10 +132,11 @@ func (d *Dao) PublishVersion(id int64) (int64, error) {
 		return 0, ecode.InternalError
 	}
 
-	// 先获取一遍待发布的版本信息，获取其平台，修改该平台其他版本状态为普通状态，当前版本设置为发布，保证一个平台只有一个已发布版本
+	// 先获取一遍待发布的版本信息，获取其平台和渠道，修改该平台该渠道其他版本状态为普通状态，当前版本设置为发布，
+	// 保证一个平台的一个渠道只有一个已发布版本
 	version := new(model.Version)
 	has, err := transaction.
-		Cols("platform").
+		Cols("platform", "channel").
 		Where("id = ?", id).And("status != ?", model.DeletedStatus).
 		Get(version)
 
@@ -119,20 +146,49 @@ func (d *Dao) PublishVersion(id int64) (int64, error) {
 		)
 		return 0, ecode.InternalError
 	} else if !has {
-		log.Error("发布版本信息时出现错误，id不存在", zap.Int64("id", id), zap.String("err", err.Error()))
+		log.Error("发布版本信息时出现错误，id不存在", zap.Int64("id", id))
 		return 0, ecode.QueryFailed
 	}
 
-	// 修改当前平台其他版本状态为普通状态
+	// 记录发布历史，重复发布当前已发布的版本时不记录
 	publishedStatus, normalStatus := model.VersionPublishedStatus, model.NormalStatus
+	current := new(model.Version)
+	hasCurrent, err := transaction.
+		Cols("id").
+		Where("platform = ?", *version.Platform).And("channel = ?", *version.Channel).
+		And("status = ?", publishedStatus).
+		Get(current)
+	if err != nil {
+		log.Error("发布版本信息时出现错误，获取当前已发布版本时出现异常", zap.Int64("id", id), zap.String("err", err.Error()))
+		return 0, ecode.InternalError
+	}
+
+	if !hasCurrent || current.ID != id {
+		previousId, action := int64(0), model.VersionPublishActionPublish
+		if hasCurrent {
+			previousId = current.ID
+		}
+
+		history.Platform, history.Channel = version.Platform, version.Channel
+		history.VersionId, history.PreviousVersionId, history.Action = &id, &previousId, &action
+		_, err = transaction.InsertOne(history)
+		if err != nil {
+			log.Error("发布版本信息时出现错误，记录发布历史时出现异常", zap.Int64("id", id), zap.String("err", err.Error()))
+			return 0, ecode.InternalError
+		}
+	}
+
+	// 修改当前平台当前渠道其他已发布和灰度中的版本状态为普通状态
 	_, err = transaction.Omit("id").
-		In("platform", *version.Platform).And("status = ?", publishedStatus).
+		Where("platform = ?", *version.Platform).And("channel = ?", *version.Channel).
+		In("status", publishedStatus, model.VersionRollingStatus).
 		Update(&model.Version{Status: &normalStatus})
 
 	if err != nil {
 		log.Error("发布版本信息时出现错误，切换其他版本信息状态时出现异常",
 			zap.Int64("id", id),
 			zap.String("platform", *version.Platform),
+			zap.String("channel", *version.Channel),
 			zap.String("err", err.Error()),
 		)
 		return 0, ecode.InternalError
@@ -197,3 +253,157 @@ func (d *Dao) DeleteVersion(id int64) error {
 
 	return nil
 }
+
+// GetMandatoryVersionList 获取平台下各渠道发布过的强制更新版本，当前已发布或有发布记录的才算，
+// 未发布的草稿和灰度中的版本即使标记了强制更新也不生效
+func (d *Dao) GetMandatoryVersionList(platform string, channels ...string) (*[]model.Version, error) {
+	result := make([]model.Version, 0)
+	err := d.db.
+		Where("platform = ?", platform).
+		In("channel", channels).
+		And("mandatory = ?", true).
+		And("status != ?", model.DeletedStatus).
+		And("(status = ? OR id IN (SELECT version_id FROM version_publish_history))", model.VersionPublishedStatus).
+		Find(&result)
+	if err != nil {
+		log.Error("获取强制更新版本列表时出现错误", zap.String("platform", platform), zap.String("err", err.Error()))
+		return nil, ecode.InternalError
+	}
+
+	return &result, nil
+}
+
+// GetRollingVersion 获取平台某个渠道灰度发布中的版本
+func (d *Dao) GetRollingVersion(platform, channel string) (*model.Version, error) {
+	result := new(model.Version)
+	has, err := d.db.
+		Where("platform = ?", platform).And("channel = ?", channel).
+		And("status = ?", model.VersionRollingStatus).
+		Get(result)
+	if err != nil {
+		log.Error("获取灰度版本信息时出现错误", zap.String("platform", platform), zap.String("err", err.Error()))
+		return nil, ecode.InternalError
+	} else if !has {
+		return nil, nil
+	}
+
+	return result, nil
+}
+
+// StartVersionRollout 开始灰度发布一个普通状态的版本，同平台同渠道其他灰度中的版本恢复为普通状态，保证一个平台的一个渠道只有一个灰度版本
+func (d *Dao) StartVersionRollout(id int64, percent int) (int64, error) {
+	transaction := d.db.NewSession()
+	defer func(transaction *xorm.Session) {
+		err := transaction.Close()
+		if err != nil {
+			log.Warn("灰度发布版本时出现错误，事务session关闭时出现异常", zap.Int64("id", id), zap.Error(err))
+		}
+	}(transaction)
+
+	if err := transaction.Begin(); err != nil {
+		log.Error("灰度发布版本时出现错误，事务开启时出现异常", zap.Int64("id", id), zap.Error(err))
+		return 0, ecode.InternalError
+	}
+
+	version := new(model.Version)
+	has, err := transaction.
+		Cols("platform", "channel").
+		Where("id = ?", id).And("status = ?", model.NormalStatus).
+		Get(version)
+	if err != nil {
+		log.Error("灰度发布版本时出现错误，获取版本信息时出现异常", zap.Int64("id", id), zap.String("err", err.Error()))
+		return 0, ecode.InternalError
+	} else if !has {
+		return 0, nil
+	}
+
+	rollingStatus, normalStatus, paused := model.VersionRollingStatus, model.NormalStatus, false
+	_, err = transaction.Omit("id").
+		Where("platform = ?", *version.Platform).And("channel = ?", *version.Channel).
+		And("status = ?", rollingStatus).
+		Update(&model.Version{Status: &normalStatus})
+	if err != nil {
+		log.Error("灰度发布版本时出现错误，切换其他灰度版本状态时出现异常",
+			zap.Int64("id", id),
+			zap.String("platform", *version.Platform),
+			zap.String("err", err.Error()),
+		)
+		return 0, ecode.InternalError
+	}
+
+	count, err := transaction.Omit("id").
+		MustCols("rollout_paused").
+		Where("id = ?", id).And("status = ?", model.NormalStatus).
+		Update(&model.Version{Status: &rollingStatus, RolloutPercent: &percent, RolloutPaused: &paused})
+	if err != nil {
+		log.Error("灰度发布版本时出现错误", zap.Int64("id", id), zap.String("err", err.Error()))
+		return 0, ecode.InternalError
+	}
+
+	err = transaction.Commit()
+	if err != nil {
+		log.Error("灰度发布版本时出现错误，提交事务时出现异常", zap.Int64("id", id), zap.Error(err))
+		return 0, ecode.InternalError
+	}
+
+	return count, nil
+}
+
+// UpdateVersionRollout 修改灰度中版本的灰度比例或暂停状态，参数为nil时不修改
+func (d *Dao) UpdateVersionRollout(id int64, percent *int, paused *bool) (int64, error) {
+	session := d.db.Omit("id").Where("id = ?", id).And("status = ?", model.VersionRollingStatus)
+	if paused != nil {
+		session.MustCols("rollout_paused")
+	}
+
+	count, err := session.Update(&model.Version{RolloutPercent: percent, RolloutPaused: paused})
+	if err != nil {
+		log.Error("修改灰度版本信息时出现错误", zap.Int64("id", id), zap.String("err", err.Error()))
+		return 0, ecode.InternalError
+	}
+
+	return count, nil
+}
+
+// StopVersionRollout 停止灰度，版本恢复为普通状态
+func (d *Dao) StopVersionRollout(id int64) (int64, error) {
+	normalStatus, percent := model.NormalStatus, 0
+	count, err := d.db.Omit("id").
+		MustCols("rollout_percent").
+		Where("id = ?", id).And("status = ?", model.VersionRollingStatus).
+		Update(&model.Version{Status: &normalStatus, RolloutPercent: &percent})
+	if err != nil {
+		log.Error("停止灰度发布时出现错误", zap.Int64("id", id), zap.String("err", err.Error()))
+		return 0, ecode.InternalError
+	}
+
+	return count, nil
+}
+
+// RestoreVersionFile 上传任务失败清空文件记录后手动重试成功时，恢复版本的文件记录，已经换了新文件的不修改
+func (d *Dao) RestoreVersionFile(id int64, fileKey string) (int64, error) {
+	count, err := d.db.Omit("id").
+		Where("id = ?", id).And("status != ?", model.DeletedStatus).
+		And("file IS NULL OR file = ''").
+		Update(&model.Version{File: &fileKey})
+	if err != nil {
+		log.Error("恢复版本文件记录时出现错误", zap.Int64("id", id), zap.String("err", err.Error()))
+		return 0, ecode.InternalError
+	}
+
+	return count, nil
+}
+
+// GetVersionFileList 获取所有未删除版本的文件，用于清理存储中没有被引用的对象
+func (d *Dao) GetVersionFileList() ([]string, error) {
+	result := make([]string, 0)
+	err := d.db.Table(&model.Version{}).Cols("file").
+		Where("status != ?", model.DeletedStatus).And("file != ''").
+		Find(&result)
+	if err != nil {
+		log.Error("获取版本文件列表时出现错误", zap.String("err", err.Error()))
+		return nil, ecode.InternalError
+	}
+
+	return result, nil
+}
@@ -0,0 +1,132 @@
+package dao
+
+import (
+	"go.uber.org/zap"
+	"wusthelper-manager-go/app/model"
+	"wusthelper-manager-go/library/ecode"
+	"wusthelper-manager-go/library/log"
+	"xorm.io/xorm"
+)
+
+func (d *Dao) GetVersionArtifact(id int64) (*model.VersionArtifact, error) {
+	result := new(model.VersionArtifact)
+	has, err := d.db.
+		Where("id = ?", id).And("status != ?", model.DeletedStatus).
+		Get(result)
+	if err != nil {
+		log.Error("获取版本文件时出现错误", zap.Int64("id", id), zap.String("err", err.Error()))
+		return nil, ecode.InternalError
+	} else if !has {
+		return nil, nil
+	}
+
+	return result, nil
+}
+
+// GetVersionArtifactList 获取版本所有未删除的文件，readyOnly为true时只获取已上传完成的文件
+func (d *Dao) GetVersionArtifactList(versionId int64, readyOnly bool) (*[]model.VersionArtifact, error) {
+	result := make([]model.VersionArtifact, 0)
+	session := d.db.Where("version_id = ?", versionId).And("status != ?", model.DeletedStatus)
+	if readyOnly {
+		session.And("ready = ?", true)
+	}
+	err := session.Asc("id").Find(&result)
+	if err != nil {
+		log.Error("获取版本文件列表时出现错误", zap.Int64("version_id", versionId), zap.String("err", err.Error()))
+		return nil, ecode.InternalError
+	}
+
+	return &result, nil
+}
+
+func (d *Dao) AddVersionArtifact(artifact *model.VersionArtifact) (int64, error) {
+	count, err := d.db.InsertOne(artifact)
+	if err != nil {
+		log.Error("添加版本文件时出现错误", zap.Any("entity", artifact), zap.String("err", err.Error()))
+		return 0, ecode.InternalError
+	}
+
+	return count, nil
+}
+
+// AddVersionArtifacts 在同一个事务中添加版本文件和上传文件的后台任务，版本已有的同类型文件标记为删除
+func (d *Dao) AddVersionArtifacts(versionId int64, artifacts []model.VersionArtifact, jobs ...model.Job) (int64, error) {
+	return d.withJobs("添加版本文件", jobs, func(session *xorm.Session) (int64, error) {
+		err := replaceVersionArtifacts(session, versionId, artifacts)
+		if err != nil {
+			return 0, err
+		}
+
+		return int64(len(artifacts)), nil
+	})
+}
+
+// replaceVersionArtifacts 在事务中添加版本文件，版本已有的同类型文件标记为删除
+func replaceVersionArtifacts(session *xorm.Session, versionId int64, artifacts []model.VersionArtifact) error {
+	if len(artifacts) == 0 {
+		return nil
+	}
+
+	types := make([]string, len(artifacts))
+	for i, artifact := range artifacts {
+		types[i] = *artifact.Type
+	}
+
+	status := model.DeletedStatus
+	_, err := session.Omit("id").
+		Where("version_id = ?", versionId).And("status != ?", model.DeletedStatus).In("type", types).
+		Update(&model.VersionArtifact{Status: &status})
+	if err != nil {
+		log.Error("删除同类型的版本文件时出现错误", zap.Int64("version_id", versionId), zap.String("err", err.Error()))
+		return ecode.InternalError
+	}
+
+	_, err = session.Insert(artifacts)
+	if err != nil {
+		log.Error("添加版本文件时出现错误", zap.Any("entity", artifacts), zap.String("err", err.Error()))
+		return ecode.InternalError
+	}
+
+	return nil
+}
+
+// SetVersionArtifactReady 版本文件已上传到存储
+func (d *Dao) SetVersionArtifactReady(id int64) (int64, error) {
+	ready := true
+	count, err := d.db.Omit("id").
+		Where("id = ?", id).And("status != ?", model.DeletedStatus).
+		Update(&model.VersionArtifact{Ready: &ready})
+	if err != nil {
+		log.Error("修改版本文件时出现错误", zap.Int64("id", id), zap.String("err", err.Error()))
+		return 0, ecode.InternalError
+	}
+
+	return count, nil
+}
+
+func (d *Dao) DeleteVersionArtifact(id int64) (int64, error) {
+	status := model.DeletedStatus
+	count, err := d.db.Omit("id").
+		Where("id = ?", id).And("status != ?", model.DeletedStatus).
+		Update(&model.VersionArtifact{Status: &status})
+	if err != nil {
+		log.Error("删除版本文件时出现错误", zap.Int64("id", id), zap.String("err", err.Error()))
+		return 0, ecode.InternalError
+	}
+
+	return count, nil
+}
+
+// GetVersionArtifactFileList 获取所有未删除的版本下载文件，用于清理存储中没有被引用的对象
+func (d *Dao) GetVersionArtifactFileList() ([]string, error) {
+	result := make([]string, 0)
+	err := d.db.Table(&model.VersionArtifact{}).Cols("file").
+		Where("status != ?", model.DeletedStatus).And("file != ''").
+		Find(&result)
+	if err != nil {
+		log.Error("获取版本下载文件列表时出现错误", zap.String("err", err.Error()))
+		return nil, ecode.InternalError
+	}
+
+	return result, nil
+}
@@ -0,0 +1,99 @@
+package dao
+
+import (
+	"go.uber.org/zap"
+	"wusthelper-manager-go/app/model"
+	"wusthelper-manager-go/library/ecode"
+	"wusthelper-manager-go/library/log"
+	"xorm.io/xorm"
+)
+
+// GetVersionPatch 获取从基础版本号升级到版本的增量包，没有时返回nil
+func (d *Dao) GetVersionPatch(versionId int64, baseVersionText string) (*model.VersionPatch, error) {
+	result := new(model.VersionPatch)
+	has, err := d.db.
+		Where("version_id = ?", versionId).And("base_version_text = ?", baseVersionText).
+		And("status != ?", model.DeletedStatus).
+		Desc("id").
+		Get(result)
+	if err != nil {
+		log.Error("获取版本增量包时出现错误",
+			zap.Int64("version_id", versionId),
+			zap.String("base_version", baseVersionText),
+			zap.String("err", err.Error()),
+		)
+		return nil, ecode.InternalError
+	} else if !has {
+		return nil, nil
+	}
+
+	return result, nil
+}
+
+// GetVersionPatchList 获取版本所有未删除的增量包
+func (d *Dao) GetVersionPatchList(versionId int64) (*[]model.VersionPatch, error) {
+	result := make([]model.VersionPatch, 0)
+	err := d.db.
+		Where("version_id = ?", versionId).And("status != ?", model.DeletedStatus).
+		Find(&result)
+	if err != nil {
+		log.Error("获取版本增量包列表时出现错误", zap.Int64("version_id", versionId), zap.String("err", err.Error()))
+		return nil, ecode.InternalError
+	}
+
+	return &result, nil
+}
+
+// ReplaceVersionPatch 在事务中删除同一对版本之间原有的增量包，并添加新的增量包
+func (d *Dao) ReplaceVersionPatch(patch *model.VersionPatch) error {
+	transaction := d.db.NewSession()
+	defer func(transaction *xorm.Session) {
+		err := transaction.Close()
+		if err != nil {
+			log.Warn("保存版本增量包时出现错误，事务session关闭时出现异常", zap.Int64("id", patch.ID), zap.Error(err))
+		}
+	}(transaction)
+
+	if err := transaction.Begin(); err != nil {
+		log.Error("保存版本增量包时出现错误，事务开启时出现异常", zap.Int64("id", patch.ID), zap.Error(err))
+		return ecode.InternalError
+	}
+
+	deletedStatus := model.DeletedStatus
+	_, err := transaction.Omit("id").
+		Where("version_id = ?", *patch.VersionId).And("base_version_id = ?", *patch.BaseVersionId).
+		And("status != ?", model.DeletedStatus).
+		Update(&model.VersionPatch{Status: &deletedStatus})
+	if err != nil {
+		log.Error("保存版本增量包时出现错误，删除原有增量包时出现异常", zap.Int64("id", patch.ID), zap.String("err", err.Error()))
+		return ecode.InternalError
+	}
+
+	_, err = transaction.InsertOne(patch)
+	if err != nil {
+		log.Error("保存版本增量包时出现错误", zap.Any("entity", patch), zap.String("err", err.Error()))
+		return ecode.InternalError
+	}
+
+	err = transaction.Commit()
+	if err != nil {
+		log.Error("保存版本增量包时出现错误，提交事务时出现异常", zap.Int64("id", patch.ID), zap.Error(err))
+		return ecode.InternalError
+	}
+
+	return nil
+}
+
+// GetVersionPatchFileList 获取所有未删除的增量包文件，用于清理存储中没有被引用的对象
+func (d *Dao) GetVersionPatchFileList() ([]string, error) {
+	result := make([]string, 0)
+	err := d.db.Table(&model.VersionPatch{}).Cols("file").
+		Where("status != ?", model.DeletedStatus).And("file != ''").
+		Find(&result)
+	if err != nil {
+		log.Error("获取增量包文件列表时出现错误", zap.String("err", err.Error()))
+		return nil, ecode.InternalError
+	}
+
+	return result, nil
+}
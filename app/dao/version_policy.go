@@ -0,0 +1,55 @@
+package dao
+
+import (
+	"go.uber.org/zap"
+	"wusthelper-manager-go/app/model"
+	"wusthelper-manager-go/library/ecode"
+	"wusthelper-manager-go/library/log"
+)
+
+func (d *Dao) GetVersionPolicy(platform string) (*model.VersionPolicy, error) {
+	result := new(model.VersionPolicy)
+	has, err := d.db.Where("platform = ?", platform).Get(result)
+	if err != nil {
+		log.Error("获取版本策略时出现错误", zap.String("platform", platform), zap.String("err", err.Error()))
+		return nil, ecode.InternalError
+	} else if !has {
+		return nil, nil
+	}
+
+	return result, nil
+}
+
+func (d *Dao) GetVersionPolicyList() (*[]model.VersionPolicy, error) {
+	result := make([]model.VersionPolicy, 0)
+	err := d.db.Asc("platform").Find(&result)
+	if err != nil {
+		log.Error("获取版本策略列表时出现错误", zap.String("err", err.Error()))
+		return nil, ecode.InternalError
+	}
+
+	return &result, nil
+}
+
+func (d *Dao) AddVersionPolicy(policy *model.VersionPolicy) (int64, error) {
+	count, err := d.db.InsertOne(policy)
+	if err != nil {
+		log.Error("添加版本策略时出现错误", zap.Any("entity", policy), zap.String("err", err.Error()))
+		return 0, ecode.InternalError
+	}
+
+	return count, nil
+}
+
+func (d *Dao) UpdateVersionPolicy(policy *model.VersionPolicy) (int64, error) {
+	count, err := d.db.Omit("id", "platform", "create_time").
+		MustCols("min_version").
+		Where("platform = ?", *policy.Platform).
+		Update(policy)
+	if err != nil {
+		log.Error("修改版本策略时出现错误", zap.Any("entity", policy), zap.String("err", err.Error()))
+		return 0, ecode.InternalError
+	}
+
+	return count, nil
+}
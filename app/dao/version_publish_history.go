@@ -0,0 +1,219 @@
+package dao
+
+import (
+	"go.uber.org/zap"
+	"wusthelper-manager-go/app/model"
+	"wusthelper-manager-go/common"
+	"wusthelper-manager-go/library/ecode"
+	"wusthelper-manager-go/library/log"
+	"xorm.io/xorm"
+)
+
+func (d *Dao) GetVersionPublishHistoryList(paging common.Pagination, platform, channel string) (*[]model.VersionPublishHistory, int64, error) {
+	countSession := d.db.NewSession()
+	defer countSession.Close()
+	if platform != "" {
+		countSession.Where("platform = ?", platform)
+	}
+	if channel != "" {
+		countSession.And("channel = ?", channel)
+	}
+
+	total, err := countSession.Count(model.VersionPublishHistory{})
+	if err != nil {
+		log.Error("获取版本发布历史数量时出现错误", zap.String("err", err.Error()))
+		return nil, 0, ecode.InternalError
+	}
+
+	result := make([]model.VersionPublishHistory, 0)
+	querySession := d.db.Desc("id")
+	if platform != "" {
+		querySession.Where("platform = ?", platform)
+	}
+	if channel != "" {
+		querySession.And("channel = ?", channel)
+	}
+	err = querySession.Limit(paging.PageSize, paging.PageSize*(paging.Page-1)).Find(&result)
+	if err != nil {
+		log.Error("获取版本发布历史列表时出现错误", zap.String("err", err.Error()))
+		return nil, 0, ecode.InternalError
+	}
+
+	return &result, total, nil
+}
+
+// RollbackVersion 在事务中把平台某个渠道的已发布版本回滚到上一次发布的版本，并记录回滚历史。
+// beforeCommit在数据库修改完成、事务提交前调用，返回错误时回滚事务，用于保证外部资源（如发布文件链接）修改成功后才提交。
+// 返回被恢复的版本，没有可回滚的版本时返回ecode.NoVersionToRollback
+func (d *Dao) RollbackVersion(platform, channel string, history *model.VersionPublishHistory,
+	beforeCommit func(current, target *model.Version) error) (*model.Version, error) {
+	transaction := d.db.NewSession()
+	defer func(transaction *xorm.Session) {
+		err := transaction.Close()
+		if err != nil {
+			log.Warn("回滚版本时出现错误，事务session关闭时出现异常", zap.String("platform", platform), zap.Error(err))
+		}
+	}(transaction)
+
+	if err := transaction.Begin(); err != nil {
+		log.Error("回滚版本时出现错误，事务开启时出现异常", zap.String("platform", platform), zap.Error(err))
+		return nil, ecode.InternalError
+	}
+
+	publishedStatus, normalStatus := model.VersionPublishedStatus, model.NormalStatus
+	current := new(model.Version)
+	has, err := transaction.
+		Where("platform = ?", platform).And("channel = ?", channel).
+		And("status = ?", publishedStatus).
+		ForUpdate().
+		Get(current)
+	if err != nil {
+		log.Error("回滚版本时出现错误，获取当前已发布版本时出现异常", zap.String("platform", platform), zap.String("err", err.Error()))
+		return nil, ecode.InternalError
+	} else if !has {
+		return nil, ecode.NoVersionToRollback
+	}
+
+	// 最近一次发布记录需要对应当前已发布的版本，其previous_version_id即为要恢复的版本
+	latestHistory := new(model.VersionPublishHistory)
+	has, err = transaction.
+		Where("platform = ?", platform).And("channel = ?", channel).
+		Desc("id").
+		Get(latestHistory)
+	if err != nil {
+		log.Error("回滚版本时出现错误，获取发布历史时出现异常", zap.String("platform", platform), zap.String("err", err.Error()))
+		return nil, ecode.InternalError
+	} else if !has || *latestHistory.VersionId != current.ID || *latestHistory.PreviousVersionId == 0 {
+		return nil, ecode.NoVersionToRollback
+	}
+
+	target := new(model.Version)
+	has, err = transaction.
+		Where("id = ?", *latestHistory.PreviousVersionId).And("status != ?", model.DeletedStatus).
+		Get(target)
+	if err != nil {
+		log.Error("回滚版本时出现错误，获取要恢复的版本时出现异常", zap.String("platform", platform), zap.String("err", err.Error()))
+		return nil, ecode.InternalError
+	} else if !has {
+		return nil, ecode.NoVersionToRollback
+	}
+
+	// 被恢复版本发布前的版本，用于继续回滚
+	targetHistory := new(model.VersionPublishHistory)
+	has, err = transaction.
+		Where("version_id = ?", target.ID).And("action = ?", model.VersionPublishActionPublish).
+		Desc("id").
+		Get(targetHistory)
+	if err != nil {
+		log.Error("回滚版本时出现错误，获取发布历史时出现异常", zap.String("platform", platform), zap.String("err", err.Error()))
+		return nil, ecode.InternalError
+	}
+
+	previousId, action := int64(0), model.VersionPublishActionRollback
+	if has {
+		previousId = *targetHistory.PreviousVersionId
+	}
+
+	_, err = transaction.Omit("id").
+		Where("id = ?", current.ID).
+		Update(&model.Version{Status: &normalStatus})
+	if err != nil {
+		log.Error("回滚版本时出现错误，修改当前版本状态时出现异常", zap.Int64("id", current.ID), zap.String("err", err.Error()))
+		return nil, ecode.InternalError
+	}
+
+	_, err = transaction.Omit("id").
+		Where("id = ?", target.ID).
+		Update(&model.Version{Status: &publishedStatus})
+	if err != nil {
+		log.Error("回滚版本时出现错误，修改恢复版本状态时出现异常", zap.Int64("id", target.ID), zap.String("err", err.Error()))
+		return nil, ecode.InternalError
+	}
+
+	history.Platform, history.Channel = &platform, &channel
+	history.VersionId, history.PreviousVersionId, history.Action = &target.ID, &previousId, &action
+	_, err = transaction.InsertOne(history)
+	if err != nil {
+		log.Error("回滚版本时出现错误，记录发布历史时出现异常", zap.String("platform", platform), zap.String("err", err.Error()))
+		return nil, ecode.InternalError
+	}
+
+	if err = beforeCommit(current, target); err != nil {
+		return nil, err
+	}
+
+	err = transaction.Commit()
+	if err != nil {
+		log.Error("回滚版本时出现错误，提交事务时出现异常", zap.String("platform", platform), zap.Error(err))
+		return nil, ecode.InternalError
+	}
+
+	*target.Status = publishedStatus
+	return target, nil
+}
+
+// GetRecentPublishedVersionList 按发布时间倒序获取平台最近发布过的limit个不同版本，不包括已删除的版本
+func (d *Dao) GetRecentPublishedVersionList(platform string, limit int) (*[]model.Version, error) {
+	historyList := make([]model.VersionPublishHistory, 0)
+	// 同一版本可能发布过多次，多取一些记录再去重
+	err := d.db.Cols("version_id").
+		Where("platform = ?", platform).And("action = ?", model.VersionPublishActionPublish).
+		Desc("id").
+		Limit(limit * 4).
+		Find(&historyList)
+	if err != nil {
+		log.Error("获取最近发布的版本时出现错误", zap.String("platform", platform), zap.String("err", err.Error()))
+		return nil, ecode.InternalError
+	}
+
+	ids := make([]int64, 0, limit)
+	for _, history := range historyList {
+		duplicated := false
+		for _, id := range ids {
+			if id == *history.VersionId {
+				duplicated = true
+				break
+			}
+		}
+
+		if !duplicated {
+			ids = append(ids, *history.VersionId)
+		}
+	}
+
+	if len(ids) == 0 {
+		return &[]model.Version{}, nil
+	}
+
+	versionList := make([]model.Version, 0, len(ids))
+	err = d.db.In("id", ids).And("status != ?", model.DeletedStatus).Find(&versionList)
+	if err != nil {
+		log.Error("获取最近发布的版本时出现错误", zap.String("platform", platform), zap.String("err", err.Error()))
+		return nil, ecode.InternalError
+	}
+
+	versionMap := make(map[int64]model.Version, len(versionList))
+	for _, version := range versionList {
+		versionMap[version.ID] = version
+	}
+
+	result := make([]model.Version, 0, limit)
+	for _, id := range ids {
+		if version, ok := versionMap[id]; ok && len(result) < limit {
+			result = append(result, version)
+		}
+	}
+
+	return &result, nil
+}
+
+// HasVersionPublishHistory 版本是否发布过，被新版本替换后状态会恢复为普通，需要通过发布历史判断
+func (d *Dao) HasVersionPublishHistory(versionId int64) (bool, error) {
+	has, err := d.db.Where("version_id = ?", versionId).Exist(&model.VersionPublishHistory{})
+	if err != nil {
+		log.Error("查询版本发布历史时出现错误", zap.Int64("versionId", versionId), zap.String("err", err.Error()))
+		return false, ecode.InternalError
+	}
+
+	return has, nil
+}
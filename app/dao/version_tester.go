@@ -0,0 +1,99 @@
+package dao
+
+import (
+	"go.uber.org/zap"
+	"wusthelper-manager-go/app/model"
+	"wusthelper-manager-go/common"
+	"wusthelper-manager-go/library/ecode"
+	"wusthelper-manager-go/library/log"
+)
+
+// GetVersionTesterList 按标识获取测试人员，identifier为空时获取全部
+func (d *Dao) GetVersionTesterList(paging common.Pagination, identifier string) (*[]model.VersionTester, int64, error) {
+	countSession := d.db.Where("status != ?", model.DeletedStatus)
+	if identifier != "" {
+		countSession.And("identifier = ?", identifier)
+	}
+
+	total, err := countSession.Count(&model.VersionTester{})
+	if err != nil {
+		log.Error("获取测试人员数量时出现错误", zap.String("err", err.Error()))
+		return nil, 0, ecode.InternalError
+	}
+
+	result := make([]model.VersionTester, 0)
+	querySession := d.db.Where("status != ?", model.DeletedStatus)
+	if identifier != "" {
+		querySession.And("identifier = ?", identifier)
+	}
+	err = querySession.Desc("id").
+		Limit(paging.PageSize, paging.PageSize*(paging.Page-1)).Find(&result)
+	if err != nil {
+		log.Error("获取测试人员列表时出现错误", zap.String("err", err.Error()))
+		return nil, 0, ecode.InternalError
+	}
+
+	return &result, total, nil
+}
+
+// GetVersionTesterChannels 获取学号或设备id匹配到的测试人员所在的渠道
+func (d *Dao) GetVersionTesterChannels(identifiers ...string) ([]string, error) {
+	result := make([]string, 0)
+	err := d.db.Table(model.VersionTester{}).
+		Cols("channel").
+		In("identifier", identifiers).
+		And("status != ?", model.DeletedStatus).
+		Find(&result)
+	if err != nil {
+		log.Error("获取测试人员渠道时出现错误", zap.Strings("identifiers", identifiers), zap.String("err", err.Error()))
+		return nil, ecode.InternalError
+	}
+
+	return result, nil
+}
+
+func (d *Dao) HasVersionTester(identifier string) (bool, error) {
+	has, err := d.db.Where("identifier = ?", identifier).
+		And("status != ?", model.DeletedStatus).
+		Exist(&model.VersionTester{})
+	if err != nil {
+		log.Error("查询测试人员时出现错误", zap.String("identifier", identifier), zap.String("err", err.Error()))
+		return false, ecode.InternalError
+	}
+
+	return has, nil
+}
+
+// HasAnyVersionTester 是否有未删除的测试人员
+func (d *Dao) HasAnyVersionTester() (bool, error) {
+	has, err := d.db.Where("status != ?", model.DeletedStatus).Exist(&model.VersionTester{})
+	if err != nil {
+		log.Error("查询测试人员时出现错误", zap.String("err", err.Error()))
+		return false, ecode.InternalError
+	}
+
+	return has, nil
+}
+
+func (d *Dao) AddVersionTester(tester *model.VersionTester) (int64, error) {
+	count, err := d.db.InsertOne(tester)
+	if err != nil {
+		log.Error("添加测试人员时出现错误", zap.Any("entity", tester), zap.String("err", err.Error()))
+		return 0, ecode.InternalError
+	}
+
+	return count, nil
+}
+
+func (d *Dao) DeleteVersionTester(id int64) (int64, error) {
+	status := model.DeletedStatus
+	count, err := d.db.Omit("id").
+		Where("id = ?", id).And("status != ?", model.DeletedStatus).
+		Update(&model.VersionTester{Status: &status})
+	if err != nil {
+		log.Error("删除测试人员时出现错误", zap.Int64("id", id), zap.String("err", err.Error()))
+		return 0, ecode.InternalError
+	}
+
+	return count, nil
+}
@@ -0,0 +1,30 @@
+package model
+
+// 投放规则中的学生类型
+const (
+	StudentTypeUndergraduate = "undergraduate" // 本科生
+	StudentTypeGraduate      = "graduate"      // 研究生
+)
+
+// AudienceRule 公告、轮播图的投放规则，各条件之间为且的关系，为空的条件不限制，规则为空时对所有人展示
+type AudienceRule struct {
+	Colleges     []string `json:"colleges,omitempty"`     // 学院，满足其一即可
+	EnrollYears  []int    `json:"enrollYears,omitempty"`  // 入学年份，由学号前四位得到
+	StudentTypes []string `json:"studentTypes,omitempty"` // 见 StudentTypeXxx
+	MinVersion   string   `json:"minVersion,omitempty"`   // 客户端版本范围，语义化版本号，包括边界
+	MaxVersion   string   `json:"maxVersion,omitempty"`
+}
+
+// NeedStudentInfo 是否需要学生信息才能判断
+func (rule *AudienceRule) NeedStudentInfo() bool {
+	return rule != nil && (len(rule.Colleges) > 0 || len(rule.EnrollYears) > 0 || len(rule.StudentTypes) > 0)
+}
+
+// StudentProfile 匹配投放规则用到的学生信息，由助手上游的学生信息得到，缓存在redis中。
+// token无效时缓存空的信息，StudentType为空表示不是已登录的学生
+type StudentProfile struct {
+	College     string `json:"college"`
+	EnrollYear  int    `json:"enrollYear"` // 学号不是以年份开头时为0
+	StudentType string `json:"studentType"`
+	StudentNum  string `json:"studentNum"` // 匹配测试人员用
+}
@@ -6,17 +6,52 @@ const (
 	BannerPublishedStatus int8 = 2
 )
 
+// 轮播图展示位
+const (
+	BannerSlotHome     = "home"     // 首页轮播
+	BannerSlotSchedule = "schedule" // 课表页
+	BannerSlotSplash   = "splash"   // 开屏
+)
+
+var BannerSlots = []string{BannerSlotHome, BannerSlotSchedule, BannerSlotSplash}
+
+// 轮播图链接类型，Link的内容按类型解释
+const (
+	BannerLinkTypeNone     = "none"      // 没有链接，Link为空
+	BannerLinkTypeUrl      = "url"       // http或https链接
+	BannerLinkTypeMpPage   = "mp_page"   // 小程序页面路径，可以带参数，如 pages/news/detail?id=1
+	BannerLinkTypeAppRoute = "app_route" // app内页面路由，可以带参数，如 /schedule?week=1
+)
+
 type Banner struct {
-	ID         int64      `xorm:"id" db:"id" json:"id" form:"id"`
-	Title      *string    `xorm:"title" db:"title" json:"title" form:"title"`
-	Link       *string    `xorm:"link" db:"link" json:"link" form:"link"`
-	Img        *string    `xorm:"img" db:"img" json:"img" form:"img"`
-	Platform   *string    `xorm:"platform" db:"platform" json:"platform" form:"platform"`
-	CreateTime *time.Time `xorm:"create_time" db:"create_time" json:"create_time" form:"create_time"`
-	UpdateTime *time.Time `xorm:"update_time" db:"update_time" json:"update_time" form:"update_time"`
-	Status     *int8      `xorm:"status" db:"status" json:"status" form:"status"`
+	ID          int64              `xorm:"id" db:"id" json:"id" form:"id"`
+	Title       *string            `xorm:"title" db:"title" json:"title" form:"title"`
+	Link        *string            `xorm:"link" db:"link" json:"link" form:"link"`                     // 链接目标，见 LinkType
+	LinkType    *string            `xorm:"link_type" db:"link_type" json:"link_type" form:"link_type"` // 见 BannerLinkTypeXxx，旧数据可能为空
+	Img         *string            `xorm:"img" db:"img" json:"img" form:"img"`
+	ImgVariants []BannerImgVariant `xorm:"img_variants json" db:"img_variants" json:"img_variants" form:"img_variants"` // 旧数据为空，只有原图
+	Platform    *string            `xorm:"platform" db:"platform" json:"platform" form:"platform"`
+	Slot        *string            `xorm:"slot" db:"slot" json:"slot" form:"slot"`                             // 展示位，见 BannerSlotXxx
+	SortWeight  *int               `xorm:"sort_weight" db:"sort_weight" json:"sort_weight" form:"sort_weight"` // 同一展示位内权重大的排在前边
+	StartAt     *time.Time         `xorm:"start_at" db:"start_at" json:"start_at" form:"start_at"`             // 开始展示的时间，为空时发布后立即展示
+	EndAt       *time.Time         `xorm:"end_at" db:"end_at" json:"end_at" form:"end_at"`                     // 结束展示的时间，为空时一直展示
+	Audience    *AudienceRule      `xorm:"audience json" db:"audience" json:"audience" form:"audience"`        // 投放规则，为空时对所有人展示
+	CreateTime  *time.Time         `xorm:"create_time" db:"create_time" json:"create_time" form:"create_time"`
+	UpdateTime  *time.Time         `xorm:"update_time" db:"update_time" json:"update_time" form:"update_time"`
+	Status      *int8              `xorm:"status" db:"status" json:"status" form:"status"`
 }
 
 func (Banner) TableName() string {
 	return "banner"
 }
+
+const (
+	BannerImgFormatJpeg = "jpg"
+	BannerImgFormatWebp = "webp"
+)
+
+// BannerImgVariant 轮播图图片的一个宽度和格式的版本，存放在图片id目录下的 {Width}.{Format}
+type BannerImgVariant struct {
+	Width  int    `json:"width"`
+	Format string `json:"format"`
+}
@@ -0,0 +1,18 @@
+package model
+
+import "time"
+
+// BannerStat 每个轮播图每个平台每天的展示和点击次数，由redis中的计数定期写入
+type BannerStat struct {
+	ID          int64      `xorm:"id"`
+	BannerId    *int64     `xorm:"banner_id"`
+	Platform    *string    `xorm:"platform"`
+	Date        *time.Time `xorm:"date"`
+	Impressions *int64     `xorm:"impressions"`
+	Clicks      *int64     `xorm:"clicks"`
+	UpdateTime  *time.Time `xorm:"update_time"`
+}
+
+func (BannerStat) TableName() string {
+	return "banner_stat"
+}
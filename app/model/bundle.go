@@ -0,0 +1,38 @@
+package model
+
+import "time"
+
+const (
+	BundlePublishedStatus int8 = 2
+)
+
+// Bundle 热更新资源包（小程序、webview中的H5页面等），按名称区分不同的资源包，
+// 每个平台可以同时发布多个版本，客户端按原生版本所在的范围获取其中最新的一个
+type Bundle struct {
+	ID               int64      `xorm:"id"`
+	Name             *string    `xorm:"name"`
+	VersionText      *string    `xorm:"version_text"` //  资源包的语义化版本号
+	Platform         *string    `xorm:"platform"`
+	MinNativeVersion *string    `xorm:"min_native_version"` //  兼容的原生版本范围，闭区间，为空时不限制
+	MaxNativeVersion *string    `xorm:"max_native_version"`
+	Summary          *string    `xorm:"summary"`
+	Manifest         *string    `xorm:"manifest"` //  文件清单json，见 BundleManifest
+	FileCount        *int       `xorm:"file_count"`
+	TotalSize        *int64     `xorm:"total_size"`
+	Ready            *bool      `xorm:"ready"` //  文件是否已全部上传到存储，上传完成后才能发布
+	CreateTime       *time.Time `xorm:"create_time"`
+	UpdateTime       *time.Time `xorm:"update_time"`
+	Status           *int8      `xorm:"status"`
+}
+
+func (Bundle) TableName() string {
+	return "bundle"
+}
+
+// BundleManifest 资源包的文件清单，key为包内的相对路径
+type BundleManifest map[string]BundleFile
+
+type BundleFile struct {
+	Sha256 string `json:"sha256"`
+	Size   int64  `json:"size"`
+}
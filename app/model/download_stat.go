@@ -0,0 +1,17 @@
+package model
+
+import "time"
+
+// DownloadStat 每个版本每个平台每天的下载次数，由redis中的计数定期写入
+type DownloadStat struct {
+	ID         int64      `xorm:"id"`
+	VersionId  *int64     `xorm:"version_id"`
+	Platform   *string    `xorm:"platform"`
+	Date       *time.Time `xorm:"date"`
+	Count      *int64     `xorm:"count"`
+	UpdateTime *time.Time `xorm:"update_time"`
+}
+
+func (DownloadStat) TableName() string {
+	return "download_stat"
+}
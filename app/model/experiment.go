@@ -0,0 +1,56 @@
+package model
+
+import "time"
+
+const (
+	ExperimentRunningStatus int8 = 2
+	ExperimentStoppedStatus int8 = 3
+)
+
+// 实验对象的类型
+const (
+	ExperimentTargetBanner       = "banner"
+	ExperimentTargetAnnouncement = "announcement"
+)
+
+// 实验统计的事件
+const (
+	ExperimentEventExposure = "exposure" // 曝光，客户端获取到该变体
+	ExperimentEventClick    = "click"    // 点击
+)
+
+// Experiment 轮播图或公告的A/B实验，每个变体是一个已有的轮播图或公告，客户端按权重固定分到其中一个变体，
+// 只能看到分到的变体，看不到其他变体。第一个变体作为对照组
+type Experiment struct {
+	ID         int64               `xorm:"id"`
+	Name       *string             `xorm:"name"`
+	TargetType *string             `xorm:"target_type"` // 见 ExperimentTargetXxx
+	Variants   []ExperimentVariant `xorm:"variants json"`
+	CreateTime *time.Time          `xorm:"create_time"`
+	UpdateTime *time.Time          `xorm:"update_time"`
+	Status     *int8               `xorm:"status"` // 0为未开始，2为进行中，3为已结束
+}
+
+func (Experiment) TableName() string {
+	return "experiment"
+}
+
+// ExperimentVariant 实验的一个变体，TargetId为轮播图或公告的id
+type ExperimentVariant struct {
+	TargetId int64 `json:"targetId"`
+	Weight   int   `json:"weight"` // 流量权重，分到该变体的概率为 权重/权重之和
+}
+
+// ExperimentStat 实验各变体累计的曝光和点击次数，由redis中的计数定期写入
+type ExperimentStat struct {
+	ID           int64      `xorm:"id"`
+	ExperimentId *int64     `xorm:"experiment_id"`
+	TargetId     *int64     `xorm:"target_id"`
+	Exposures    *int64     `xorm:"exposures"`
+	Clicks       *int64     `xorm:"clicks"`
+	UpdateTime   *time.Time `xorm:"update_time"`
+}
+
+func (ExperimentStat) TableName() string {
+	return "experiment_stat"
+}
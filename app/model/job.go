@@ -0,0 +1,28 @@
+package model
+
+import "time"
+
+const (
+	JobPendingStatus   = NormalStatus
+	JobRunningStatus   = int8(2)
+	JobSucceededStatus = int8(3)
+	JobFailedStatus    = int8(4)
+)
+
+// Job 后台任务，如上传文件到对象存储、发布版本后更新下载链接等，持久化后由service中的worker执行
+type Job struct {
+	ID          int64      `xorm:"id"`
+	Type        *string    `xorm:"type"`         //  任务类型，对应service中注册的处理函数
+	Payload     *string    `xorm:"payload"`      //  任务参数，json
+	Attempts    *int       `xorm:"attempts"`     //  已执行次数
+	MaxAttempts *int       `xorm:"max_attempts"` //  最多执行次数，达到后任务失败
+	LastError   *string    `xorm:"last_error"`
+	NextRunTime *time.Time `xorm:"next_run_time"` //  下次可执行的时间，用于失败重试退避
+	CreateTime  *time.Time `xorm:"create_time"`
+	UpdateTime  *time.Time `xorm:"update_time"`
+	Status      *int8      `xorm:"status"`
+}
+
+func (Job) TableName() string {
+	return "job"
+}
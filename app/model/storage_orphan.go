@@ -0,0 +1,23 @@
+package model
+
+import "time"
+
+const (
+	StorageOrphanCollectedStatus int8 = 2 // 已删除或移动到归档前缀
+)
+
+// StorageOrphan 存储中没有被数据库引用的对象，FirstSeenTime为第一次扫描到无引用的时间，
+// 持续无引用超过宽限期后才会被清理；再次被引用、对象已不存在或被重新上传时记录标记为删除
+type StorageOrphan struct {
+	ID            int64      `xorm:"id"`
+	ObjectKey     *string    `xorm:"object_key"`
+	Size          *int64     `xorm:"size"`
+	LastModified  *time.Time `xorm:"last_modified"`
+	FirstSeenTime *time.Time `xorm:"first_seen_time"`
+	UpdateTime    *time.Time `xorm:"update_time"`
+	Status        *int8      `xorm:"status"`
+}
+
+func (StorageOrphan) TableName() string {
+	return "storage_orphan"
+}
@@ -0,0 +1,15 @@
+package model
+
+import "time"
+
+// UploadSession 分片上传会话，存放在redis中，已上传的分片号单独用set存储
+type UploadSession struct {
+	Id         string    `json:"id"`
+	FileName   string    `json:"file_name"`
+	Size       int64     `json:"size"`
+	PartSize   int64     `json:"part_size"`
+	PartCount  int       `json:"part_count"`
+	Completed  bool      `json:"completed"`
+	LocalPath  string    `json:"local_path"` //  合并完成后的本地文件位置
+	CreateTime time.Time `json:"create_time"`
+}
@@ -4,17 +4,38 @@ import "time"
 
 const (
 	VersionPublishedStatus int8 = 2
+	VersionRollingStatus   int8 = 3 // 灰度发布中，同平台已发布的版本继续对灰度外的客户端生效
+)
+
+// 发布渠道，每个平台的每个渠道各自有一个已发布版本，测试人员按其所在渠道获取更新
+const (
+	VersionChannelStable   = "stable"
+	VersionChannelBeta     = "beta"
+	VersionChannelInternal = "internal"
 )
 
 type Version struct {
-	ID          int64      `xorm:"id"`
-	VersionText *string    `xorm:"version_text"`
-	Summary     *string    `xorm:"summary"`
-	File        *string    `xorm:"file"`
-	Platform    *string    `xorm:"platform"`
-	CreateTime  *time.Time `xorm:"create_time"`
-	UpdateTime  *time.Time `xorm:"update_time"`
-	Status      *int8      `xorm:"status"`
+	ID             int64      `xorm:"id"`
+	VersionText    *string    `xorm:"version_text"`
+	VersionMajor   *uint64    `xorm:"version_major"` //  以下为解析version_text得到的语义化版本号，用于排序
+	VersionMinor   *uint64    `xorm:"version_minor"`
+	VersionPatch   *uint64    `xorm:"version_patch"`
+	VersionPre     *string    `xorm:"version_pre"` //  先行版本号，正式版本为空字符串
+	Summary        *string    `xorm:"summary"`
+	File           *string    `xorm:"file"`
+	Platform       *string    `xorm:"platform"`
+	Channel        *string    `xorm:"channel"`      //  发布渠道，见 VersionChannelXxx
+	PackageName    *string    `xorm:"package_name"` //  以下apk相关字段来自AndroidManifest.xml，ios平台只有包名，为ipa的bundle id
+	VersionCode    *int32     `xorm:"version_code"`
+	MinSdk         *int32     `xorm:"min_sdk"`
+	FileSize       *int64     `xorm:"file_size"` //  版本文件大小，单位字节
+	FileSha256     *string    `xorm:"file_sha256"`
+	Mandatory      *bool      `xorm:"mandatory"`       //  是否强制更新，低于该版本的客户端必须更新
+	RolloutPercent *int       `xorm:"rollout_percent"` //  灰度比例，0-100，仅灰度发布中有效
+	RolloutPaused  *bool      `xorm:"rollout_paused"`  //  灰度是否暂停，暂停时所有客户端都使用已发布的版本
+	CreateTime     *time.Time `xorm:"create_time"`
+	UpdateTime     *time.Time `xorm:"update_time"`
+	Status         *int8      `xorm:"status"`
 }
 
 func (Version) TableName() string {
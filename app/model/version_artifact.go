@@ -0,0 +1,33 @@
+package model
+
+import "time"
+
+// 版本文件类型，android按ABI拆分的安装包用于减小下载体积
+const (
+	VersionArtifactApkUniversal = "apk_universal"
+	VersionArtifactApkArm64     = "apk_arm64_v8a"
+	VersionArtifactApkArmv7     = "apk_armeabi_v7a"
+	VersionArtifactIpa          = "ipa"
+	VersionArtifactIosManifest  = "ios_manifest" //  itms-services安装用的manifest.plist
+	VersionArtifactStoreLink    = "store_link"   //  应用商店等外部链接，没有文件
+)
+
+// VersionArtifact 版本的一个下载文件，一个版本每种类型最多一个。
+// Version.File为版本的主文件，这里是额外的文件，没有匹配的文件时客户端使用主文件
+type VersionArtifact struct {
+	ID         int64      `xorm:"id"`
+	VersionId  *int64     `xorm:"version_id"`
+	Type       *string    `xorm:"type"`
+	File       *string    `xorm:"file"` //  存储中的文件，相对版本文件目录，外部链接时为空
+	Url        *string    `xorm:"url"`  //  外部链接，有文件时为空
+	FileSize   *int64     `xorm:"file_size"`
+	FileSha256 *string    `xorm:"file_sha256"`
+	Mirrors    []string   `xorm:"mirrors json"` //  镜像下载地址
+	Ready      *bool      `xorm:"ready"`        //  文件是否已上传到存储，上传完成后才下发给客户端，外部链接总是true
+	CreateTime *time.Time `xorm:"create_time"`
+	Status     *int8      `xorm:"status"`
+}
+
+func (VersionArtifact) TableName() string {
+	return "version_artifact"
+}
@@ -0,0 +1,23 @@
+package model
+
+import "time"
+
+// VersionPatch 版本的bsdiff增量包，客户端对已安装的基础版本安装包打补丁得到新版本安装包。
+// 基础版本和新版本的sha256用于确认增量包仍然对应两个版本当前的文件，任一版本重新上传文件后增量包失效
+type VersionPatch struct {
+	ID              int64      `xorm:"id"`
+	VersionId       *int64     `xorm:"version_id"`
+	BaseVersionId   *int64     `xorm:"base_version_id"`
+	BaseVersionText *string    `xorm:"base_version_text"`
+	BaseSha256      *string    `xorm:"base_sha256"`
+	TargetSha256    *string    `xorm:"target_sha256"`
+	File            *string    `xorm:"file"`
+	FileSize        *int64     `xorm:"file_size"`
+	FileSha256      *string    `xorm:"file_sha256"`
+	CreateTime      *time.Time `xorm:"create_time"`
+	Status          *int8      `xorm:"status"`
+}
+
+func (VersionPatch) TableName() string {
+	return "version_patch"
+}
@@ -0,0 +1,16 @@
+package model
+
+import "time"
+
+// VersionPolicy 各平台的版本策略，低于最低支持版本的客户端需要强制更新
+type VersionPolicy struct {
+	ID         int64      `xorm:"id"`
+	Platform   *string    `xorm:"platform"`
+	MinVersion *string    `xorm:"min_version"` //  最低支持版本，语义化版本号，为空时不限制
+	CreateTime *time.Time `xorm:"create_time"`
+	UpdateTime *time.Time `xorm:"update_time"`
+}
+
+func (VersionPolicy) TableName() string {
+	return "version_policy"
+}
@@ -0,0 +1,24 @@
+package model
+
+import "time"
+
+const (
+	VersionPublishActionPublish  = "publish"
+	VersionPublishActionRollback = "rollback"
+)
+
+// VersionPublishHistory 版本发布记录，每次发布或回滚一条。
+// PreviousVersionId为再次回滚时要恢复的版本，发布时为发布前的已发布版本，回滚时为被恢复版本发布前的版本，没有时为0
+type VersionPublishHistory struct {
+	ID                int64      `xorm:"id"`
+	Platform          *string    `xorm:"platform"`
+	Channel           *string    `xorm:"channel"`
+	VersionId         *int64     `xorm:"version_id"`
+	PreviousVersionId *int64     `xorm:"previous_version_id"`
+	Action            *string    `xorm:"action"`
+	CreateTime        *time.Time `xorm:"create_time"`
+}
+
+func (VersionPublishHistory) TableName() string {
+	return "version_publish_history"
+}
@@ -0,0 +1,17 @@
+package model
+
+import "time"
+
+// VersionTester 测试人员，按学号或设备id匹配，匹配到的客户端可以获取所在渠道及更稳定渠道的版本
+type VersionTester struct {
+	ID         int64      `xorm:"id"`
+	Identifier *string    `xorm:"identifier"` //  学号或设备id
+	Channel    *string    `xorm:"channel"`    //  beta或internal
+	Remark     *string    `xorm:"remark"`
+	CreateTime *time.Time `xorm:"create_time"`
+	Status     *int8      `xorm:"status"`
+}
+
+func (VersionTester) TableName() string {
+	return "version_tester"
+}
@@ -0,0 +1,62 @@
+package v3
+
+import (
+	"context"
+	"go.uber.org/zap"
+	"wusthelper-manager-go/library/ecode"
+	"wusthelper-manager-go/library/log"
+)
+
+const (
+	_studentInfoPath         = "/jwc/get-student-info"
+	_graduateStudentInfoPath = "/yjs/get-student-info"
+)
+
+// GetStudentInfo 使用学生的助手token获取本科生信息，ctx用于控制单次请求的超时
+func (rpc *WusthelperHttpRpc) GetStudentInfo(ctx context.Context, token string) (*StudentInfoResp, error) {
+	result := new(WusthelperResp[StudentInfoResp])
+	err := rpc.get(ctx, _studentInfoPath, token, result)
+	if err != nil {
+		return nil, err
+	}
+
+	switch result.Code {
+	case jwcGetStuInfoSuc, jwcGetStuInfoSucJwcErrLocSuc:
+		return &result.Data, nil
+	default:
+		return nil, toEcode(result.Code, "jwc")
+	}
+}
+
+// GetGraduateStudentInfo 使用学生的助手token获取研究生信息，ctx用于控制单次请求的超时
+func (rpc *WusthelperHttpRpc) GetGraduateStudentInfo(ctx context.Context, token string) (*GraduateStudentResp, error) {
+	result := new(WusthelperResp[GraduateStudentResp])
+	err := rpc.get(ctx, _graduateStudentInfoPath, token, result)
+	if err != nil {
+		return nil, err
+	}
+
+	switch result.Code {
+	case yjsGetStuInfoSuc:
+		return &result.Data, nil
+	default:
+		return nil, toEcode(result.Code, "yjs")
+	}
+}
+
+func (rpc *WusthelperHttpRpc) get(ctx context.Context, path, token string, result any) error {
+	resp, err := rpc.client.R().
+		SetContext(ctx).
+		SetHeader("Token", token).
+		SetResult(result).
+		Get(path)
+	if err != nil {
+		log.Error("请求助手上游时出现错误", zap.String("path", path), zap.Error(err))
+		return ecode.InternalError
+	} else if resp.IsError() {
+		log.Error("请求助手上游时出现错误", zap.String("path", path), zap.Int("status", resp.StatusCode()))
+		return ecode.InternalError
+	}
+
+	return nil
+}
@@ -87,6 +87,10 @@ const (
 
 func toEcode(code int, service string) ecode.Code {
 	switch code {
+	case authErrTokenMiss, authErrTokenInvalid, authDecodeForStuNumErr, jwcPwdNedUpd, yjsPwdNedUpd:
+		return ecode.TokenInvalid
+	case jwcGetStuInfoErrNoSuchStu:
+		return ecode.UserNotExists
 	default:
 		log.Warn("未处理的助手上游响应码", zap.Int("code", code), zap.String("service", service))
 	}
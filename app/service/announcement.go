@@ -12,6 +12,7 @@ type AnnouncementAddParam struct {
 	Content  *string
 	Target   *string
 	Platform *[]string
+	Audience *model.AudienceRule // 为nil时对所有人展示
 }
 
 type AnnouncementModifyParam struct {
@@ -20,16 +21,41 @@ type AnnouncementModifyParam struct {
 	Content  *string
 	Target   *string
 	Platform *string
+	Audience *model.AudienceRule // 为nil时不修改，为空的规则表示对所有人展示
 	Status   *int8
 }
 
-func (s *Service) GetPublishedAnnouncement(platform string) (*[]model.Announcement, error) {
+// GetPublishedAnnouncement 获取对客户端投放的已发布公告，进行中的实验只保留客户端分到的变体，并记录一次曝光
+func (s *Service) GetPublishedAnnouncement(platform string, client AudienceClient) (*[]model.Announcement, ExperimentAssignments, error) {
 	announcements, err := s.dao.GetPublishedAnnouncement(platform)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
-	return announcements, nil
+	matcher := s.newAudienceMatcher(client)
+	matched := make([]model.Announcement, 0, len(*announcements))
+	ids := make([]int64, 0, len(*announcements))
+	for _, announcement := range *announcements {
+		if matcher.match(announcement.Audience) {
+			matched = append(matched, announcement)
+			ids = append(ids, announcement.Id)
+		}
+	}
+
+	hidden, assignments, err := s.assignExperimentVariants(model.ExperimentTargetAnnouncement, ids, client)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	result := make([]model.Announcement, 0, len(matched))
+	for _, announcement := range matched {
+		if !hidden[announcement.Id] {
+			result = append(result, announcement)
+		}
+	}
+
+	s.recordExperimentExposure(assignments)
+	return &result, assignments, nil
 }
 
 func (s *Service) GetAllAnnouncement(paging common.Pagination, platform string) (*[]model.Announcement, int64, error) {
@@ -69,6 +95,10 @@ func (s *Service) PublishAnnouncementBatch(ids []int64) error {
 }
 
 func (s *Service) AddAnnouncement(param *AnnouncementAddParam) error {
+	if err := checkAudienceRule(param.Audience); err != nil {
+		return err
+	}
+
 	for _, platform := range *param.Platform {
 		announcement := model.Announcement{
 			Id:         idgen.NextId(),
@@ -76,6 +106,7 @@ func (s *Service) AddAnnouncement(param *AnnouncementAddParam) error {
 			Content:    param.Content,
 			Target:     param.Target,
 			Platform:   &platform,
+			Audience:   param.Audience,
 			CreateTime: new(time.Time),
 			UpdateTime: new(time.Time),
 			Status:     new(int8),
@@ -104,12 +135,17 @@ func (s *Service) DeleteAnnouncement(id int64) error {
 }
 
 func (s *Service) ModifyAnnouncement(param *AnnouncementModifyParam) error {
+	if err := checkAudienceRule(param.Audience); err != nil {
+		return err
+	}
+
 	announcement := model.Announcement{
 		Id:         param.Id,
 		Title:      param.Title,
 		Content:    param.Content,
 		Target:     param.Target,
 		Platform:   param.Platform,
+		Audience:   param.Audience,
 		Status:     param.Status,
 		UpdateTime: new(time.Time),
 	}
@@ -0,0 +1,194 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"go.uber.org/zap"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"wusthelper-manager-go/app/model"
+	"wusthelper-manager-go/library/ecode"
+	"wusthelper-manager-go/library/log"
+	"wusthelper-manager-go/library/semver"
+)
+
+const (
+	// token无效时缓存空的学生信息，避免重复请求上游
+	_invalidStudentTokenCacheExpiration = time.Minute * 10
+	// 上游出错或超时时短暂缓存空的学生信息，避免上游故障时每个请求都等到超时
+	_failedStudentProfileCacheExpiration = time.Second * 30
+	// 获取学生信息的超时时间，本科生和研究生两次请求一起计算，超时时按获取不到学生信息处理
+	_studentProfileTimeout = time.Second * 3
+)
+
+// AudienceClient 获取公告、轮播图的客户端信息，用于匹配投放规则
+type AudienceClient struct {
+	Token   string        // 助手token，为空时只能看到没有学生相关条件的内容
+	Version string        // 客户端版本号，为空时只能看到没有版本条件的内容
+	Client  VersionClient // 设备id，用于实验分组
+}
+
+// audienceMatcher 匹配一次请求中的多条投放规则，学生信息在第一次需要时才获取
+type audienceMatcher struct {
+	service *Service
+	client  AudienceClient
+	profile *model.StudentProfile
+	loaded  bool
+}
+
+func (s *Service) newAudienceMatcher(client AudienceClient) *audienceMatcher {
+	return &audienceMatcher{service: s, client: client}
+}
+
+// match 获取不到客户端版本或学生信息时，有对应条件的规则都不匹配
+func (m *audienceMatcher) match(rule *model.AudienceRule) bool {
+	if rule == nil {
+		return true
+	}
+
+	if rule.MinVersion != "" || rule.MaxVersion != "" {
+		version, err := semver.Parse(m.client.Version)
+		if err != nil {
+			return false
+		}
+
+		if minVersion, err := semver.Parse(rule.MinVersion); err == nil && version.Compare(minVersion) < 0 {
+			return false
+		}
+		if maxVersion, err := semver.Parse(rule.MaxVersion); err == nil && version.Compare(maxVersion) > 0 {
+			return false
+		}
+	}
+
+	if !rule.NeedStudentInfo() {
+		return true
+	}
+
+	if !m.loaded {
+		m.profile, m.loaded = m.service.getStudentProfile(m.client.Token), true
+	}
+
+	profile := m.profile
+	if profile == nil || profile.StudentType == "" {
+		return false
+	}
+
+	return (len(rule.Colleges) == 0 || slices.Contains(rule.Colleges, profile.College)) &&
+		(len(rule.EnrollYears) == 0 || slices.Contains(rule.EnrollYears, profile.EnrollYear)) &&
+		(len(rule.StudentTypes) == 0 || slices.Contains(rule.StudentTypes, profile.StudentType))
+}
+
+// checkAudienceRule 校验并整理投放规则，学院去掉首尾空格
+func checkAudienceRule(rule *model.AudienceRule) error {
+	if rule == nil {
+		return nil
+	}
+
+	colleges := make([]string, 0, len(rule.Colleges))
+	for _, college := range rule.Colleges {
+		if college = strings.TrimSpace(college); college != "" {
+			colleges = append(colleges, college)
+		}
+	}
+	rule.Colleges = colleges
+
+	for _, studentType := range rule.StudentTypes {
+		if studentType != model.StudentTypeUndergraduate && studentType != model.StudentTypeGraduate {
+			return ecode.AudienceRuleInvalid
+		}
+	}
+
+	var minVersion, maxVersion *semver.Version
+	var err error
+	if rule.MinVersion != "" {
+		if minVersion, err = semver.Parse(rule.MinVersion); err != nil {
+			return ecode.AudienceRuleInvalid
+		}
+	}
+	if rule.MaxVersion != "" {
+		if maxVersion, err = semver.Parse(rule.MaxVersion); err != nil {
+			return ecode.AudienceRuleInvalid
+		}
+	}
+	if minVersion != nil && maxVersion != nil && minVersion.Compare(maxVersion) > 0 {
+		return ecode.AudienceRuleInvalid
+	}
+
+	return nil
+}
+
+// getStudentProfile 通过助手上游获取token对应的学生信息，先按本科生获取，获取不到时再按研究生获取。
+// 结果按token缓存，上游出错或token无效时返回nil。只在有规则需要学生信息时调用，见 audienceMatcher.match
+func (s *Service) getStudentProfile(token string) *model.StudentProfile {
+	if token == "" || s.config.Wusthelper.Upstream == "" {
+		return nil
+	}
+
+	ctx := context.Background()
+	hash := sha256.Sum256([]byte(token))
+	tokenHash := hex.EncodeToString(hash[:])
+	profile, err := s.dao.GetStudentProfileCache(&ctx, tokenHash)
+	if err == nil && profile != nil {
+		return profile
+	}
+
+	// 助手配置的Timeout可能为0（不超时），这里单独限制，避免上游卡住时拖住公开接口
+	fetchCtx, cancel := context.WithTimeout(ctx, _studentProfileTimeout)
+	defer cancel()
+
+	profile, err = s.fetchStudentProfile(fetchCtx, token)
+	if err == ecode.TokenInvalid || err == ecode.UserNotExists {
+		_ = s.dao.StoreStudentProfileCache(&ctx, tokenHash, &model.StudentProfile{}, _invalidStudentTokenCacheExpiration)
+		return nil
+	} else if err != nil {
+		log.Warn("获取学生信息时出现错误", zap.Error(err))
+		_ = s.dao.StoreStudentProfileCache(&ctx, tokenHash, &model.StudentProfile{}, _failedStudentProfileCacheExpiration)
+		return nil
+	}
+
+	_ = s.dao.StoreStudentProfileCache(&ctx, tokenHash, profile, wusthelperTokenExpiration)
+	return profile
+}
+
+func (s *Service) fetchStudentProfile(ctx context.Context, token string) (*model.StudentProfile, error) {
+	info, err := s.rpc.GetStudentInfo(ctx, token)
+	if err == nil {
+		return &model.StudentProfile{
+			College:     strings.TrimSpace(info.College),
+			EnrollYear:  enrollYearOfStudentNum(info.StuNum),
+			StudentType: model.StudentTypeUndergraduate,
+			StudentNum:  strings.TrimSpace(info.StuNum),
+		}, nil
+	} else if err != ecode.TokenInvalid && err != ecode.UserNotExists {
+		return nil, err
+	}
+
+	graduateInfo, err := s.rpc.GetGraduateStudentInfo(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+
+	return &model.StudentProfile{
+		College:     strings.TrimSpace(graduateInfo.Academy),
+		EnrollYear:  enrollYearOfStudentNum(graduateInfo.StudentNum),
+		StudentType: model.StudentTypeGraduate,
+		StudentNum:  strings.TrimSpace(graduateInfo.StudentNum),
+	}, nil
+}
+
+// enrollYearOfStudentNum 学号的前四位为入学年份，不是合理的年份时返回0
+func enrollYearOfStudentNum(studentNum string) int {
+	if len(studentNum) < 4 {
+		return 0
+	}
+
+	year, err := strconv.Atoi(studentNum[:4])
+	if err != nil || year < 1950 || year > time.Now().Year()+1 {
+		return 0
+	}
+
+	return year
+}
@@ -1,33 +1,86 @@
 package service
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
-	"github.com/aliyun/aliyun-oss-go-sdk/oss"
 	"github.com/dustin/go-humanize"
+	jsoniter "github.com/json-iterator/go"
 	"github.com/sunshineplan/imgconv"
 	"github.com/yitter/idgenerator-go/idgen"
 	"go.uber.org/zap"
+	"image"
+	"image/jpeg"
+	"io"
+	"math"
 	"math/rand"
+	"net/url"
 	"os"
+	"slices"
+	"sort"
 	"strings"
 	"time"
 	"wusthelper-manager-go/app/model"
 	"wusthelper-manager-go/common"
 	"wusthelper-manager-go/library/ecode"
+	"wusthelper-manager-go/library/imgcheck"
 	"wusthelper-manager-go/library/log"
+	"wusthelper-manager-go/library/webp"
 )
 
-func (s *Service) GetPublishedBanner(platform ...string) (*[]model.Banner, error) {
-	latestBannerList, err := s.dao.GetPublishedBanner(platform...)
+const (
+	_bannerJpegQuality            = 80
+	_bannerWebpQuality            = 75
+	_defaultBannerAspectTolerance = 0.02
+	_defaultBannerImgMaxWidth     = 8192
+	_defaultBannerImgMaxHeight    = 8192
+	_defaultBannerImgMaxPixels    = 40_000_000
+)
+
+var _defaultBannerImgWidths = []int{480, 960, 1440}
+
+// GetPublishedBanner 获取展示位当前正在展示且对客户端投放的轮播图，slot为空时为首页轮播。
+// 进行中的实验只保留客户端分到的变体，并记录一次曝光
+func (s *Service) GetPublishedBanner(slot string, client AudienceClient, platform ...string) (*[]model.Banner, ExperimentAssignments, error) {
+	if slot == "" {
+		slot = model.BannerSlotHome
+	} else if !slices.Contains(model.BannerSlots, slot) {
+		return nil, nil, ecode.BannerSlotInvalid
+	}
+
+	latestBannerList, err := s.dao.GetPublishedBanner(slot, platform...)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
+	}
+
+	matcher := s.newAudienceMatcher(client)
+	matched := make([]model.Banner, 0, len(*latestBannerList))
+	ids := make([]int64, 0, len(*latestBannerList))
+	for _, banner := range *latestBannerList {
+		if matcher.match(banner.Audience) {
+			matched = append(matched, banner)
+			ids = append(ids, banner.ID)
+		}
+	}
+
+	hidden, assignments, err := s.assignExperimentVariants(model.ExperimentTargetBanner, ids, client)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	result := make([]model.Banner, 0, len(matched))
+	for _, banner := range matched {
+		if !hidden[banner.ID] {
+			result = append(result, banner)
+		}
 	}
 
-	return latestBannerList, nil
+	s.recordExperimentExposure(assignments)
+	return &result, assignments, nil
 }
 
-func (s *Service) GetBannerList(pagination common.Pagination, platform string) (*[]model.Banner, int64, error) {
-	bannerList, total, err := s.dao.GetBannerList(pagination, platform)
+func (s *Service) GetBannerList(pagination common.Pagination, platform, slot string) (*[]model.Banner, int64, error) {
+	bannerList, total, err := s.dao.GetBannerList(pagination, platform, slot)
 	if err != nil {
 		return nil, 0, err
 	}
@@ -36,140 +89,446 @@ func (s *Service) GetBannerList(pagination common.Pagination, platform string) (
 }
 
 type BannerAddParam struct {
-	Title    string
-	Link     string
-	Img      *File
-	Platform []string
+	Title      string
+	Link       string
+	LinkType   string // 为空时只能推断出url和none类型
+	Img        *File
+	Platform   []string
+	Slot       string // 为空时为首页轮播
+	SortWeight int
+	StartAt    *time.Time // 为nil时不限制
+	EndAt      *time.Time
+	Audience   *model.AudienceRule // 为nil时对所有人展示
 }
 
 func (s *Service) AddBanner(param *BannerAddParam) error {
+	slot := param.Slot
+	if slot == "" {
+		slot = model.BannerSlotHome
+	} else if !slices.Contains(model.BannerSlots, slot) {
+		return ecode.BannerSlotInvalid
+	}
+
+	if !checkBannerSchedule(param.StartAt, param.EndAt) {
+		return ecode.BannerScheduleInvalid
+	}
+
+	if err := checkAudienceRule(param.Audience); err != nil {
+		return err
+	}
+
+	linkType := param.LinkType
+	if linkType == "" {
+		linkType = inferBannerLinkType(param.Link)
+	}
+	for _, platform := range param.Platform {
+		if err := s.checkBannerLink(platform, linkType, param.Link); err != nil {
+			return err
+		}
+	}
+
 	now := time.Now()
 	banners := make([]model.Banner, len(param.Platform))
+	uploadJobs := make([]bannerImgUploadJob, 0, len(param.Platform))
 	for i, platform := range param.Platform {
 		bannerId := idgen.NextId()
 		imgId := ""
+		var imgVariants []model.BannerImgVariant
 		if param.Img != nil {
 			// 先存到本地，再去传oss
-			localFileLoc, err := s.processBannerImg(param.Img)
+			img, err := s.processBannerImg(param.Img, platform, slot)
 			if err != nil {
 				return err
 			}
 
 			imgId = fmt.Sprintf("%d/v1.%d.%d", bannerId, idgen.NextId(), time.Now().UnixMilli())
-
-			// 上传新文件
-			go s.uploadBannerPic(imgId, localFileLoc)
+			imgVariants = img.variants()
+			uploadJobs = append(uploadJobs, bannerImgUploadJob{
+				BannerId:  bannerId,
+				ImgId:     imgId,
+				LocalFile: img.LocalFile,
+				Variants:  img.Variants,
+			})
 		}
 
 		status := model.NormalStatus
 		p := strings.Clone(platform)
 		banners[i] = model.Banner{
-			ID:         bannerId,
-			Title:      &param.Title,
-			Link:       &param.Link,
-			Img:        &imgId,
-			Platform:   &p,
-			CreateTime: &now,
-			UpdateTime: &now,
-			Status:     &status,
+			ID:          bannerId,
+			Title:       &param.Title,
+			Link:        &param.Link,
+			LinkType:    &linkType,
+			Img:         &imgId,
+			ImgVariants: imgVariants,
+			Platform:    &p,
+			Slot:        &slot,
+			SortWeight:  &param.SortWeight,
+			StartAt:     param.StartAt,
+			EndAt:       param.EndAt,
+			Audience:    param.Audience,
+			CreateTime:  &now,
+			UpdateTime:  &now,
+			Status:      &status,
 		}
 	}
 
-	_, err := s.dao.AddBanner(banners...)
+	// 上传新文件的任务和轮播图一起写入
+	jobs := make([]model.Job, len(uploadJobs))
+	for i, uploadJob := range uploadJobs {
+		job, err := s.newJob(JobTypeBannerImgUpload, uploadJob)
+		if err != nil {
+			return err
+		}
+		jobs[i] = *job
+	}
+
+	_, err := s.dao.AddBanner(banners, jobs...)
 	if err != nil {
 		return err
 	}
 
+	s.releaseUploadFile(param.Img)
+
 	return nil
 }
 
-func (s *Service) uploadBannerPic(imgId, localFileLoc string) {
-	log.Info("banner图片后台上传任务开始")
-	resourceStorageOption := s.config.Server.FileStorageOption.ResourceStorageOption
-	ossObjectKey := fmt.Sprintf("%s/%s.jpg", resourceStorageOption.PicStorageBasePath, imgId)
-	err := s.ossBucket.PutObjectFromFile(ossObjectKey, localFileLoc, oss.Meta("Content-Type", "image/jpeg"))
+type bannerImgUploadJob struct {
+	BannerId  int64              `json:"banner_id"`
+	ImgId     string             `json:"img_id"`
+	LocalFile string             `json:"local_file"`
+	Variants  []bannerImgVariant `json:"variants,omitempty"`
+}
+
+// runBannerImgUploadJob 先上传各宽度的版本，最后上传原图
+func (s *Service) runBannerImgUploadJob(payload []byte) error {
+	job := new(bannerImgUploadJob)
+	if err := jsoniter.Unmarshal(payload, job); err != nil {
+		return err
+	}
+
+	for _, variant := range job.Variants {
+		ossObjectKey := s.BannerImgVariantKey(job.ImgId, variant.BannerImgVariant)
+		err := s.uploadLocalFile(ossObjectKey, variant.LocalFile, variant.ContentType)
+		if err != nil {
+			return err
+		}
+	}
+
+	err := s.uploadLocalFile(s.bannerImgKey(job.ImgId), job.LocalFile, "image/jpeg")
 	if err != nil {
-		log.Warn("banner图片上传oss时出现错误",
-			zap.String("oss_key", ossObjectKey),
-			zap.String("local_source", localFileLoc),
-			zap.Error(err),
-		)
-	} else {
-		err = os.Remove(localFileLoc)
+		return err
+	}
+
+	// 任务失败时图片记录会被清空，手动重试成功后要恢复
+	_, err = s.dao.RestoreBannerImg(job.BannerId, job.ImgId)
+	return err
+}
+
+// onBannerImgUploadJobFail 图片最终上传失败时，清空轮播图的图片记录，避免指向不存在的图片
+func (s *Service) onBannerImgUploadJobFail(payload []byte) {
+	job := new(bannerImgUploadJob)
+	if err := jsoniter.Unmarshal(payload, job); err != nil {
+		return
+	}
+
+	banner, err := s.dao.GetBanner(job.BannerId)
+	if err != nil || banner == nil || banner.Img == nil || *banner.Img != job.ImgId {
+		return
+	}
+
+	imgId := ""
+	_, _ = s.dao.UpdateBanner(&model.Banner{ID: job.BannerId, Img: &imgId})
+}
+
+// bannerImgKey 轮播图原图的对象key，兼容只使用原图地址的旧客户端
+func (s *Service) bannerImgKey(imgId string) string {
+	resourceStorageOption := s.config.Server.FileStorageOption.ResourceStorageOption
+	return fmt.Sprintf("%s/%s.jpg", resourceStorageOption.PicStorageBasePath, imgId)
+}
+
+// BannerImgVariantKey 轮播图图片某个宽度和格式版本的对象key
+func (s *Service) BannerImgVariantKey(imgId string, variant model.BannerImgVariant) string {
+	resourceStorageOption := s.config.Server.FileStorageOption.ResourceStorageOption
+	return fmt.Sprintf("%s/%s/%d.%s", resourceStorageOption.PicStorageBasePath, imgId, variant.Width, variant.Format)
+}
+
+// bannerImgKeys 轮播图图片的所有对象key
+func (s *Service) bannerImgKeys(banner *model.Banner) []string {
+	if banner.Img == nil || *banner.Img == "" {
+		return nil
+	}
+
+	keys := []string{s.bannerImgKey(*banner.Img)}
+	for _, variant := range banner.ImgVariants {
+		keys = append(keys, s.BannerImgVariantKey(*banner.Img, variant))
+	}
+
+	return keys
+}
+
+// hideBannerImg 将轮播图图片的所有对象设置为不可访问
+func (s *Service) hideBannerImg(banner *model.Banner) {
+	for _, ossObjectKey := range s.bannerImgKeys(banner) {
+		err := s.storage.Hide(ossObjectKey)
 		if err != nil {
-			log.Warn("移除本地banner图片时出现异常", zap.String("file", localFileLoc), zap.Error(err))
+			log.Warn("删除oss文件出现错误", zap.String("oss_key", ossObjectKey), zap.Error(err))
+		} else {
+			log.Info("删除oss文件完成", zap.String("oss_key", ossObjectKey))
 		}
-		log.Info("banner图片上传oss成功",
-			zap.String("oss_key", ossObjectKey),
-			zap.String("local_source", localFileLoc),
-		)
 	}
-	log.Info("banner图片后台上传任务完成")
 }
 
-func (s *Service) processBannerImg(imgFile *File) (string, error) {
+// bannerImg 处理后待上传的轮播图图片
+type bannerImg struct {
+	LocalFile string // 原图转换成的jpeg
+	Variants  []bannerImgVariant
+}
+
+type bannerImgVariant struct {
+	model.BannerImgVariant
+	LocalFile   string `json:"local_file"`
+	ContentType string `json:"content_type"`
+}
+
+func (img *bannerImg) variants() []model.BannerImgVariant {
+	result := make([]model.BannerImgVariant, len(img.Variants))
+	for i, variant := range img.Variants {
+		result[i] = variant.BannerImgVariant
+	}
+
+	return result
+}
+
+// processBannerImg 校验图片是否符合平台展示位的尺寸要求，将原图转换为jpeg，并生成各宽度的jpeg和webp版本。
+// 解码前先按文件头校验格式和尺寸；图片按EXIF方向转正后重新编码，原文件中的EXIF、GPS等元数据不会保留
+func (s *Service) processBannerImg(imgFile *File, platform, slot string) (*bannerImg, error) {
 	storageOption := s.config.Server.FileStorageOption
 	tmpId := time.Now().UnixMilli() + rand.Int63()
-	tmpImgFile := fmt.Sprintf("%s/tmp-%d-%s", storageOption.UploadFileLocalTmpPath, tmpId, imgFile.FileName)
 	processedFileLoc := fmt.Sprintf("%s/%d-%s", storageOption.UploadFileLocalTmpPath, tmpId, imgFile.FileName)
 
-	err := os.WriteFile(tmpImgFile, *imgFile.Data, 0664)
+	// 分片上传的文件已经在本地，直接读取，由调用方释放
+	var reader io.ReaderAt
+	var size int64
+	if imgFile.LocalPath != "" {
+		f, err := os.Open(imgFile.LocalPath)
+		if err != nil {
+			log.Error("打开图片文件时出现错误", zap.String("file", imgFile.LocalPath), zap.Error(err))
+			return nil, ecode.InternalError
+		}
+		defer f.Close()
+
+		reader, size = f, imgFile.Size()
+	} else {
+		reader, size = bytes.NewReader(*imgFile.Data), int64(len(*imgFile.Data))
+	}
+
+	imgFormat, _, err := imgcheck.Check(reader, size, s.bannerImgLimit())
+	if err != nil {
+		log.Warn("图片校验不通过", zap.String("file", imgFile.FileName), zap.Error(err))
+		switch {
+		case errors.Is(err, imgcheck.ErrFormatNotAllowed):
+			return nil, ecode.BannerImgFormatInvalid
+		case errors.Is(err, imgcheck.ErrTooLarge):
+			return nil, ecode.BannerImgTooLarge
+		default:
+			return nil, ecode.ParamWrong
+		}
+	}
+
+	img, err := imgcheck.Decode(reader, size)
 	if err != nil {
-		log.Error("写临时图片到本地临时目录时出现错误", zap.String("file", tmpImgFile), zap.Error(err))
-		return "", ecode.InternalError
+		log.Warn("图片数据读取错误", zap.String("format", imgFormat), zap.Error(err))
+		return nil, ecode.ParamWrong
 	}
 
-	img, err := imgconv.Open(tmpImgFile)
+	// 按转正后的尺寸校验
+	err = s.checkBannerImgSize(img.Bounds().Dx(), img.Bounds().Dy(), platform, slot)
 	if err != nil {
-		log.Warn("图片数据读取错误", zap.Error(err))
-		return "", ecode.ParamWrong
+		return nil, err
 	}
 
 	option := imgconv.FormatOption{
 		Format: imgconv.JPEG,
 	}
-	if len(*imgFile.Data) > 1*humanize.MByte {
+	if imgFile.Size() > 1*humanize.MByte {
 		option.EncodeOption = append(make([]imgconv.EncodeOption, 0, 1), imgconv.Quality(75))
 	}
 
 	err = imgconv.Save(processedFileLoc, img, &option)
 	if err != nil {
 		log.Error("图片处理错误", zap.Error(err))
-		return "", ecode.ParamWrong
+		return nil, ecode.ParamWrong
+	}
+
+	result := &bannerImg{LocalFile: processedFileLoc}
+	for _, width := range s.bannerImgWidths(img.Bounds().Dx()) {
+		resized := img
+		if width != img.Bounds().Dx() {
+			resized = imgconv.Resize(img, &imgconv.ResizeOption{Width: width})
+		}
+
+		formats := []string{model.BannerImgFormatJpeg}
+		if !s.config.Server.BannerOption.DisableWebp {
+			formats = append(formats, model.BannerImgFormatWebp)
+		}
+		for _, format := range formats {
+			variant := bannerImgVariant{
+				BannerImgVariant: model.BannerImgVariant{Width: width, Format: format},
+				LocalFile:        fmt.Sprintf("%s/%d-%d.%s", storageOption.UploadFileLocalTmpPath, tmpId, width, format),
+			}
+
+			variant.ContentType, err = saveBannerImgVariant(variant.LocalFile, resized, format)
+			if err != nil {
+				log.Error("生成轮播图图片版本时出现错误", zap.Int("width", width), zap.String("format", format), zap.Error(err))
+				return nil, ecode.InternalError
+			}
+
+			result.Variants = append(result.Variants, variant)
+		}
+	}
+
+	return result, nil
+}
+
+// checkBannerImgSize 按平台展示位的配置校验图片的宽高比和最低分辨率
+func (s *Service) checkBannerImgSize(width, height int, platform, slot string) error {
+	requirement, ok := s.config.Server.BannerOption.Slots[platform][slot]
+	if !ok || width <= 0 || height <= 0 {
+		return nil
+	}
+
+	if width < requirement.MinWidth || height < requirement.MinHeight {
+		log.Warn("轮播图图片分辨率过低",
+			zap.String("platform", platform), zap.String("slot", slot),
+			zap.Int("width", width), zap.Int("height", height),
+		)
+		return ecode.BannerImgSizeInvalid
 	}
 
-	err = os.Remove(tmpImgFile)
+	if requirement.AspectRatio > 0 {
+		tolerance := requirement.AspectTolerance
+		if tolerance <= 0 {
+			tolerance = _defaultBannerAspectTolerance
+		}
+
+		ratio := float64(width) / float64(height)
+		if math.Abs(ratio-requirement.AspectRatio)/requirement.AspectRatio > tolerance {
+			log.Warn("轮播图图片宽高比不符合要求",
+				zap.String("platform", platform), zap.String("slot", slot),
+				zap.Int("width", width), zap.Int("height", height),
+			)
+			return ecode.BannerImgSizeInvalid
+		}
+	}
+
+	return nil
+}
+
+// bannerImgLimit 上传图片的尺寸限制，没有配置的项使用默认值
+func (s *Service) bannerImgLimit() imgcheck.Limit {
+	option := s.config.Server.BannerOption
+	limit := imgcheck.Limit{
+		MaxWidth:  option.MaxWidth,
+		MaxHeight: option.MaxHeight,
+		MaxPixels: option.MaxPixels,
+	}
+	if limit.MaxWidth <= 0 {
+		limit.MaxWidth = _defaultBannerImgMaxWidth
+	}
+	if limit.MaxHeight <= 0 {
+		limit.MaxHeight = _defaultBannerImgMaxHeight
+	}
+	if limit.MaxPixels <= 0 {
+		limit.MaxPixels = _defaultBannerImgMaxPixels
+	}
+
+	return limit
+}
+
+// bannerImgWidths 需要生成的宽度，不超过原图宽度，原图宽度总会生成
+func (s *Service) bannerImgWidths(originWidth int) []int {
+	widths := s.config.Server.BannerOption.Widths
+	if len(widths) == 0 {
+		widths = _defaultBannerImgWidths
+	}
+
+	result := make([]int, 0, len(widths)+1)
+	for _, width := range widths {
+		if width > 0 && width < originWidth {
+			result = append(result, width)
+		}
+	}
+	sort.Ints(result)
+
+	return append(result, originWidth)
+}
+
+func saveBannerImgVariant(file string, img image.Image, format string) (string, error) {
+	out, err := os.Create(file)
 	if err != nil {
-		log.Warn("删除临时存储图片错误", zap.Error(err))
+		return "", err
 	}
+	defer out.Close()
 
-	return processedFileLoc, nil
+	contentType := "image/jpeg"
+	if format == model.BannerImgFormatWebp {
+		contentType = "image/webp"
+		err = webp.Encode(out, img, _bannerWebpQuality)
+	} else {
+		err = jpeg.Encode(out, img, &jpeg.Options{Quality: _bannerJpegQuality})
+	}
+	if err != nil {
+		return "", err
+	}
+
+	return contentType, out.Close()
 }
 
 type BannerModifyParam struct {
-	Id       int64
-	Title    *string
-	Link     *string
-	Img      *File
-	Platform *string
-	Status   *int8
+	Id         int64
+	Title      *string
+	Link       *string
+	LinkType   *string
+	Img        *File
+	Platform   *string
+	Slot       *string
+	SortWeight *int
+	StartAt    *time.Time          // 为nil时不修改，为零值时取消开始时间
+	EndAt      *time.Time          // 为nil时不修改，为零值时取消结束时间
+	Audience   *model.AudienceRule // 为nil时不修改，为空的规则表示对所有人展示
+	Status     *int8
 }
 
 func (s *Service) ModifyBanner(param *BannerModifyParam) error {
+	if param.Slot != nil && !slices.Contains(model.BannerSlots, *param.Slot) {
+		return ecode.BannerSlotInvalid
+	}
+
+	if err := checkAudienceRule(param.Audience); err != nil {
+		return err
+	}
+
 	now := time.Now()
 	banner := model.Banner{
 		ID:         param.Id,
 		Title:      param.Title,
 		Link:       param.Link,
+		LinkType:   param.LinkType,
 		Img:        nil,
 		Platform:   param.Platform,
+		Slot:       param.Slot,
+		SortWeight: param.SortWeight,
+		Audience:   param.Audience,
 		UpdateTime: &now,
 		Status:     param.Status,
 	}
 
-	// banner图片需要修改
-	if param.Img != nil {
-		existsBanner, err := s.dao.GetBanner(param.Id)
+	scheduleModified := param.StartAt != nil || param.EndAt != nil
+	linkModified := param.Link != nil || param.LinkType != nil || param.Platform != nil
+	var existsBanner *model.Banner
+	if param.Img != nil || scheduleModified || linkModified {
+		var err error
+		existsBanner, err = s.dao.GetBanner(param.Id)
 		if err != nil {
 			return err
 		}
@@ -178,42 +537,213 @@ func (s *Service) ModifyBanner(param *BannerModifyParam) error {
 		if existsBanner == nil {
 			return ecode.InvalidId
 		}
+	}
+
+	// 展示时间段和原来的合并后再校验
+	var startAt, endAt *time.Time
+	if scheduleModified {
+		startAt = mergeBannerScheduleTime(existsBanner.StartAt, param.StartAt)
+		endAt = mergeBannerScheduleTime(existsBanner.EndAt, param.EndAt)
+		if !checkBannerSchedule(startAt, endAt) {
+			return ecode.BannerScheduleInvalid
+		}
+	}
 
-		// 先保存新文件到本地
-		storageOption := s.config.Server.FileStorageOption
-		localFileLoc, err := s.processBannerImg(param.Img)
-		if err != nil {
+	// 链接、链接类型和平台和原来的合并后再校验
+	if linkModified {
+		platform, link, linkType := *existsBanner.Platform, "", ""
+		if param.Platform != nil {
+			platform = *param.Platform
+		}
+		if param.Link != nil {
+			link = *param.Link
+		} else if existsBanner.Link != nil {
+			link = *existsBanner.Link
+		}
+		if param.LinkType != nil {
+			linkType = *param.LinkType
+		} else if existsBanner.LinkType != nil {
+			linkType = *existsBanner.LinkType
+		}
+		// 旧数据没有链接类型
+		if linkType == "" {
+			linkType = inferBannerLinkType(link)
+		}
+
+		if err := s.checkBannerLink(platform, linkType, link); err != nil {
 			return err
 		}
+		banner.LinkType = &linkType
+	}
 
-		// 如果原来有文件就删除
-		resourceStorageOption := storageOption.ResourceStorageOption
-		ossObjectKey := fmt.Sprintf("%s/%s.jpg", resourceStorageOption.PicStorageBasePath, *existsBanner.Img)
-		if banner.Img != nil && *banner.Img != "" {
-			err = s.ossBucket.SetObjectACL(ossObjectKey, oss.ACLPrivate)
-			if err != nil {
-				log.Warn("删除已存在的oss文件出现错误", zap.String("oss_key", ossObjectKey), zap.Error(err))
-			} else {
-				log.Info("删除已存在的oss文件完成", zap.String("oss_key", ossObjectKey))
-			}
+	// banner图片需要修改
+	localFile := ""
+	var variants []bannerImgVariant
+	if param.Img != nil {
+		// 先保存新文件到本地，修改平台或展示位时按新的展示位校验
+		platform, slot := *existsBanner.Platform, bannerSlotOrDefault(existsBanner.Slot)
+		if param.Platform != nil {
+			platform = *param.Platform
+		}
+		if param.Slot != nil {
+			slot = *param.Slot
+		}
+		img, err := s.processBannerImg(param.Img, platform, slot)
+		if err != nil {
+			return err
 		}
 
+		// 旧图片不再被引用后由存储清理任务回收，新图片上传成功前旧图片仍然可以访问
 		imgId := fmt.Sprintf("%d/v1.%d.%d", param.Id, idgen.NextId(), time.Now().UnixMilli())
 		banner.Img = &imgId
+		banner.ImgVariants = img.variants()
+		localFile = img.LocalFile
+		variants = img.Variants
+	}
 
-		// 上传新文件
-		go s.uploadBannerPic(imgId, localFileLoc)
+	// 上传新文件的任务和轮播图一起写入
+	jobs := make([]model.Job, 0, 1)
+	if localFile != "" {
+		job, err := s.newJob(JobTypeBannerImgUpload, bannerImgUploadJob{
+			BannerId:  param.Id,
+			ImgId:     *banner.Img,
+			LocalFile: localFile,
+			Variants:  variants,
+		})
+		if err != nil {
+			return err
+		}
+		jobs = append(jobs, *job)
 	}
 
 	*banner.UpdateTime = time.Now()
-	_, err := s.dao.UpdateBanner(&banner)
+	_, err := s.dao.UpdateBanner(&banner, jobs...)
 	if err != nil {
 		return err
 	}
 
+	if scheduleModified {
+		_, err = s.dao.UpdateBannerSchedule(param.Id, startAt, endAt)
+		if err != nil {
+			return err
+		}
+	}
+
+	s.releaseUploadFile(param.Img)
+
+	return nil
+}
+
+// inferBannerLinkType 只能确定空链接和http链接的类型，其余的返回空
+func inferBannerLinkType(link string) string {
+	if link == "" {
+		return model.BannerLinkTypeNone
+	} else if isHttpUrl(link) {
+		return model.BannerLinkTypeUrl
+	}
+
+	return ""
+}
+
+// BannerLinkType 轮播图的链接类型，旧数据没有链接类型时按链接推断，推断不出时返回空
+func (s *Service) BannerLinkType(banner *model.Banner) string {
+	if banner.LinkType != nil && *banner.LinkType != "" {
+		return *banner.LinkType
+	} else if banner.Link == nil {
+		return model.BannerLinkTypeNone
+	}
+
+	return inferBannerLinkType(*banner.Link)
+}
+
+// checkBannerLink 校验链接类型是否被平台支持，以及链接是否在配置的域名、小程序页面或app路由中
+func (s *Service) checkBannerLink(platform, linkType, link string) error {
+	option := s.config.Server.BannerOption.Link
+	if types, ok := option.Types[platform]; ok && !slices.Contains(types, linkType) {
+		log.Warn("轮播图链接类型不被平台支持", zap.String("platform", platform), zap.String("link_type", linkType))
+		return ecode.BannerLinkInvalid
+	}
+
+	valid := false
+	switch linkType {
+	case model.BannerLinkTypeNone:
+		valid = link == ""
+	case model.BannerLinkTypeUrl:
+		valid = isHttpUrl(link) && (len(option.UrlDomains) == 0 || matchUrlDomain(link, option.UrlDomains))
+	case model.BannerLinkTypeMpPage:
+		page := strings.TrimPrefix(linkPath(link), "/")
+		valid = page != "" && (len(option.MpPages) == 0 || slices.Contains(option.MpPages, page))
+	case model.BannerLinkTypeAppRoute:
+		route := linkPath(link)
+		valid = route != "" && (len(option.AppRoutes) == 0 || slices.Contains(option.AppRoutes, route))
+	}
+
+	if !valid {
+		log.Warn("轮播图链接不合法", zap.String("platform", platform), zap.String("link_type", linkType), zap.String("link", link))
+		return ecode.BannerLinkInvalid
+	}
+
 	return nil
 }
 
+// matchUrlDomain 链接的域名是否为domains中的域名或其子域名
+func matchUrlDomain(link string, domains []string) bool {
+	u, err := url.Parse(link)
+	if err != nil {
+		return false
+	}
+
+	host := strings.ToLower(u.Hostname())
+	for _, domain := range domains {
+		domain = strings.ToLower(strings.TrimPrefix(domain, "."))
+		if host == domain || strings.HasSuffix(host, "."+domain) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// linkPath 去掉小程序页面路径或app路由中的参数
+func linkPath(link string) string {
+	path, _, _ := strings.Cut(link, "?")
+	return strings.TrimSpace(path)
+}
+
+// checkBannerSchedule 开始和结束时间都有时，结束时间需要晚于开始时间
+func checkBannerSchedule(startAt, endAt *time.Time) bool {
+	return startAt == nil || endAt == nil || endAt.After(*startAt)
+}
+
+// mergeBannerScheduleTime modified为nil时保持原来的时间，为零值时取消
+func mergeBannerScheduleTime(origin, modified *time.Time) *time.Time {
+	if modified == nil {
+		return origin
+	} else if modified.IsZero() {
+		return nil
+	}
+
+	return modified
+}
+
+// bannerSlotOrDefault 没有展示位的数据视为首页轮播
+func bannerSlotOrDefault(slot *string) string {
+	if slot == nil || *slot == "" {
+		return model.BannerSlotHome
+	}
+
+	return *slot
+}
+
+// ReorderBanner 按拖拽排序后的顺序设置排序权重，id排在前边的展示在前边
+func (s *Service) ReorderBanner(id ...int64) error {
+	if len(id) == 0 {
+		return ecode.ParamWrong
+	}
+
+	return s.dao.ReorderBanner(id...)
+}
+
 func (s *Service) DeleteBanner(id int64) error {
 	existsBanner, err := s.dao.GetBanner(id)
 	if err != nil {
@@ -230,16 +760,7 @@ func (s *Service) DeleteBanner(id int64) error {
 	}
 
 	// 如果有文件记录，删除oss文件（仅设置不可见）
-	if existsBanner.Img != nil && *existsBanner.Img != "" {
-		resourceStorageOption := s.config.Server.FileStorageOption.ResourceStorageOption
-		ossObjectKey := fmt.Sprintf("%s/%s.jpg", resourceStorageOption.PicStorageBasePath, *existsBanner.Img)
-		err = s.ossBucket.SetObjectACL(ossObjectKey, oss.ACLPrivate)
-		if err != nil {
-			log.Warn("删除oss文件出现错误", zap.String("oss_key", ossObjectKey), zap.Error(err))
-		} else {
-			log.Info("删除oss文件完成", zap.String("oss_key", ossObjectKey))
-		}
-	}
+	s.hideBannerImg(existsBanner)
 
 	return nil
 }
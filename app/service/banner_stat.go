@@ -0,0 +1,138 @@
+package service
+
+import (
+	"context"
+	"github.com/yitter/idgenerator-go/idgen"
+	"math/big"
+	"time"
+	"wusthelper-manager-go/app/model"
+	"wusthelper-manager-go/library/ecode"
+)
+
+// BannerShortCode 轮播图短链接的code，为轮播图id的62进制表示，链接修改后短链接不变
+func (s *Service) BannerShortCode(id int64) string {
+	return big.NewInt(id).Text(62)
+}
+
+// GetBannerByShortCode 获取短链接对应的轮播图，code不正确、轮播图不存在或没有链接时返回ecode.InvalidId。
+// 下线的轮播图仍然可以跳转，客户端可能缓存了之前的列表
+func (s *Service) GetBannerByShortCode(code string) (*model.Banner, error) {
+	id, ok := new(big.Int).SetString(code, 62)
+	if !ok || !id.IsInt64() || id.Sign() <= 0 {
+		return nil, ecode.InvalidId
+	}
+
+	banner, err := s.dao.GetBanner(id.Int64())
+	if err != nil {
+		return nil, err
+	} else if banner == nil || banner.Link == nil || *banner.Link == "" {
+		return nil, ecode.InvalidId
+	}
+
+	return banner, nil
+}
+
+// RecordBannerImpression 记录轮播图的一次展示，计数先存在redis中，定期写入数据库
+func (s *Service) RecordBannerImpression(banner ...model.Banner) error {
+	ctx := context.Background()
+	return s.dao.IncreaseBannerImpressionCount(&ctx, time.Now().Format(time.DateOnly), banner...)
+}
+
+// RecordBannerClick 记录轮播图的一次点击，轮播图在进行中的实验里时同时记录到实验。计数先存在redis中，定期写入数据库
+func (s *Service) RecordBannerClick(banner *model.Banner) error {
+	ctx := context.Background()
+	err := s.dao.IncreaseBannerClickCount(&ctx, time.Now().Format(time.DateOnly), banner.ID, *banner.Platform)
+	if err != nil {
+		return err
+	}
+
+	return s.recordBannerExperimentClick(banner.ID)
+}
+
+// flushBannerStats 定期把redis中的轮播图计数写入数据库
+func (s *Service) flushBannerStats() {
+	s.runCounterFlusher(counterFlusher{
+		name:      "轮播图统计",
+		buckets:   s.dao.GetBannerStatDays,
+		flush:     s.flushBannerStatsOfDay,
+		removeDay: s.dao.RemoveBannerStatDay,
+	})
+}
+
+func (s *Service) flushBannerStatsOfDay(ctx *context.Context, day string, now time.Time) error {
+	date, err := parseCounterDay(day)
+	if err != nil {
+		return err
+	}
+
+	counts, err := s.dao.GetBannerStatCounts(ctx, day)
+	if err != nil {
+		return err
+	}
+
+	for i := range counts {
+		stat := &counts[i]
+		stat.ID, stat.Date, stat.UpdateTime = idgen.NextId(), &date, &now
+		err = s.dao.SaveBannerStat(stat)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// BannerStatReportItem 一个轮播图在一个平台上的展示和点击汇总，Banner在轮播图记录不存在时为nil
+type BannerStatReportItem struct {
+	Banner      *model.Banner
+	BannerId    int64
+	Platform    string
+	Impressions int64
+	Clicks      int64
+	Ctr         float64 // 点击率，没有展示时为0
+}
+
+// GetBannerStatReport 获取[start, end]内各轮播图的点击率报告，按展示次数从大到小排列
+func (s *Service) GetBannerStatReport(bannerId int64, platform string, start, end time.Time) ([]BannerStatReportItem, error) {
+	if end.Before(start) {
+		return nil, ecode.ParamWrong
+	}
+
+	statList, err := s.dao.GetBannerStatSummary(bannerId, platform, start, end)
+	if err != nil {
+		return nil, err
+	}
+
+	bannerIds := make([]int64, len(statList))
+	for i, stat := range statList {
+		bannerIds[i] = *stat.BannerId
+	}
+
+	bannerList, err := s.dao.GetBannerListByIds(bannerIds...)
+	if err != nil {
+		return nil, err
+	}
+
+	banners := make(map[int64]*model.Banner, len(*bannerList))
+	for i := range *bannerList {
+		banners[(*bannerList)[i].ID] = &(*bannerList)[i]
+	}
+
+	result := make([]BannerStatReportItem, len(statList))
+	for i, stat := range statList {
+		item := BannerStatReportItem{
+			Banner:      banners[*stat.BannerId],
+			BannerId:    *stat.BannerId,
+			Platform:    *stat.Platform,
+			Impressions: *stat.Impressions,
+			Clicks:      *stat.Clicks,
+		}
+		if item.Impressions > 0 {
+			item.Ctr = float64(item.Clicks) / float64(item.Impressions)
+		}
+
+		result[i] = item
+	}
+
+	return result, nil
+}
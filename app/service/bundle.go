@@ -0,0 +1,529 @@
+package service
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	jsoniter "github.com/json-iterator/go"
+	"github.com/yitter/idgenerator-go/idgen"
+	"go.uber.org/zap"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+	"wusthelper-manager-go/app/model"
+	"wusthelper-manager-go/common"
+	"wusthelper-manager-go/library/ecode"
+	"wusthelper-manager-go/library/log"
+	"wusthelper-manager-go/library/semver"
+)
+
+const (
+	_maxBundleFileCount = 10000
+	_maxBundleTotalSize = 500 << 20 // 解压后的总大小
+)
+
+func (s *Service) GetBundleList(pagination common.Pagination, platform, name string) (*[]model.Bundle, int64, error) {
+	return s.dao.GetBundleList(pagination, platform, name)
+}
+
+// BundleObjectKey 资源包中文件在存储中的位置，按sha256存放
+func (s *Service) BundleObjectKey(sha256Text string) string {
+	basePath := s.config.Server.FileStorageOption.ResourceStorageOption.BundleStorageBasePath
+	return path.Join(basePath, "objects", sha256Text[:2], sha256Text)
+}
+
+type BundleAddParam struct {
+	Name             string
+	Version          string
+	Platform         string
+	MinNativeVersion string // 为空时不限制
+	MaxNativeVersion string
+	Summary          string
+	UploadFile       *File // zip文件
+}
+
+// AddBundle 解压上传的zip，计算每个文件的sha256生成文件清单，文件在后台任务中上传到存储，上传完成后才能发布
+func (s *Service) AddBundle(param *BundleAddParam) error {
+	if param.Name == "" || param.Platform == "" || param.UploadFile == nil {
+		return ecode.ParamWrong
+	}
+
+	if _, err := semver.Parse(param.Version); err != nil {
+		return ecode.VersionTextInvalid
+	}
+
+	var minNative, maxNative *semver.Version
+	var err error
+	if param.MinNativeVersion != "" {
+		if minNative, err = semver.Parse(param.MinNativeVersion); err != nil {
+			return ecode.VersionTextInvalid
+		}
+	}
+	if param.MaxNativeVersion != "" {
+		if maxNative, err = semver.Parse(param.MaxNativeVersion); err != nil {
+			return ecode.VersionTextInvalid
+		}
+	}
+	if minNative != nil && maxNative != nil && minNative.Compare(maxNative) > 0 {
+		return ecode.ParamWrong
+	}
+
+	existing, err := s.dao.GetBundleByVersion(param.Name, param.Platform, param.Version)
+	if err != nil {
+		return err
+	} else if existing != nil {
+		return ecode.BundleVersionExists
+	}
+
+	bundleId := idgen.NextId()
+	localDir := fmt.Sprintf("%s/bundle-%d", s.config.Server.FileStorageOption.UploadFileLocalTmpPath, bundleId)
+	manifest, totalSize, err := unpackBundle(param.UploadFile, localDir)
+	if err != nil {
+		_ = os.RemoveAll(localDir)
+		return err
+	}
+
+	manifestText, err := jsoniter.MarshalToString(manifest)
+	if err != nil {
+		_ = os.RemoveAll(localDir)
+		log.Error("序列化资源包文件清单时出现错误", zap.Int64("id", bundleId), zap.Error(err))
+		return ecode.InternalError
+	}
+
+	now, ready, fileCount := time.Now(), false, len(manifest)
+	bundle := model.Bundle{
+		ID:               bundleId,
+		Name:             &param.Name,
+		VersionText:      &param.Version,
+		Platform:         &param.Platform,
+		MinNativeVersion: &param.MinNativeVersion,
+		MaxNativeVersion: &param.MaxNativeVersion,
+		Summary:          &param.Summary,
+		Manifest:         &manifestText,
+		FileCount:        &fileCount,
+		TotalSize:        &totalSize,
+		Ready:            &ready,
+		CreateTime:       &now,
+		UpdateTime:       &now,
+		Status:           new(int8),
+	}
+
+	// 上传文件的任务和资源包一起写入
+	job, err := s.newJob(JobTypeBundleUpload, bundleUploadJob{
+		BundleId: bundleId,
+		LocalDir: localDir,
+	})
+	if err != nil {
+		_ = os.RemoveAll(localDir)
+		return err
+	}
+
+	_, err = s.dao.AddBundle(&bundle, *job)
+	if err != nil {
+		_ = os.RemoveAll(localDir)
+		return err
+	}
+
+	s.releaseUploadFile(param.UploadFile)
+
+	log.Info("资源包已添加",
+		zap.Int64("id", bundleId),
+		zap.String("name", param.Name),
+		zap.String("version", param.Version),
+		zap.Int("file_count", fileCount),
+	)
+	return nil
+}
+
+// unpackBundle 解压zip到localDir，文件以sha256命名，返回文件清单和解压后的总大小。
+// 包含绝对路径、..等非法路径，或文件数量、总大小超出限制时返回错误
+func unpackBundle(file *File, localDir string) (model.BundleManifest, int64, error) {
+	var reader io.ReaderAt
+	var size int64
+	if file.LocalPath != "" {
+		f, err := os.Open(file.LocalPath)
+		if err != nil {
+			log.Error("打开资源包文件时出现错误", zap.String("file", file.LocalPath), zap.Error(err))
+			return nil, 0, ecode.InternalError
+		}
+		defer f.Close()
+
+		stat, err := f.Stat()
+		if err != nil {
+			log.Error("获取资源包文件信息时出现错误", zap.String("file", file.LocalPath), zap.Error(err))
+			return nil, 0, ecode.InternalError
+		}
+		reader, size = f, stat.Size()
+	} else {
+		reader, size = bytes.NewReader(*file.Data), int64(len(*file.Data))
+	}
+
+	zipReader, err := zip.NewReader(reader, size)
+	if err != nil {
+		log.Warn("解析资源包zip时出现错误", zap.String("file", file.FileName), zap.Error(err))
+		return nil, 0, ecode.BundleFileInvalid
+	}
+
+	if err = os.MkdirAll(localDir, 0755); err != nil {
+		log.Error("创建资源包临时目录时出现错误", zap.String("dir", localDir), zap.Error(err))
+		return nil, 0, ecode.InternalError
+	}
+
+	manifest := model.BundleManifest{}
+	totalSize := int64(0)
+	for _, zipFile := range zipReader.File {
+		if zipFile.FileInfo().IsDir() {
+			continue
+		}
+
+		name, ok := cleanBundlePath(zipFile.Name)
+		if !ok {
+			log.Warn("资源包中有非法路径", zap.String("file", file.FileName), zap.String("path", zipFile.Name))
+			return nil, 0, ecode.BundleFileInvalid
+		}
+
+		if len(manifest) >= _maxBundleFileCount {
+			log.Warn("资源包中的文件数量超出限制", zap.String("file", file.FileName))
+			return nil, 0, ecode.BundleFileInvalid
+		}
+
+		entry, err := unpackBundleFile(zipFile, localDir, _maxBundleTotalSize-totalSize)
+		if err != nil {
+			log.Warn("解压资源包中的文件时出现错误",
+				zap.String("file", file.FileName),
+				zap.String("path", zipFile.Name),
+				zap.Error(err),
+			)
+			return nil, 0, ecode.BundleFileInvalid
+		}
+
+		manifest[name] = *entry
+		totalSize += entry.Size
+	}
+
+	if len(manifest) == 0 {
+		return nil, 0, ecode.BundleFileInvalid
+	}
+
+	return manifest, totalSize, nil
+}
+
+// cleanBundlePath 规范化包内的路径，不允许跳出包的根目录
+func cleanBundlePath(name string) (string, bool) {
+	if name == "" || strings.Contains(name, "\\") || strings.HasPrefix(name, "/") {
+		return "", false
+	}
+
+	for _, part := range strings.Split(name, "/") {
+		if part == ".." {
+			return "", false
+		}
+	}
+
+	return path.Clean(name), true
+}
+
+// unpackBundleFile 解压一个文件到localDir下以sha256命名的文件中，解压后的大小超过limit时返回错误
+func unpackBundleFile(zipFile *zip.File, localDir string, limit int64) (*model.BundleFile, error) {
+	src, err := zipFile.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer src.Close()
+
+	dst, err := os.CreateTemp(localDir, "unpack-*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(dst.Name())
+	defer dst.Close()
+
+	hash := sha256.New()
+	size, err := io.Copy(io.MultiWriter(dst, hash), io.LimitReader(src, limit+1))
+	if err != nil {
+		return nil, err
+	} else if size > limit {
+		return nil, fmt.Errorf("资源包解压后的大小超出限制")
+	}
+
+	if err = dst.Close(); err != nil {
+		return nil, err
+	}
+
+	sha256Text := hex.EncodeToString(hash.Sum(nil))
+	if err = os.Rename(dst.Name(), path.Join(localDir, sha256Text)); err != nil {
+		return nil, err
+	}
+
+	return &model.BundleFile{Sha256: sha256Text, Size: size}, nil
+}
+
+// PublishBundle 发布资源包，文件未上传完成的资源包不能发布
+func (s *Service) PublishBundle(id int64) error {
+	return s.updateBundleStatus(id, model.BundlePublishedStatus)
+}
+
+// OfflineBundle 下线资源包，客户端不再获取到该版本，已下载该版本的客户端仍然可以获取增量更新
+func (s *Service) OfflineBundle(id int64) error {
+	return s.updateBundleStatus(id, model.NormalStatus)
+}
+
+func (s *Service) updateBundleStatus(id int64, status int8) error {
+	bundle, err := s.dao.GetBundle(id)
+	if err != nil {
+		return err
+	} else if bundle == nil {
+		return ecode.BundleOperationFailed
+	} else if bundle.Ready == nil || !*bundle.Ready {
+		return ecode.BundleNotReady
+	}
+
+	_, err = s.dao.UpdateBundleStatus(id, status)
+	if err != nil {
+		return err
+	}
+
+	log.Info("资源包状态已修改", zap.Int64("id", id), zap.Int8("status", status))
+	return nil
+}
+
+// DeleteBundle 删除资源包，存储中的文件可能被其他版本引用，不在这里删除
+func (s *Service) DeleteBundle(id int64) error {
+	count, err := s.dao.DeleteBundle(id)
+	if err != nil {
+		return err
+	} else if count == 0 {
+		return ecode.BundleOperationFailed
+	}
+
+	log.Info("资源包已删除", zap.Int64("id", id))
+	return nil
+}
+
+// BundleFileEntry 文件清单中的一项
+type BundleFileEntry struct {
+	Path string
+	model.BundleFile
+}
+
+// BundleUpdate 客户端从当前资源包版本更新到最新版本需要的变化
+type BundleUpdate struct {
+	Bundle   *model.Bundle
+	UpToDate bool
+	Full     bool // 客户端当前的版本未知时，Added为完整的文件清单
+	Added    []BundleFileEntry
+	Modified []BundleFileEntry
+	Removed  []string
+}
+
+// GetBundleUpdate 获取客户端原生版本可用的最新资源包，以及与客户端当前资源包版本的文件清单差异，没有可用的资源包时返回nil
+func (s *Service) GetBundleUpdate(name, platform, nativeVersion, bundleVersion string) (*BundleUpdate, error) {
+	latest, err := s.getLatestBundle(name, platform, nativeVersion)
+	if err != nil || latest == nil {
+		return nil, err
+	}
+
+	result := &BundleUpdate{Bundle: latest}
+	if bundleVersion == *latest.VersionText {
+		result.UpToDate = true
+		return result, nil
+	}
+
+	// 列表中不包括文件清单，需要重新获取
+	latest, err = s.dao.GetBundle(latest.ID)
+	if err != nil {
+		return nil, err
+	} else if latest == nil {
+		return nil, nil
+	}
+	result.Bundle = latest
+
+	latestManifest, err := parseBundleManifest(latest)
+	if err != nil {
+		return nil, err
+	}
+
+	var baseManifest model.BundleManifest
+	if bundleVersion != "" {
+		base, err := s.dao.GetBundleByVersion(name, platform, bundleVersion)
+		if err != nil {
+			return nil, err
+		} else if base != nil {
+			if baseManifest, err = parseBundleManifest(base); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if baseManifest == nil {
+		result.Full = true
+		baseManifest = model.BundleManifest{}
+	}
+
+	for filePath, file := range latestManifest {
+		baseFile, ok := baseManifest[filePath]
+		if !ok {
+			result.Added = append(result.Added, BundleFileEntry{Path: filePath, BundleFile: file})
+		} else if baseFile.Sha256 != file.Sha256 {
+			result.Modified = append(result.Modified, BundleFileEntry{Path: filePath, BundleFile: file})
+		}
+	}
+	for filePath := range baseManifest {
+		if _, ok := latestManifest[filePath]; !ok {
+			result.Removed = append(result.Removed, filePath)
+		}
+	}
+
+	sort.Slice(result.Added, func(i, j int) bool { return result.Added[i].Path < result.Added[j].Path })
+	sort.Slice(result.Modified, func(i, j int) bool { return result.Modified[i].Path < result.Modified[j].Path })
+	sort.Strings(result.Removed)
+
+	return result, nil
+}
+
+// getLatestBundle 按语义化版本号获取原生版本兼容范围包含nativeVersion的最新已发布资源包，
+// nativeVersion不合法时只考虑没有限制原生版本的资源包
+func (s *Service) getLatestBundle(name, platform, nativeVersion string) (*model.Bundle, error) {
+	bundleList, err := s.dao.GetPublishedBundleList(name, platform)
+	if err != nil {
+		return nil, err
+	}
+
+	native, _ := semver.Parse(nativeVersion)
+
+	var latest *model.Bundle
+	var latestSemver *semver.Version
+	for i := range *bundleList {
+		bundle := &(*bundleList)[i]
+		if !bundleSupportsNative(bundle, native) {
+			continue
+		}
+
+		bundleSemver, err := semver.Parse(*bundle.VersionText)
+		if err != nil {
+			continue
+		}
+		if latest == nil || compareSemver(bundleSemver, latestSemver) > 0 {
+			latest, latestSemver = bundle, bundleSemver
+		}
+	}
+
+	return latest, nil
+}
+
+func bundleSupportsNative(bundle *model.Bundle, native *semver.Version) bool {
+	minText, maxText := "", ""
+	if bundle.MinNativeVersion != nil {
+		minText = *bundle.MinNativeVersion
+	}
+	if bundle.MaxNativeVersion != nil {
+		maxText = *bundle.MaxNativeVersion
+	}
+	if minText == "" && maxText == "" {
+		return true
+	} else if native == nil {
+		return false
+	}
+
+	if minText != "" {
+		minNative, err := semver.Parse(minText)
+		if err != nil || native.Compare(minNative) < 0 {
+			return false
+		}
+	}
+	if maxText != "" {
+		maxNative, err := semver.Parse(maxText)
+		if err != nil || native.Compare(maxNative) > 0 {
+			return false
+		}
+	}
+
+	return true
+}
+
+func parseBundleManifest(bundle *model.Bundle) (model.BundleManifest, error) {
+	manifest := model.BundleManifest{}
+	if isEmptyString(bundle.Manifest) {
+		return manifest, nil
+	}
+
+	if err := jsoniter.UnmarshalFromString(*bundle.Manifest, &manifest); err != nil {
+		log.Error("解析资源包文件清单时出现错误", zap.Int64("id", bundle.ID), zap.Error(err))
+		return nil, ecode.InternalError
+	}
+
+	return manifest, nil
+}
+
+type bundleUploadJob struct {
+	BundleId int64  `json:"bundle_id"`
+	LocalDir string `json:"local_dir"`
+}
+
+// runBundleUploadJob 上传资源包中的文件，存储中已有的文件（其他版本上传过的）跳过，全部完成后标记为可发布
+func (s *Service) runBundleUploadJob(payload []byte) error {
+	job := new(bundleUploadJob)
+	if err := jsoniter.Unmarshal(payload, job); err != nil {
+		return err
+	}
+
+	bundle, err := s.dao.GetBundle(job.BundleId)
+	if err != nil {
+		return err
+	} else if bundle == nil {
+		_ = os.RemoveAll(job.LocalDir)
+		return nil
+	}
+
+	manifest, err := parseBundleManifest(bundle)
+	if err != nil {
+		return err
+	}
+
+	uploaded := map[string]bool{}
+	for _, file := range manifest {
+		if uploaded[file.Sha256] {
+			continue
+		}
+
+		key, localFile := s.BundleObjectKey(file.Sha256), path.Join(job.LocalDir, file.Sha256)
+		exists, err := s.storage.Exists(key)
+		if err != nil {
+			return err
+		}
+		if exists {
+			_ = os.Remove(localFile)
+		} else if err = s.uploadLocalFile(key, localFile, ""); err != nil {
+			return err
+		}
+		uploaded[file.Sha256] = true
+	}
+
+	_, err = s.dao.SetBundleReady(job.BundleId)
+	if err != nil {
+		return err
+	}
+
+	if err = os.RemoveAll(job.LocalDir); err != nil {
+		log.Warn("移除资源包临时目录时出现异常", zap.String("dir", job.LocalDir), zap.Error(err))
+	}
+	log.Info("资源包文件上传完成", zap.Int64("id", job.BundleId), zap.Int("object_count", len(uploaded)))
+
+	return nil
+}
+
+// onBundleUploadJobFail 文件最终上传失败时删除该资源包，避免发布缺少文件的版本
+func (s *Service) onBundleUploadJobFail(payload []byte) {
+	job := new(bundleUploadJob)
+	if err := jsoniter.Unmarshal(payload, job); err != nil {
+		return
+	}
+
+	_, _ = s.dao.DeleteBundle(job.BundleId)
+	_ = os.RemoveAll(job.LocalDir)
+}
@@ -1,6 +1,61 @@
 package service
 
+import (
+	"io"
+	"os"
+)
+
 type File struct {
 	Data     *[]byte
 	FileName string
+	// LocalPath 已经在本地的文件（如分片上传合并后的文件），不为空时忽略Data
+	LocalPath string
+	// UploadId 文件来自分片上传时的上传会话id
+	UploadId string
+}
+
+// SaveTo 将文件保存到本地dst位置。已在本地的文件硬链接过去，不能链接时复制，
+// 原文件保留到上传会话释放，使用方写数据库失败时客户端还可以用同一个上传会话重试
+func (f *File) SaveTo(dst string) error {
+	if f.LocalPath == "" {
+		return os.WriteFile(dst, *f.Data, 0664)
+	}
+
+	if err := os.Link(f.LocalPath, dst); err == nil {
+		return nil
+	}
+
+	src, err := os.Open(f.LocalPath)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(out, src)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(dst)
+	}
+
+	return err
+}
+
+// Size 文件大小
+func (f *File) Size() int64 {
+	if f.LocalPath != "" {
+		info, err := os.Stat(f.LocalPath)
+		if err != nil {
+			return 0
+		}
+		return info.Size()
+	}
+
+	return int64(len(*f.Data))
 }
@@ -0,0 +1,63 @@
+package service
+
+import (
+	"context"
+	"go.uber.org/zap"
+	"time"
+	"wusthelper-manager-go/library/log"
+)
+
+const (
+	_counterFlushPeriod = time.Minute * 5
+	// 按天的计数写入数据库后，超过这么多天的从redis中删除，留一天余量给跨零点的请求
+	_dailyCounterKeepDays = 1
+)
+
+// counterFlusher 定期把redis中按桶累计的计数写入数据库，写入的是桶的累计值，重复写入不会重复计数
+type counterFlusher struct {
+	name    string // 用于日志
+	buckets func(ctx *context.Context) ([]string, error)
+	flush   func(ctx *context.Context, bucket string, now time.Time) error
+	// 桶为yyyy-MM-dd的日期时设置，写入后超过保留天数的桶和格式不对的桶从redis中删除，为nil时桶一直保留
+	removeDay func(ctx *context.Context, day string) error
+}
+
+func (s *Service) runCounterFlusher(flusher counterFlusher) {
+	ticker := time.NewTicker(_counterFlushPeriod)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		ctx := context.Background()
+		buckets, err := flusher.buckets(&ctx)
+		if err != nil {
+			continue
+		}
+
+		now := time.Now()
+		expireDate := now.AddDate(0, 0, -_dailyCounterKeepDays).Format(time.DateOnly)
+		for _, bucket := range buckets {
+			if flusher.removeDay != nil {
+				if _, err = parseCounterDay(bucket); err != nil {
+					// 格式不对的日期不会再被写入，直接清掉
+					_ = flusher.removeDay(&ctx, bucket)
+					continue
+				}
+			}
+
+			err = flusher.flush(&ctx, bucket, now)
+			if err != nil {
+				log.Warn("写入"+flusher.name+"时出现错误", zap.String("bucket", bucket), zap.Error(err))
+				continue
+			}
+
+			if flusher.removeDay != nil && bucket < expireDate {
+				_ = flusher.removeDay(&ctx, bucket)
+			}
+		}
+	}
+}
+
+// parseCounterDay 解析按天计数的桶
+func parseCounterDay(day string) (time.Time, error) {
+	return time.ParseInLocation(time.DateOnly, day, time.Local)
+}
@@ -0,0 +1,139 @@
+package service
+
+import (
+	"context"
+	"github.com/yitter/idgenerator-go/idgen"
+	"slices"
+	"time"
+	"wusthelper-manager-go/app/model"
+	"wusthelper-manager-go/library/ecode"
+)
+
+// GetDownloadVersion 获取要下载的版本，id为0时获取客户端应使用的最新版本。
+// 指定id时只能下载客户端可见的版本，没有版本、版本不可见或版本没有安装包时返回ecode.VersionFileNotFound
+func (s *Service) GetDownloadVersion(id int64, platform string, client VersionClient) (*model.Version, error) {
+	var version *model.Version
+	var err error
+	if id != 0 {
+		version, err = s.dao.GetVersion(id)
+		if err == nil && version != nil {
+			var visible bool
+			visible, err = s.isVersionVisibleToClient(version, client)
+			if !visible {
+				version = nil
+			}
+		}
+	} else {
+		version, err = s.GetLatestVersionForClient(platform, client)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if version == nil || version.File == nil || *version.File == "" {
+		return nil, ecode.VersionFileNotFound
+	}
+
+	return version, nil
+}
+
+// GetDownloadArtifact 获取客户端可以下载的版本文件及其所属版本，文件未上传完成或版本对客户端不可见时返回不存在
+func (s *Service) GetDownloadArtifact(id int64, client VersionClient) (*model.VersionArtifact, *model.Version, error) {
+	artifact, err := s.dao.GetVersionArtifact(id)
+	if err != nil {
+		return nil, nil, err
+	}
+	if artifact == nil || artifact.Ready == nil || !*artifact.Ready {
+		return nil, nil, ecode.VersionFileNotFound
+	}
+
+	version, err := s.GetDownloadVersion(*artifact.VersionId, "", client)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return artifact, version, nil
+}
+
+// isVersionVisibleToClient 版本是否对客户端可见，规则和 GetLatestVersionForClient 一致：
+// 发布过的版本对能使用其渠道的客户端可见，灰度中的版本只对落在灰度范围内的客户端可见，草稿和已删除的版本都不可见
+func (s *Service) isVersionVisibleToClient(version *model.Version, client VersionClient) (bool, error) {
+	channel := model.VersionChannelStable
+	if version.Channel != nil {
+		channel = *version.Channel
+	}
+
+	switch *version.Status {
+	case model.VersionPublishedStatus:
+	case model.NormalStatus:
+		// 被新版本替换的已发布版本会恢复为普通状态，仍然可以下载
+		published, err := s.dao.HasVersionPublishHistory(version.ID)
+		if err != nil || !published {
+			return false, err
+		}
+	case model.VersionRollingStatus:
+		clientId := s.clientRolloutId(client)
+		if clientId == "" || (version.RolloutPaused != nil && *version.RolloutPaused) || version.RolloutPercent == nil {
+			return false, nil
+		}
+
+		return inRolloutBucket(version.ID, clientId, *version.RolloutPercent), nil
+	default:
+		return false, nil
+	}
+
+	channels, err := s.getClientChannels(client)
+	if err != nil {
+		return false, err
+	}
+
+	return slices.Contains(channels, channel), nil
+}
+
+// RecordDownload 记录一次版本下载，计数先存在redis中，定期写入数据库
+func (s *Service) RecordDownload(version *model.Version) error {
+	ctx := context.Background()
+	return s.dao.IncreaseDownloadCount(&ctx, time.Now().Format(time.DateOnly), version.ID, *version.Platform)
+}
+
+// flushDownloadStats 定期把redis中的下载计数写入数据库
+func (s *Service) flushDownloadStats() {
+	s.runCounterFlusher(counterFlusher{
+		name:      "下载统计",
+		buckets:   s.dao.GetDownloadCountDays,
+		flush:     s.flushDownloadStatsOfDay,
+		removeDay: s.dao.RemoveDownloadCountDay,
+	})
+}
+
+func (s *Service) flushDownloadStatsOfDay(ctx *context.Context, day string, now time.Time) error {
+	date, err := parseCounterDay(day)
+	if err != nil {
+		return err
+	}
+
+	counts, err := s.dao.GetDownloadCounts(ctx, day)
+	if err != nil {
+		return err
+	}
+
+	for i := range counts {
+		stat := &counts[i]
+		stat.ID, stat.Date, stat.UpdateTime = idgen.NextId(), &date, &now
+		err = s.dao.SaveDownloadStat(stat)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// GetDownloadStatSeries 获取[start, end]内每天的下载次数，没有下载的日期不返回
+func (s *Service) GetDownloadStatSeries(versionId int64, platform string, start, end time.Time) ([]model.DownloadStat, error) {
+	if end.Before(start) {
+		return nil, ecode.ParamWrong
+	}
+
+	return s.dao.GetDownloadStatSeries(versionId, platform, start, end)
+}
@@ -0,0 +1,511 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"github.com/yitter/idgenerator-go/idgen"
+	"go.uber.org/zap"
+	"hash/fnv"
+	"math"
+	"slices"
+	"strconv"
+	"time"
+	"wusthelper-manager-go/app/model"
+	"wusthelper-manager-go/common"
+	"wusthelper-manager-go/library/ecode"
+	"wusthelper-manager-go/library/log"
+)
+
+const _minExperimentVariants = 2
+
+// ExperimentAssignments 一次请求中客户端分到的实验变体，key为变体（轮播图或公告）id，value为实验id
+type ExperimentAssignments map[int64]int64
+
+func (s *Service) GetExperimentList(pagination common.Pagination, targetType string) (*[]model.Experiment, int64, error) {
+	return s.dao.GetExperimentList(pagination, targetType)
+}
+
+func (s *Service) GetExperiment(id int64) (*model.Experiment, error) {
+	experiment, err := s.dao.GetExperiment(id)
+	if err != nil {
+		return nil, err
+	} else if experiment == nil {
+		return nil, ecode.InvalidId
+	}
+
+	return experiment, nil
+}
+
+type ExperimentAddParam struct {
+	Name       string
+	TargetType string
+	Variants   []model.ExperimentVariant // 第一个为对照组
+}
+
+// AddExperiment 添加实验，添加后为未开始的状态
+func (s *Service) AddExperiment(param *ExperimentAddParam) error {
+	err := s.checkExperimentVariants(param.TargetType, param.Variants)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	status := model.NormalStatus
+	return s.dao.AddExperiment(&model.Experiment{
+		ID:         idgen.NextId(),
+		Name:       &param.Name,
+		TargetType: &param.TargetType,
+		Variants:   param.Variants,
+		CreateTime: &now,
+		UpdateTime: &now,
+		Status:     &status,
+	})
+}
+
+type ExperimentModifyParam struct {
+	Id       int64
+	Name     *string
+	Variants []model.ExperimentVariant // 为空时不修改
+}
+
+// ModifyExperiment 只能修改未开始的实验，进行中修改变体或权重会让之前的分组和统计失去意义
+func (s *Service) ModifyExperiment(param *ExperimentModifyParam) error {
+	experiment, err := s.GetExperiment(param.Id)
+	if err != nil {
+		return err
+	} else if *experiment.Status != model.NormalStatus {
+		return ecode.ExperimentRunning
+	}
+
+	if len(param.Variants) > 0 {
+		err = s.checkExperimentVariants(*experiment.TargetType, param.Variants)
+		if err != nil {
+			return err
+		}
+	}
+
+	now := time.Now()
+	count, err := s.dao.UpdateExperiment(&model.Experiment{
+		ID:         param.Id,
+		Name:       param.Name,
+		Variants:   param.Variants,
+		UpdateTime: &now,
+	})
+	if err != nil {
+		return err
+	} else if count == 0 {
+		return ecode.ExperimentRunning
+	}
+
+	return nil
+}
+
+// StartExperiment 开始实验，同一个轮播图或公告同时只能在一个进行中的实验里
+func (s *Service) StartExperiment(id int64) error {
+	experiment, err := s.GetExperiment(id)
+	if err != nil {
+		return err
+	} else if *experiment.Status != model.NormalStatus {
+		return ecode.ExperimentStatusWrong
+	}
+
+	// 添加后变体可能被删除了，再校验一遍
+	err = s.checkExperimentVariants(*experiment.TargetType, experiment.Variants)
+	if err != nil {
+		return err
+	}
+
+	runningList, err := s.dao.GetRunningExperimentList(*experiment.TargetType)
+	if err != nil {
+		return err
+	}
+
+	targetIds := map[int64]bool{}
+	for _, variant := range experiment.Variants {
+		targetIds[variant.TargetId] = true
+	}
+	for _, running := range *runningList {
+		for _, variant := range running.Variants {
+			if targetIds[variant.TargetId] {
+				return ecode.ExperimentConflict
+			}
+		}
+	}
+
+	count, err := s.dao.UpdateExperimentStatus(id, model.NormalStatus, model.ExperimentRunningStatus)
+	if err != nil {
+		return err
+	} else if count == 0 {
+		return ecode.ExperimentStatusWrong
+	}
+
+	log.Info("实验已开始", zap.Int64("id", id))
+	return nil
+}
+
+// StopExperiment 结束实验，结束后所有变体都正常展示，统计数据保留
+func (s *Service) StopExperiment(id int64) error {
+	count, err := s.dao.UpdateExperimentStatus(id, model.ExperimentRunningStatus, model.ExperimentStoppedStatus)
+	if err != nil {
+		return err
+	} else if count == 0 {
+		return ecode.ExperimentStatusWrong
+	}
+
+	ctx := context.Background()
+	if err = s.flushExperimentStatsOf(&ctx, id, time.Now()); err != nil {
+		log.Warn("写入实验统计时出现错误", zap.Int64("id", id), zap.Error(err))
+	}
+
+	log.Info("实验已结束", zap.Int64("id", id))
+	return nil
+}
+
+// DeleteExperiment 删除实验，并清理redis中的计数
+func (s *Service) DeleteExperiment(id int64) error {
+	err := s.dao.DeleteExperiment(id)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	return s.dao.RemoveExperimentCount(&ctx, id)
+}
+
+// checkExperimentVariants 变体至少两个，权重大于0，不能重复，且都是同一平台未删除的轮播图或公告
+func (s *Service) checkExperimentVariants(targetType string, variants []model.ExperimentVariant) error {
+	if len(variants) < _minExperimentVariants {
+		return ecode.ExperimentInvalid
+	}
+
+	platform := ""
+	targetIds := map[int64]bool{}
+	for i, variant := range variants {
+		if variant.Weight <= 0 || targetIds[variant.TargetId] {
+			return ecode.ExperimentInvalid
+		}
+		targetIds[variant.TargetId] = true
+
+		targetPlatform, err := s.getExperimentTargetPlatform(targetType, variant.TargetId)
+		if err != nil {
+			return err
+		} else if i > 0 && targetPlatform != platform {
+			return ecode.ExperimentInvalid
+		}
+		platform = targetPlatform
+	}
+
+	return nil
+}
+
+// getExperimentTargetPlatform 获取变体的平台，变体不存在时返回ecode.ExperimentInvalid
+func (s *Service) getExperimentTargetPlatform(targetType string, targetId int64) (string, error) {
+	var platform *string
+	switch targetType {
+	case model.ExperimentTargetBanner:
+		banner, err := s.dao.GetBanner(targetId)
+		if err != nil {
+			return "", err
+		} else if banner != nil {
+			platform = banner.Platform
+		}
+	case model.ExperimentTargetAnnouncement:
+		announcement, err := s.dao.GetAnnouncement(targetId)
+		if err != nil {
+			return "", err
+		} else if announcement != nil {
+			platform = announcement.Platform
+		}
+	}
+
+	if platform == nil {
+		return "", ecode.ExperimentInvalid
+	}
+
+	return *platform, nil
+}
+
+// assignExperimentVariants 按进行中的实验给客户端分组，返回客户端看不到的变体，以及分到的变体。
+// 只有在ids中的变体才参与，分到的变体不在ids中时（如被投放规则过滤掉）该实验对客户端不生效
+func (s *Service) assignExperimentVariants(targetType string, ids []int64, client AudienceClient) (map[int64]bool, ExperimentAssignments, error) {
+	hidden, assignments := map[int64]bool{}, ExperimentAssignments{}
+	runningList, err := s.dao.GetRunningExperimentList(targetType)
+	if err != nil {
+		return nil, nil, err
+	} else if len(*runningList) == 0 {
+		return hidden, assignments, nil
+	}
+
+	present := make(map[int64]bool, len(ids))
+	for _, id := range ids {
+		present[id] = true
+	}
+
+	clientId := experimentClientId(client)
+	for _, experiment := range *runningList {
+		assigned := pickExperimentVariant(experiment.ID, clientId, experiment.Variants)
+		if !present[assigned.TargetId] {
+			continue
+		}
+
+		// 无法识别的客户端不参与实验，只展示第一个变体，也不计入曝光和点击
+		if clientId != "" {
+			assignments[assigned.TargetId] = experiment.ID
+		}
+		for _, variant := range experiment.Variants {
+			if variant.TargetId != assigned.TargetId {
+				hidden[variant.TargetId] = true
+			}
+		}
+	}
+
+	return hidden, assignments, nil
+}
+
+// experimentClientId 分组使用的客户端标识，优先使用设备id，其次助手token，都没有时返回空
+func experimentClientId(client AudienceClient) string {
+	if client.Client.DeviceId != "" {
+		return client.Client.DeviceId
+	} else if client.Token != "" {
+		return "token:" + client.Token
+	}
+
+	return ""
+}
+
+// pickExperimentVariant 按实验id和客户端标识哈希后按权重选择变体，同一客户端在同一实验中的结果固定。
+// 客户端标识为空时总是选择第一个变体
+func pickExperimentVariant(experimentId int64, clientId string, variants []model.ExperimentVariant) model.ExperimentVariant {
+	totalWeight := 0
+	for _, variant := range variants {
+		totalWeight += variant.Weight
+	}
+
+	if clientId == "" {
+		return variants[0]
+	}
+
+	hash := fnv.New32a()
+	_, _ = hash.Write([]byte(fmt.Sprintf("experiment:%d:%s", experimentId, clientId)))
+	point := int(hash.Sum32() % uint32(totalWeight))
+
+	for _, variant := range variants {
+		if point < variant.Weight {
+			return variant
+		}
+		point -= variant.Weight
+	}
+
+	return variants[len(variants)-1]
+}
+
+// recordExperimentExposure 记录客户端获取到的变体，计数失败不影响获取
+func (s *Service) recordExperimentExposure(assignments ExperimentAssignments) {
+	ctx := context.Background()
+	for targetId, experimentId := range assignments {
+		err := s.dao.IncreaseExperimentCount(&ctx, model.ExperimentEventExposure, experimentId, targetId)
+		if err != nil {
+			log.Warn("记录实验曝光次数失败", zap.Int64("experiment_id", experimentId), zap.Error(err))
+		}
+	}
+}
+
+// RecordExperimentClick 客户端上报变体的一次点击，实验不在进行中或变体不在实验中时不记录。
+// url类型的轮播图通过短链接跳转时已经记录过点击，这里忽略，避免重复计数
+func (s *Service) RecordExperimentClick(experimentId, targetId int64) error {
+	experiment, err := s.GetExperiment(experimentId)
+	if err != nil {
+		return err
+	} else if *experiment.Status != model.ExperimentRunningStatus {
+		return ecode.ExperimentStatusWrong
+	}
+
+	if !slices.ContainsFunc(experiment.Variants, func(variant model.ExperimentVariant) bool {
+		return variant.TargetId == targetId
+	}) {
+		return ecode.InvalidId
+	}
+
+	if *experiment.TargetType == model.ExperimentTargetBanner {
+		banner, err := s.dao.GetBanner(targetId)
+		if err != nil {
+			return err
+		} else if banner != nil && s.BannerLinkType(banner) == model.BannerLinkTypeUrl {
+			return nil
+		}
+	}
+
+	ctx := context.Background()
+	return s.dao.IncreaseExperimentCount(&ctx, model.ExperimentEventClick, experimentId, targetId)
+}
+
+// recordBannerExperimentClick 轮播图通过短链接被点击时，记录到其所在的进行中的实验
+func (s *Service) recordBannerExperimentClick(bannerId int64) error {
+	runningList, err := s.dao.GetRunningExperimentList(model.ExperimentTargetBanner)
+	if err != nil {
+		return err
+	}
+
+	for _, experiment := range *runningList {
+		for _, variant := range experiment.Variants {
+			if variant.TargetId == bannerId {
+				ctx := context.Background()
+				return s.dao.IncreaseExperimentCount(&ctx, model.ExperimentEventClick, experiment.ID, bannerId)
+			}
+		}
+	}
+
+	return nil
+}
+
+// flushExperimentStats 定期把redis中的实验计数写入数据库，实验的计数在实验删除时才从redis中清掉
+func (s *Service) flushExperimentStats() {
+	s.runCounterFlusher(counterFlusher{
+		name: "实验统计",
+		buckets: func(ctx *context.Context) ([]string, error) {
+			ids, err := s.dao.GetExperimentCountIds(ctx)
+			if err != nil {
+				return nil, err
+			}
+
+			buckets := make([]string, len(ids))
+			for i, id := range ids {
+				buckets[i] = strconv.FormatInt(id, 10)
+			}
+			return buckets, nil
+		},
+		flush: func(ctx *context.Context, bucket string, now time.Time) error {
+			id, err := strconv.ParseInt(bucket, 10, 64)
+			if err != nil {
+				return err
+			}
+			return s.flushExperimentStatsOf(ctx, id, now)
+		},
+	})
+}
+
+func (s *Service) flushExperimentStatsOf(ctx *context.Context, id int64, now time.Time) error {
+	counts, err := s.dao.GetExperimentCounts(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	for i := range counts {
+		stat := &counts[i]
+		stat.ID, stat.UpdateTime = idgen.NextId(), &now
+		err = s.dao.SaveExperimentStat(stat)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ExperimentVariantReport 一个变体的统计，显著性为和对照组（第一个变体）的双比例z检验
+type ExperimentVariantReport struct {
+	model.ExperimentVariant
+	Title      string
+	Control    bool
+	Exposures  int64
+	Clicks     int64
+	Conversion float64 // 点击率，没有曝光时为0
+	Uplift     float64 // 相对对照组点击率的提升，对照组点击率为0时为0
+	ZScore     float64
+	PValue     float64 // 双侧检验的p值，越小越显著，对照组为1
+}
+
+type ExperimentReport struct {
+	Experiment *model.Experiment
+	Variants   []ExperimentVariantReport
+}
+
+// GetExperimentReport 获取实验各变体的曝光、点击和显著性，数据定期从redis写入，最近几分钟的数据可能还没有统计进来
+func (s *Service) GetExperimentReport(id int64) (*ExperimentReport, error) {
+	experiment, err := s.GetExperiment(id)
+	if err != nil {
+		return nil, err
+	}
+
+	statList, err := s.dao.GetExperimentStatList(id)
+	if err != nil {
+		return nil, err
+	}
+
+	stats := make(map[int64]*model.ExperimentStat, len(*statList))
+	for i := range *statList {
+		stats[*(*statList)[i].TargetId] = &(*statList)[i]
+	}
+
+	report := &ExperimentReport{Experiment: experiment, Variants: make([]ExperimentVariantReport, len(experiment.Variants))}
+	for i, variant := range experiment.Variants {
+		item := ExperimentVariantReport{ExperimentVariant: variant, Control: i == 0, PValue: 1}
+		if stat, ok := stats[variant.TargetId]; ok {
+			item.Exposures, item.Clicks = *stat.Exposures, *stat.Clicks
+		}
+		if item.Exposures > 0 {
+			item.Conversion = float64(item.Clicks) / float64(item.Exposures)
+		}
+
+		item.Title, err = s.getExperimentTargetTitle(*experiment.TargetType, variant.TargetId)
+		if err != nil {
+			return nil, err
+		}
+
+		if i > 0 {
+			control := &report.Variants[0]
+			if control.Conversion > 0 {
+				item.Uplift = (item.Conversion - control.Conversion) / control.Conversion
+			}
+			item.ZScore, item.PValue = twoProportionZTest(control.Clicks, control.Exposures, item.Clicks, item.Exposures)
+		}
+
+		report.Variants[i] = item
+	}
+
+	return report, nil
+}
+
+// getExperimentTargetTitle 变体的标题，变体已被删除时为空
+func (s *Service) getExperimentTargetTitle(targetType string, targetId int64) (string, error) {
+	var title *string
+	switch targetType {
+	case model.ExperimentTargetBanner:
+		banner, err := s.dao.GetBanner(targetId)
+		if err != nil {
+			return "", err
+		} else if banner != nil {
+			title = banner.Title
+		}
+	case model.ExperimentTargetAnnouncement:
+		announcement, err := s.dao.GetAnnouncement(targetId)
+		if err != nil {
+			return "", err
+		} else if announcement != nil {
+			title = announcement.Title
+		}
+	}
+
+	if title == nil {
+		return "", nil
+	}
+
+	return *title, nil
+}
+
+// twoProportionZTest 双比例z检验，返回z值和双侧p值，样本为空或没有差异可比时返回0和1
+func twoProportionZTest(clicksA, exposuresA, clicksB, exposuresB int64) (float64, float64) {
+	if exposuresA <= 0 || exposuresB <= 0 {
+		return 0, 1
+	}
+
+	pA, pB := float64(clicksA)/float64(exposuresA), float64(clicksB)/float64(exposuresB)
+	pooled := float64(clicksA+clicksB) / float64(exposuresA+exposuresB)
+	se := math.Sqrt(pooled * (1 - pooled) * (1/float64(exposuresA) + 1/float64(exposuresB)))
+	if se == 0 {
+		return 0, 1
+	}
+
+	z := (pB - pA) / se
+	return z, math.Erfc(math.Abs(z) / math.Sqrt2)
+}
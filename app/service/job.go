@@ -0,0 +1,325 @@
+package service
+
+import (
+	"fmt"
+	jsoniter "github.com/json-iterator/go"
+	"github.com/yitter/idgenerator-go/idgen"
+	"go.uber.org/zap"
+	"os"
+	"time"
+	"wusthelper-manager-go/app/model"
+	"wusthelper-manager-go/common"
+	"wusthelper-manager-go/library/ecode"
+	"wusthelper-manager-go/library/log"
+)
+
+const (
+	JobTypeVersionFileUpload = "version_file_upload" // 上传版本文件
+	JobTypeBannerImgUpload   = "banner_img_upload"   // 上传轮播图图片
+	JobTypeReleaseFileLink   = "release_file_link"   // 发布版本后更新最新版本文件的固定链接
+	JobTypeVersionPatchGen   = "version_patch_gen"   // 版本文件上传后生成增量包
+	JobTypeArtifactUpload    = "artifact_upload"     // 上传版本的额外下载文件
+	JobTypeBundleUpload      = "bundle_upload"       // 上传热更新资源包中的文件
+)
+
+const (
+	_defaultJobWorkers           = 2
+	_defaultJobMaxAttempts       = 8
+	_defaultJobRetryBaseInterval = time.Second * 10
+	_defaultJobPollInterval      = time.Second * 2
+	_maxJobRetryInterval         = time.Hour
+	_maxJobErrorLength           = 1000
+)
+
+// jobHandler 后台任务处理函数，任务可能因为重试或服务重启被执行多次，run需要保证幂等
+type jobHandler struct {
+	run func(payload []byte) error
+	// onFail 重试次数用尽后调用，用于回滚依赖该任务结果的业务数据，可以为空
+	onFail func(payload []byte)
+}
+
+func (s *Service) registerJobHandlers() {
+	s.jobHandlers = map[string]jobHandler{
+		JobTypeVersionFileUpload: {run: s.runVersionFileUploadJob, onFail: s.onVersionFileUploadJobFail},
+		JobTypeBannerImgUpload:   {run: s.runBannerImgUploadJob, onFail: s.onBannerImgUploadJobFail},
+		JobTypeReleaseFileLink:   {run: s.runReleaseFileLinkJob},
+		JobTypeVersionPatchGen:   {run: s.runVersionPatchGenerateJob},
+		JobTypeArtifactUpload:    {run: s.runVersionArtifactUploadJob, onFail: s.onVersionArtifactUploadJobFail},
+		JobTypeBundleUpload:      {run: s.runBundleUploadJob, onFail: s.onBundleUploadJobFail},
+	}
+}
+
+// uploadLocalFile 上传本地文件到存储，成功后删除本地文件。
+// 本地文件已不存在时（上次上传成功但任务状态没来得及更新），以存储中是否已有该对象判断是否成功
+func (s *Service) uploadLocalFile(key, localFile, contentType string) error {
+	if _, err := os.Stat(localFile); os.IsNotExist(err) {
+		exists, err := s.storage.Exists(key)
+		if err != nil {
+			return err
+		} else if !exists {
+			return fmt.Errorf("本地文件不存在：%s", localFile)
+		}
+		return nil
+	}
+
+	err := s.storage.PutFile(key, localFile, contentType)
+	if err != nil {
+		log.Warn("文件上传oss时出现错误",
+			zap.String("oss_key", key),
+			zap.String("local_source", localFile),
+			zap.Error(err),
+		)
+		return err
+	}
+
+	err = os.Remove(localFile)
+	if err != nil {
+		log.Warn("移除本地文件时出现异常", zap.String("file", localFile), zap.Error(err))
+	}
+	log.Info("文件上传oss成功", zap.String("oss_key", key), zap.String("local_source", localFile))
+
+	return nil
+}
+
+// saveJobUploadFile 任务和业务数据写入数据库前，把上传的文件保存到任务要上传的本地位置，localFile为空时不需保存。
+// 保存失败时同时删除其他任务已经生成的本地文件（如manifest.plist）
+func (s *Service) saveJobUploadFile(file *File, localFile string, jobs []model.Job) error {
+	if file == nil || localFile == "" {
+		return nil
+	}
+
+	err := file.SaveTo(localFile)
+	if err != nil {
+		log.Error("写文件到本地临时目录时出现错误", zap.String("file", localFile), zap.Error(err))
+		s.removeJobLocalFiles(jobs)
+		return ecode.InternalError
+	}
+
+	return nil
+}
+
+// removeJobLocalFiles 任务和业务数据写入数据库失败时，删除已经为任务保存到本地的待上传文件
+func (s *Service) removeJobLocalFiles(jobs []model.Job) {
+	for _, job := range jobs {
+		payload := new(struct {
+			LocalFile string `json:"local_file"`
+		})
+		if err := jsoniter.UnmarshalFromString(*job.Payload, payload); err != nil || payload.LocalFile == "" {
+			continue
+		}
+
+		s.removeLocalFile(payload.LocalFile)
+	}
+}
+
+// removeLocalFile 业务数据或任务写入数据库失败时，删除已经为任务保存到本地的待上传文件，localFile为空时不处理
+func (s *Service) removeLocalFile(localFile string) {
+	if localFile == "" {
+		return
+	}
+
+	if err := os.Remove(localFile); err != nil && !os.IsNotExist(err) {
+		log.Warn("删除本地临时文件出现错误", zap.String("file", localFile), zap.Error(err))
+	}
+}
+
+// addJob 持久化一个后台任务，由worker异步执行
+func (s *Service) addJob(jobType string, payload any) error {
+	job, err := s.newJob(jobType, payload)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.dao.AddJob(job)
+	if err != nil {
+		return err
+	}
+
+	log.Info("后台任务已添加", zap.Int64("id", job.ID), zap.String("type", jobType))
+	return nil
+}
+
+// newJob 生成一个待执行的后台任务记录，需要和业务数据在同一个事务中写入时使用，否则使用addJob
+func (s *Service) newJob(jobType string, payload any) (*model.Job, error) {
+	data, err := jsoniter.MarshalToString(payload)
+	if err != nil {
+		log.Error("序列化后台任务参数时出现错误", zap.String("type", jobType), zap.Error(err))
+		return nil, ecode.InternalError
+	}
+
+	now := time.Now()
+	attempts, maxAttempts, lastError, status := 0, s.jobMaxAttempts(), "", model.JobPendingStatus
+	job := model.Job{
+		ID:          idgen.NextId(),
+		Type:        &jobType,
+		Payload:     &data,
+		Attempts:    &attempts,
+		MaxAttempts: &maxAttempts,
+		LastError:   &lastError,
+		NextRunTime: &now,
+		CreateTime:  &now,
+		UpdateTime:  &now,
+		Status:      &status,
+	}
+
+	return &job, nil
+}
+
+func (s *Service) jobMaxAttempts() int {
+	if s.config.Server.JobOption.MaxAttempts > 0 {
+		return s.config.Server.JobOption.MaxAttempts
+	}
+
+	return _defaultJobMaxAttempts
+}
+
+// jobRetryInterval 第attempts次执行失败后到下次重试的间隔，指数退避
+func (s *Service) jobRetryInterval(attempts int) time.Duration {
+	base := s.config.Server.JobOption.RetryBaseInterval * time.Second
+	if base <= 0 {
+		base = _defaultJobRetryBaseInterval
+	}
+
+	interval := base
+	for i := 1; i < attempts && interval < _maxJobRetryInterval; i++ {
+		interval *= 2
+	}
+
+	if interval > _maxJobRetryInterval {
+		interval = _maxJobRetryInterval
+	}
+
+	return interval
+}
+
+// startJobWorkers 启动后台任务worker，会先把上次服务退出时中断的任务恢复为待执行
+func (s *Service) startJobWorkers() {
+	count, err := s.dao.ResetRunningJobs()
+	if err != nil {
+		log.Warn("恢复中断的后台任务时出现错误", zap.Error(err))
+	} else if count > 0 {
+		log.Info("已恢复中断的后台任务", zap.Int64("count", count))
+	}
+
+	workers := s.config.Server.JobOption.Workers
+	if workers <= 0 {
+		workers = _defaultJobWorkers
+	}
+
+	pollInterval := s.config.Server.JobOption.PollInterval * time.Second
+	if pollInterval <= 0 {
+		pollInterval = _defaultJobPollInterval
+	}
+
+	for i := 0; i < workers; i++ {
+		go s.jobWorker(pollInterval)
+	}
+}
+
+func (s *Service) jobWorker(pollInterval time.Duration) {
+	for {
+		job, err := s.dao.ClaimDueJob(time.Now())
+		if err != nil || job == nil {
+			time.Sleep(pollInterval)
+			continue
+		}
+
+		s.runJob(job)
+	}
+}
+
+func (s *Service) runJob(job *model.Job) {
+	log.Info("后台任务开始执行", zap.Int64("id", job.ID), zap.String("type", *job.Type))
+
+	var err error
+	handler, ok := s.jobHandlers[*job.Type]
+	if !ok {
+		err = fmt.Errorf("未知的任务类型：%s", *job.Type)
+	} else {
+		err = runJobHandler(handler, []byte(*job.Payload))
+	}
+
+	now := time.Now()
+	attempts := *job.Attempts + 1
+	status, lastError, nextRunTime := model.JobSucceededStatus, "", now
+	if err != nil {
+		lastError = err.Error()
+		if runes := []rune(lastError); len(runes) > _maxJobErrorLength {
+			lastError = string(runes[:_maxJobErrorLength])
+		}
+
+		if !ok || attempts >= *job.MaxAttempts {
+			status = model.JobFailedStatus
+		} else {
+			status = model.JobPendingStatus
+			nextRunTime = now.Add(s.jobRetryInterval(attempts))
+		}
+	}
+
+	result := model.Job{
+		ID:          job.ID,
+		Attempts:    &attempts,
+		LastError:   &lastError,
+		NextRunTime: &nextRunTime,
+		UpdateTime:  &now,
+		Status:      &status,
+	}
+	if _, updateErr := s.dao.UpdateJobResult(&result); updateErr != nil {
+		log.Error("更新后台任务结果时出现错误", zap.Int64("id", job.ID), zap.Error(updateErr))
+	}
+
+	switch status {
+	case model.JobSucceededStatus:
+		log.Info("后台任务执行成功", zap.Int64("id", job.ID), zap.String("type", *job.Type))
+	case model.JobPendingStatus:
+		log.Warn("后台任务执行失败，等待重试",
+			zap.Int64("id", job.ID),
+			zap.String("type", *job.Type),
+			zap.Int("attempts", attempts),
+			zap.Time("next_run_time", nextRunTime),
+			zap.Error(err),
+		)
+	case model.JobFailedStatus:
+		log.Error("后台任务执行失败，不再重试",
+			zap.Int64("id", job.ID),
+			zap.String("type", *job.Type),
+			zap.Int("attempts", attempts),
+			zap.Error(err),
+		)
+		if ok && handler.onFail != nil {
+			handler.onFail([]byte(*job.Payload))
+		}
+	}
+}
+
+// runJobHandler 执行任务处理函数，处理函数panic时当作执行失败，避免worker退出
+func runJobHandler(handler jobHandler, payload []byte) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("任务执行panic：%v", r)
+		}
+	}()
+
+	return handler.run(payload)
+}
+
+func (s *Service) GetJobList(pagination common.Pagination, status *int8, jobType string) (*[]model.Job, int64, error) {
+	jobList, total, err := s.dao.GetJobList(pagination, status, jobType)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return jobList, total, nil
+}
+
+// RetryJob 手动重试失败的任务，或让待执行的任务立即执行
+func (s *Service) RetryJob(id int64) error {
+	count, err := s.dao.RetryJob(id)
+	if err != nil {
+		return err
+	} else if count == 0 {
+		return ecode.InvalidId
+	}
+
+	return nil
+}
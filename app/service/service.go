@@ -1,14 +1,16 @@
 package service
 
 import (
+	"errors"
 	"fmt"
-	"github.com/aliyun/aliyun-oss-go-sdk/oss"
 	"os"
 	"time"
 	"wusthelper-manager-go/app/conf"
 	"wusthelper-manager-go/app/dao"
 	"wusthelper-manager-go/app/rpc/http/wusthelper/v3"
 	"wusthelper-manager-go/library/log"
+	"wusthelper-manager-go/library/storage"
+	"wusthelper-manager-go/library/webp"
 )
 
 const (
@@ -16,10 +18,12 @@ const (
 )
 
 type Service struct {
-	config    *conf.Config
-	dao       *dao.Dao
-	ossBucket *oss.Bucket
-	rpc       *v3.WusthelperHttpRpc
+	config  *conf.Config
+	dao     *dao.Dao
+	storage storage.Storage
+	rpc     *v3.WusthelperHttpRpc
+
+	jobHandlers map[string]jobHandler
 }
 
 func New(c *conf.Config) (*Service, error) {
@@ -29,23 +33,35 @@ func New(c *conf.Config) (*Service, error) {
 		rpc:    v3.NewRpcClient(&c.Wusthelper),
 	}
 
+	if !webp.Supported && !c.Server.BannerOption.DisableWebp {
+		return nil, errors.New("当前构建没有cgo，不支持webp编码，请使用CGO_ENABLED=1构建，或设置BannerOption.DisableWebp为true")
+	}
+
 	uploadFileLocalTmpPath := c.Server.FileStorageOption.UploadFileLocalTmpPath
 	if err := os.MkdirAll(uploadFileLocalTmpPath, 0660); err != nil {
 		return nil, fmt.Errorf("初始化临时文件目录失败：%s", err.Error())
 	}
 
-	aliyunOssOption := c.Server.FileStorageOption.AliyunOssOption
-	client, err := oss.New(aliyunOssOption.Endpoint, aliyunOssOption.AccessKeyId, aliyunOssOption.AccessKeySecret)
+	var err error
+	service.storage, err = storage.New(&c.Server.FileStorageOption.Config)
 	if err != nil {
-		log.Warn("阿里云oss客户端初始化失败")
-		return nil, fmt.Errorf("阿里云oss客户端初始化失败：%s", err.Error())
+		log.Warn("文件存储初始化失败")
+		return nil, err
 	}
 
-	service.ossBucket, err = client.Bucket(aliyunOssOption.Bucket)
-	if err != nil {
-		log.Warn("阿里云oss bucket初始化失败")
-		return nil, fmt.Errorf("阿里云oss bucket初始化失败，bucket: %s，err: %s", aliyunOssOption.Bucket, err.Error())
-	}
+	go service.cleanExpiredUploads()
+	go service.flushDownloadStats()
+	go service.flushBannerStats()
+	go service.flushExperimentStats()
+	go service.runStorageGcPeriodically()
+
+	service.registerJobHandlers()
+	service.startJobWorkers()
 
 	return service, nil
 }
+
+// GetFileUrl 获取存储中对象的公开访问地址
+func (s *Service) GetFileUrl(key string) string {
+	return s.storage.Url(key)
+}
@@ -0,0 +1,276 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"github.com/yitter/idgenerator-go/idgen"
+	"go.uber.org/zap"
+	"path"
+	"sort"
+	"strings"
+	"time"
+	"wusthelper-manager-go/app/model"
+	"wusthelper-manager-go/library/ecode"
+	"wusthelper-manager-go/library/log"
+	"wusthelper-manager-go/library/storage"
+)
+
+const (
+	_defaultStorageGcGracePeriod = time.Hour * 24 * 7
+)
+
+// StorageGcObject 一个没有被引用的对象，Expired表示已超过宽限期，清理时会被删除或移动
+type StorageGcObject struct {
+	Orphan    *model.StorageOrphan
+	Expired   bool
+	Collected bool
+}
+
+type StorageGcResult struct {
+	DryRun    bool
+	Scanned   int // 扫描的对象数量
+	Orphans   []StorageGcObject
+	Collected int // 实际删除或移动的对象数量
+	Failed    int
+}
+
+// RunStorageGc 扫描版本文件和图片目录，找出没有被数据库引用的对象并记录第一次发现的时间，
+// 持续无引用超过宽限期的对象删除或移动到归档前缀。dryRun为true时只记录和报告，不清理
+func (s *Service) RunStorageGc(dryRun bool) (*StorageGcResult, error) {
+	ctx := context.Background()
+	ok, err := s.dao.LockStorageGc(&ctx)
+	if err != nil {
+		return nil, err
+	} else if !ok {
+		return nil, ecode.StorageGcRunning
+	}
+	defer func() {
+		_ = s.dao.UnlockStorageGc(&ctx)
+	}()
+
+	// 先获取引用再列举对象，列举期间新上传的对象最多被当作刚发现的无引用对象，不会超过宽限期
+	referenced, err := s.getReferencedObjectKeys()
+	if err != nil {
+		return nil, err
+	}
+
+	result := &StorageGcResult{DryRun: dryRun}
+	objects, err := s.listOrphanObjects(referenced, &result.Scanned)
+	if err != nil {
+		return nil, err
+	}
+
+	orphans, err := s.syncStorageOrphans(objects)
+	if err != nil {
+		return nil, err
+	}
+
+	now, gracePeriod := time.Now(), s.storageGcGracePeriod()
+	result.Orphans = make([]StorageGcObject, len(orphans))
+	for i := range orphans {
+		orphan := &orphans[i]
+		result.Orphans[i] = StorageGcObject{
+			Orphan: orphan,
+			Expired: now.Sub(*orphan.FirstSeenTime) >= gracePeriod &&
+				now.Sub(*orphan.LastModified) >= gracePeriod,
+		}
+	}
+	sort.Slice(result.Orphans, func(i, j int) bool {
+		return *result.Orphans[i].Orphan.ObjectKey < *result.Orphans[j].Orphan.ObjectKey
+	})
+
+	if !dryRun {
+		collectedIds := make([]int64, 0)
+		for i := range result.Orphans {
+			object := &result.Orphans[i]
+			if !object.Expired {
+				continue
+			}
+
+			if err = s.collectStorageObject(*object.Orphan.ObjectKey); err != nil {
+				log.Warn("清理无引用对象时出现错误", zap.String("oss_key", *object.Orphan.ObjectKey), zap.Error(err))
+				result.Failed++
+				continue
+			}
+
+			object.Collected = true
+			collectedIds = append(collectedIds, object.Orphan.ID)
+		}
+		result.Collected = len(collectedIds)
+
+		_, err = s.dao.UpdateStorageOrphanStatusBatch(model.StorageOrphanCollectedStatus, collectedIds...)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	log.Info("存储清理完成",
+		zap.Bool("dry_run", dryRun),
+		zap.Int("scanned", result.Scanned),
+		zap.Int("orphans", len(result.Orphans)),
+		zap.Int("collected", result.Collected),
+		zap.Int("failed", result.Failed),
+	)
+	return result, nil
+}
+
+// getReferencedObjectKeys 获取数据库中引用的所有对象，包括版本文件、下载文件、增量包、轮播图图片的各个版本和各平台的固定链接
+func (s *Service) getReferencedObjectKeys() (map[string]bool, error) {
+	resourceStorageOption := s.config.Server.FileStorageOption.ResourceStorageOption
+	referenced := map[string]bool{}
+
+	for _, getFileList := range []func() ([]string, error){
+		s.dao.GetVersionFileList,
+		s.dao.GetVersionArtifactFileList,
+		s.dao.GetVersionPatchFileList,
+	} {
+		fileList, err := getFileList()
+		if err != nil {
+			return nil, err
+		}
+		for _, file := range fileList {
+			referenced[fmt.Sprintf("%s/%s", resourceStorageOption.VersionFileStorageBasePath, file)] = true
+		}
+	}
+
+	bannerList, err := s.dao.GetBannerImgList()
+	if err != nil {
+		return nil, err
+	}
+	for i := range *bannerList {
+		for _, key := range s.bannerImgKeys(&(*bannerList)[i]) {
+			referenced[key] = true
+		}
+	}
+
+	referenced[resourceStorageOption.WusthelperReleaseFileKey] = true
+	for _, key := range resourceStorageOption.ReleaseFileKeys {
+		referenced[key] = true
+	}
+
+	return referenced, nil
+}
+
+// listOrphanObjects 列举版本文件和图片目录下没有被引用的对象，归档前缀下的对象不列出
+func (s *Service) listOrphanObjects(referenced map[string]bool, scanned *int) (map[string]storage.ObjectInfo, error) {
+	resourceStorageOption := s.config.Server.FileStorageOption.ResourceStorageOption
+	coldPrefix := s.config.Server.StorageGcOption.ColdPrefix
+
+	objects := map[string]storage.ObjectInfo{}
+	for _, basePath := range []string{
+		resourceStorageOption.VersionFileStorageBasePath,
+		resourceStorageOption.PicStorageBasePath,
+	} {
+		if basePath == "" {
+			continue
+		}
+
+		err := s.storage.List(strings.TrimSuffix(basePath, "/")+"/", func(object storage.ObjectInfo) error {
+			*scanned++
+			if referenced[object.Key] || (coldPrefix != "" && strings.HasPrefix(object.Key, coldPrefix+"/")) {
+				return nil
+			}
+
+			objects[object.Key] = object
+			return nil
+		})
+		if err != nil {
+			log.Error("列举存储中的对象时出现错误", zap.String("prefix", basePath), zap.Error(err))
+			return nil, ecode.InternalError
+		}
+	}
+
+	return objects, nil
+}
+
+// syncStorageOrphans 将本次扫描到的无引用对象同步到数据库，返回所有还未清理的记录。
+// 新发现的对象添加记录；已不在本次结果中，或修改时间变化（被重新上传）的对象，原记录标记为删除
+func (s *Service) syncStorageOrphans(objects map[string]storage.ObjectInfo) ([]model.StorageOrphan, error) {
+	existingList, err := s.dao.GetStorageOrphanList()
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]model.StorageOrphan, 0, len(objects))
+	kept := map[string]bool{}
+	staleIds := make([]int64, 0)
+	for _, orphan := range *existingList {
+		object, ok := objects[*orphan.ObjectKey]
+		// 数据库中的时间只精确到秒
+		if !ok || kept[*orphan.ObjectKey] || object.LastModified.Unix() != orphan.LastModified.Unix() {
+			staleIds = append(staleIds, orphan.ID)
+			continue
+		}
+
+		kept[*orphan.ObjectKey] = true
+		result = append(result, orphan)
+	}
+
+	_, err = s.dao.UpdateStorageOrphanStatusBatch(model.DeletedStatus, staleIds...)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	added := make([]model.StorageOrphan, 0)
+	for key, object := range objects {
+		if kept[key] {
+			continue
+		}
+
+		objectKey, size, lastModified := key, object.Size, object.LastModified
+		added = append(added, model.StorageOrphan{
+			ID:            idgen.NextId(),
+			ObjectKey:     &objectKey,
+			Size:          &size,
+			LastModified:  &lastModified,
+			FirstSeenTime: &now,
+			UpdateTime:    &now,
+			Status:        new(int8),
+		})
+	}
+
+	_, err = s.dao.AddStorageOrphan(added...)
+	if err != nil {
+		return nil, err
+	}
+
+	return append(result, added...), nil
+}
+
+// collectStorageObject 配置了归档前缀时先复制到归档前缀下再删除，否则直接删除
+func (s *Service) collectStorageObject(key string) error {
+	if coldPrefix := s.config.Server.StorageGcOption.ColdPrefix; coldPrefix != "" {
+		if err := s.storage.Copy(key, path.Join(coldPrefix, key)); err != nil {
+			return err
+		}
+	}
+
+	return s.storage.Delete(key)
+}
+
+func (s *Service) storageGcGracePeriod() time.Duration {
+	if s.config.Server.StorageGcOption.GracePeriod > 0 {
+		return s.config.Server.StorageGcOption.GracePeriod * time.Hour
+	}
+
+	return _defaultStorageGcGracePeriod
+}
+
+// runStorageGcPeriodically 按配置的间隔定期清理存储，间隔为0时不自动清理
+func (s *Service) runStorageGcPeriodically() {
+	option := s.config.Server.StorageGcOption
+	if option.Interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(option.Interval * time.Hour)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		_, err := s.RunStorageGc(option.DryRun)
+		if err != nil && err != ecode.StorageGcRunning {
+			log.Warn("定期清理存储时出现错误", zap.Error(err))
+		}
+	}
+}
@@ -0,0 +1,309 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"github.com/dustin/go-humanize"
+	"github.com/yitter/idgenerator-go/idgen"
+	"go.uber.org/zap"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"time"
+	"wusthelper-manager-go/app/model"
+	"wusthelper-manager-go/library/ecode"
+	"wusthelper-manager-go/library/log"
+)
+
+const (
+	MaxUploadFileSize = 100 * humanize.MByte
+	MaxUploadPartSize = 32 * humanize.MByte
+
+	_defaultUploadPartSize    = 5 * humanize.MByte
+	_minUploadPartSize        = 256 * humanize.KByte
+	_uploadSessionExpiration  = time.Hour * 24
+	_uploadSessionCleanPeriod = time.Hour
+	_uploadSessionDirName     = "chunked"
+	_uploadMergedFileName     = "merged"
+)
+
+func (s *Service) uploadSessionDir(id string) string {
+	return filepath.Join(s.config.Server.FileStorageOption.UploadFileLocalTmpPath, _uploadSessionDirName, id)
+}
+
+// InitUpload 创建分片上传会话，partSize为0时使用默认分片大小
+func (s *Service) InitUpload(fileName string, size, partSize int64) (*model.UploadSession, error) {
+	fileName = filepath.Base(fileName)
+	if fileName == "." || fileName == string(filepath.Separator) || size <= 0 || size > MaxUploadFileSize {
+		return nil, ecode.ParamWrong
+	}
+
+	if partSize == 0 {
+		partSize = _defaultUploadPartSize
+	}
+	if partSize < _minUploadPartSize || partSize > MaxUploadPartSize {
+		return nil, ecode.UploadPartWrong
+	}
+
+	session := &model.UploadSession{
+		Id:         strconv.FormatInt(idgen.NextId(), 10),
+		FileName:   fileName,
+		Size:       size,
+		PartSize:   partSize,
+		PartCount:  int((size + partSize - 1) / partSize),
+		CreateTime: time.Now(),
+	}
+
+	// 先写会话再建目录，避免目录被定时清理当成过期目录删掉
+	ctx := context.Background()
+	err := s.dao.StoreUploadSession(&ctx, session, _uploadSessionExpiration)
+	if err != nil {
+		return nil, err
+	}
+
+	err = os.MkdirAll(s.uploadSessionDir(session.Id), 0755)
+	if err != nil {
+		log.Error("创建分片上传临时目录出现错误", zap.String("id", session.Id), zap.Error(err))
+		return nil, ecode.InternalError
+	}
+
+	return session, nil
+}
+
+func (s *Service) getUploadSession(id string) (*model.UploadSession, error) {
+	if id == "" {
+		return nil, ecode.UploadSessionInvalid
+	}
+
+	ctx := context.Background()
+	session, err := s.dao.GetUploadSession(&ctx, id)
+	if err != nil {
+		return nil, err
+	} else if session == nil {
+		return nil, ecode.UploadSessionInvalid
+	}
+
+	return session, nil
+}
+
+// GetUploadStatus 获取上传会话和已上传的分片号（从1开始，升序），用于断点续传
+func (s *Service) GetUploadStatus(id string) (*model.UploadSession, []int, error) {
+	session, err := s.getUploadSession(id)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	ctx := context.Background()
+	parts, err := s.dao.GetUploadedParts(&ctx, id)
+	if err != nil {
+		return nil, nil, err
+	}
+	sort.Ints(parts)
+
+	return session, parts, nil
+}
+
+// UploadPart 上传一个分片，分片内容直接流式写入本地临时目录，同一分片重复上传会覆盖
+func (s *Service) UploadPart(id string, partNumber int, data io.Reader) error {
+	session, err := s.getUploadSession(id)
+	if err != nil {
+		return err
+	}
+
+	if session.Completed {
+		return ecode.UploadSessionInvalid
+	}
+
+	if partNumber < 1 || partNumber > session.PartCount {
+		return ecode.UploadPartWrong
+	}
+
+	expectedSize := session.PartSize
+	if partNumber == session.PartCount {
+		expectedSize = session.Size - session.PartSize*int64(session.PartCount-1)
+	}
+
+	partFile := filepath.Join(s.uploadSessionDir(id), fmt.Sprintf("%d.part", partNumber))
+	tmpPartFile := fmt.Sprintf("%s.%d", partFile, idgen.NextId())
+	out, err := os.Create(tmpPartFile)
+	if err != nil {
+		log.Error("创建分片临时文件出现错误", zap.String("file", tmpPartFile), zap.Error(err))
+		return ecode.InternalError
+	}
+
+	// 多读一个字节，用来判断分片是否超出预期大小
+	written, err := io.Copy(out, io.LimitReader(data, expectedSize+1))
+	closeErr := out.Close()
+	if err != nil || closeErr != nil || written != expectedSize {
+		_ = os.Remove(tmpPartFile)
+		if err != nil {
+			log.Warn("接收分片数据出现错误", zap.String("id", id), zap.Int("part", partNumber), zap.Error(err))
+			return ecode.UploadPartWrong
+		} else if closeErr != nil {
+			log.Error("写分片临时文件出现错误", zap.String("file", tmpPartFile), zap.Error(closeErr))
+			return ecode.InternalError
+		}
+		return ecode.UploadPartWrong
+	}
+
+	err = os.Rename(tmpPartFile, partFile)
+	if err != nil {
+		log.Error("保存分片文件出现错误", zap.String("file", partFile), zap.Error(err))
+		return ecode.InternalError
+	}
+
+	ctx := context.Background()
+	return s.dao.AddUploadedPart(&ctx, id, partNumber, _uploadSessionExpiration)
+}
+
+// CompleteUpload 合并全部分片，之后上传会话id可以代替文件用在版本和轮播图接口中
+func (s *Service) CompleteUpload(id string) (*model.UploadSession, error) {
+	session, parts, err := s.GetUploadStatus(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if session.Completed {
+		return session, nil
+	}
+
+	if len(parts) != session.PartCount {
+		return nil, ecode.UploadNotCompleted
+	}
+
+	// 合并到固定的文件名，文件名只作为元数据，避免和分片文件重名
+	dir := s.uploadSessionDir(id)
+	mergedFile := filepath.Join(dir, _uploadMergedFileName)
+	out, err := os.Create(mergedFile)
+	if err != nil {
+		log.Error("创建分片合并文件出现错误", zap.String("file", mergedFile), zap.Error(err))
+		return nil, ecode.InternalError
+	}
+
+	err = mergeUploadParts(out, dir, session.PartCount)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(mergedFile)
+		log.Error("合并分片出现错误", zap.String("id", id), zap.Error(err))
+		return nil, ecode.InternalError
+	}
+
+	for i := 1; i <= session.PartCount; i++ {
+		_ = os.Remove(filepath.Join(dir, fmt.Sprintf("%d.part", i)))
+	}
+
+	session.Completed = true
+	session.LocalPath = mergedFile
+	ctx := context.Background()
+	err = s.dao.StoreUploadSession(&ctx, session, _uploadSessionExpiration)
+	if err != nil {
+		return nil, err
+	}
+
+	return session, nil
+}
+
+func mergeUploadParts(out io.Writer, dir string, partCount int) error {
+	for i := 1; i <= partCount; i++ {
+		part, err := os.Open(filepath.Join(dir, fmt.Sprintf("%d.part", i)))
+		if err != nil {
+			return err
+		}
+
+		_, err = io.Copy(out, part)
+		_ = part.Close()
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// AbortUpload 取消上传，删除会话和已上传的分片
+func (s *Service) AbortUpload(id string) error {
+	if _, err := s.getUploadSession(id); err != nil {
+		return err
+	}
+
+	return s.releaseUpload(id)
+}
+
+// GetUploadedFile 获取已完成的分片上传文件，文件在使用方处理成功后通过 releaseUploadFile 释放
+func (s *Service) GetUploadedFile(id string) (*File, error) {
+	session, err := s.getUploadSession(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if !session.Completed {
+		return nil, ecode.UploadNotCompleted
+	}
+
+	return &File{
+		FileName:  session.FileName,
+		LocalPath: session.LocalPath,
+		UploadId:  session.Id,
+	}, nil
+}
+
+// releaseUploadFile 文件来自分片上传时，删除对应的上传会话和临时目录
+func (s *Service) releaseUploadFile(file *File) {
+	if file == nil || file.UploadId == "" {
+		return
+	}
+
+	if err := s.releaseUpload(file.UploadId); err != nil {
+		log.Warn("释放分片上传会话出现错误", zap.String("id", file.UploadId), zap.Error(err))
+	}
+}
+
+func (s *Service) releaseUpload(id string) error {
+	ctx := context.Background()
+	err := s.dao.DeleteUploadSession(&ctx, id)
+	if err != nil {
+		return err
+	}
+
+	err = os.RemoveAll(s.uploadSessionDir(id))
+	if err != nil {
+		log.Warn("删除分片上传临时目录出现错误", zap.String("id", id), zap.Error(err))
+	}
+
+	return nil
+}
+
+// cleanExpiredUploads 定期清理会话已过期的分片上传临时目录
+func (s *Service) cleanExpiredUploads() {
+	ticker := time.NewTicker(_uploadSessionCleanPeriod)
+	defer ticker.Stop()
+
+	baseDir := filepath.Join(s.config.Server.FileStorageOption.UploadFileLocalTmpPath, _uploadSessionDirName)
+	for range ticker.C {
+		entries, err := os.ReadDir(baseDir)
+		if err != nil {
+			continue
+		}
+
+		ctx := context.Background()
+		for _, entry := range entries {
+			if !entry.IsDir() {
+				continue
+			}
+
+			exists, err := s.dao.HasUploadSession(&ctx, entry.Name())
+			if err != nil || exists {
+				continue
+			}
+
+			err = os.RemoveAll(filepath.Join(baseDir, entry.Name()))
+			if err != nil {
+				log.Warn("清理过期分片上传目录出现错误", zap.String("id", entry.Name()), zap.Error(err))
+			}
+		}
+	}
+}
@@ -1,25 +1,121 @@
 package service
 
 import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
-	"github.com/aliyun/aliyun-oss-go-sdk/oss"
+	jsoniter "github.com/json-iterator/go"
 	"github.com/yitter/idgenerator-go/idgen"
 	"go.uber.org/zap"
+	"io"
 	"os"
+	"strings"
 	"time"
 	"wusthelper-manager-go/app/model"
 	"wusthelper-manager-go/common"
+	"wusthelper-manager-go/library/apk"
 	"wusthelper-manager-go/library/ecode"
+	"wusthelper-manager-go/library/ipa"
 	"wusthelper-manager-go/library/log"
+	"wusthelper-manager-go/library/semver"
 )
 
-func (s *Service) GetLatestVersion(platform ...string) (*model.Version, error) {
-	latestVersionList, err := s.dao.GetLatestVersion(platform...)
+const (
+	_platformAndroid = "android"
+	_platformIos     = "ios"
+)
+
+// GetLatestVersion 按语义化版本号获取平台在给定渠道中最新的已发布版本，没有给定渠道时只看稳定渠道，没有时返回nil
+func (s *Service) GetLatestVersion(platform string, channels ...string) (*model.Version, error) {
+	if len(channels) == 0 {
+		channels = []string{model.VersionChannelStable}
+	}
+
+	versionList, err := s.dao.GetPublishedVersionList(platform, channels...)
 	if err != nil {
 		return nil, err
 	}
 
-	return latestVersionList, nil
+	var latest *model.Version
+	var latestSemver *semver.Version
+	for i := range *versionList {
+		version := &(*versionList)[i]
+		versionSemver := parseVersionSemver(version)
+		if latest == nil || compareSemver(versionSemver, latestSemver) > 0 {
+			latest, latestSemver = version, versionSemver
+		}
+	}
+
+	return latest, nil
+}
+
+// parseVersionSemver 获取版本记录的语义化版本号，优先使用已解析的字段，版本号不合法时返回nil
+func parseVersionSemver(version *model.Version) *semver.Version {
+	if version.VersionMajor != nil && version.VersionMinor != nil && version.VersionPatch != nil {
+		pre := ""
+		if version.VersionPre != nil {
+			pre = *version.VersionPre
+		}
+
+		return &semver.Version{
+			Major: *version.VersionMajor,
+			Minor: *version.VersionMinor,
+			Patch: *version.VersionPatch,
+			Pre:   pre,
+		}
+	}
+
+	if version.VersionText == nil {
+		return nil
+	}
+
+	versionSemver, err := semver.Parse(*version.VersionText)
+	if err != nil {
+		return nil
+	}
+
+	return versionSemver
+}
+
+// compareSemver 比较两个版本号，不合法的版本号（nil）视为最低
+func compareSemver(a, b *semver.Version) int {
+	switch {
+	case a == nil && b == nil:
+		return 0
+	case a == nil:
+		return -1
+	case b == nil:
+		return 1
+	default:
+		return a.Compare(b)
+	}
+}
+
+// checkVersionChannel 校验发布渠道是否合法
+func checkVersionChannel(channel string) error {
+	switch channel {
+	case model.VersionChannelStable, model.VersionChannelBeta, model.VersionChannelInternal:
+		return nil
+	default:
+		return ecode.ChannelInvalid
+	}
+}
+
+// fillVersionSemver 校验版本号格式，并将解析得到的语义化版本号填到版本记录中
+func fillVersionSemver(version *model.Version, versionText string) error {
+	versionSemver, err := semver.Parse(versionText)
+	if err != nil {
+		log.Warn("版本号格式不正确", zap.String("version", versionText), zap.Error(err))
+		return ecode.VersionTextInvalid
+	}
+
+	version.VersionMajor = &versionSemver.Major
+	version.VersionMinor = &versionSemver.Minor
+	version.VersionPatch = &versionSemver.Patch
+	version.VersionPre = &versionSemver.Pre
+
+	return nil
 }
 
 func (s *Service) GetVersionList(pagination common.Pagination, platform string) (*[]model.Version, int64, error) {
@@ -35,69 +131,96 @@ type VersionAddParam struct {
 	Version    string
 	Summary    string
 	Platform   string
+	Channel    string // 为空时为稳定渠道
+	Mandatory  bool
 	UploadFile *File
 }
 
 func (s *Service) AddVersion(param *VersionAddParam) error {
-	// 先存到本地，再去传oss
-	storageOption := s.config.Server.FileStorageOption
-	localFileLoc := fmt.Sprintf("%s/%s", storageOption.UploadFileLocalTmpPath, param.UploadFile.FileName)
-
-	fileKey := ""
-	if param.UploadFile != nil {
-		err := os.WriteFile(localFileLoc, *param.UploadFile.Data, 0664)
-		if err != nil {
-			log.Error("写版本文件到本地临时目录时出现错误", zap.String("file", localFileLoc), zap.Error(err))
-			return ecode.InternalError
-		}
-
-		fileKey = fmt.Sprintf("%d/%s", idgen.NextId(), param.UploadFile.FileName)
-
-		// 上传新文件
-		go func() {
-			log.Info("新版本文件处理后台任务开始")
-			resourceStorageOption := storageOption.ResourceStorageOption
-			ossObjectKey := fmt.Sprintf("%s/%s", resourceStorageOption.VersionFileStorageBasePath, fileKey)
-			err = s.ossBucket.PutObjectFromFile(ossObjectKey, localFileLoc)
-			if err != nil {
-				log.Warn("版本文件上传oss时出现错误",
-					zap.String("oss_key", ossObjectKey),
-					zap.String("local_source", localFileLoc),
-					zap.Error(err),
-				)
-			} else {
-				err = os.Remove(localFileLoc)
-				if err != nil {
-					log.Warn("移除本地版本文件时出现异常", zap.String("file", localFileLoc), zap.Error(err))
-				}
-				log.Info("版本文件上传oss成功",
-					zap.String("oss_key", ossObjectKey),
-					zap.String("local_source", localFileLoc),
-				)
-			}
-			log.Info("新版本文件处理后台任务完成")
-		}()
+	if param.Channel == "" {
+		param.Channel = model.VersionChannelStable
+	}
+	if err := checkVersionChannel(param.Channel); err != nil {
+		return err
 	}
 
-	now := time.Now()
 	version := model.Version{
 		ID:          idgen.NextId(),
 		VersionText: &param.Version,
 		Summary:     &param.Summary,
 		Platform:    &param.Platform,
-		File:        &fileKey,
+		Channel:     &param.Channel,
+		Mandatory:   &param.Mandatory,
 		Status:      new(int8),
-		CreateTime:  &now,
-		UpdateTime:  &now,
 	}
 
+	err := fillVersionSemver(&version, param.Version)
+	if err != nil {
+		return err
+	}
+
+	// 先存到本地，再去传oss
+	storageOption := s.config.Server.FileStorageOption
+
+	fileKey, localFile := "", ""
+	var fileInfo *versionFileInfo
+	if param.UploadFile != nil {
+		fileInfo, err = s.inspectVersionFile(param.UploadFile, param.Platform, param.Version)
+		if err != nil {
+			return err
+		}
+
+		// 本地文件要等任务执行时才上传，按文件id区分，避免同名文件互相覆盖
+		fileId := idgen.NextId()
+		localFile = fmt.Sprintf("%s/%d-%s", storageOption.UploadFileLocalTmpPath, fileId, param.UploadFile.FileName)
+		fileKey = fmt.Sprintf("%d/%s", fileId, param.UploadFile.FileName)
+	}
+
+	now := time.Now()
+	version.File = &fileKey
+	version.CreateTime = &now
+	version.UpdateTime = &now
+
 	*version.Status = model.NormalStatus
+	if fileInfo != nil {
+		fileInfo.fill(&version)
+	}
+
+	// 上传新文件的任务、ipa的manifest和版本信息一起写入，写入前出错时不会留下不完整的版本
+	artifacts, jobs := make([]model.VersionArtifact, 0, 1), make([]model.Job, 0, 2)
+	if fileKey != "" {
+		job, err := s.newJob(JobTypeVersionFileUpload, versionFileUploadJob{
+			VersionId: version.ID,
+			FileKey:   fileKey,
+			LocalFile: localFile,
+		})
+		if err != nil {
+			return err
+		}
+		jobs = append(jobs, *job)
+	}
 
-	_, err := s.dao.AddVersion(&version)
+	if fileInfo != nil && fileInfo.Ipa != nil {
+		manifest, manifestJob, err := s.newIosManifest(version.ID, fileKey, fileInfo.Ipa)
+		if err != nil {
+			return err
+		}
+		artifacts, jobs = append(artifacts, *manifest), append(jobs, *manifestJob)
+	}
+
+	err = s.saveJobUploadFile(param.UploadFile, localFile, jobs)
 	if err != nil {
 		return err
 	}
 
+	_, err = s.dao.AddVersion(&version, artifacts, jobs...)
+	if err != nil {
+		s.removeJobLocalFiles(jobs)
+		return err
+	}
+
+	s.releaseUploadFile(param.UploadFile)
+
 	return nil
 }
 
@@ -106,88 +229,138 @@ type VersionModifyParam struct {
 	Version    *string
 	Summary    *string
 	Platform   *string
+	Channel    *string
+	Mandatory  *bool
 	UploadFile *File
 }
 
 func (s *Service) ModifyVersion(param *VersionModifyParam) error {
+	if param.Channel != nil {
+		if err := checkVersionChannel(*param.Channel); err != nil {
+			return err
+		}
+	}
+
 	version := model.Version{
 		ID:          param.Id,
 		VersionText: param.Version,
 		Summary:     param.Summary,
 		File:        nil,
 		Platform:    param.Platform,
+		Channel:     param.Channel,
+		Mandatory:   param.Mandatory,
 		UpdateTime:  new(time.Time),
 	}
 
-	// 新版本文件需要修改
-	if param.UploadFile != nil {
-		existsVersion, err := s.dao.GetVersion(param.Id)
+	existsVersion, err := s.dao.GetVersion(param.Id)
+	if err != nil {
+		return err
+	}
+
+	// 如果没查到数据
+	if existsVersion == nil {
+		return ecode.VersionOperationFailed
+	}
+
+	if param.Version != nil {
+		err := fillVersionSemver(&version, *param.Version)
 		if err != nil {
 			return err
 		}
 
-		// 如果没查到数据
-		if existsVersion == nil {
-			return ecode.VersionOperationFailed
+		// 已有文件的版本号是和安装包校验过的，不换文件时不能单独修改版本号
+		hasFile := existsVersion.File != nil && *existsVersion.File != ""
+		if param.UploadFile == nil && hasFile && *param.Version != *existsVersion.VersionText {
+			return ecode.VersionNameMismatch
 		}
+	}
 
-		// 先保存新文件到本地
-		storageOption := s.config.Server.FileStorageOption
-		localFileLoc := fmt.Sprintf("%s/%s", storageOption.UploadFileLocalTmpPath, param.UploadFile.FileName)
-		err = os.WriteFile(localFileLoc, *param.UploadFile.Data, 0664)
+	// 安装包只按上传时的平台校验过，不换文件时不能修改平台，避免把没校验签名和包名的文件改成android版本发布
+	if param.Platform != nil && param.UploadFile == nil && *param.Platform != *existsVersion.Platform &&
+		existsVersion.File != nil && *existsVersion.File != "" {
+		return ecode.VersionPlatformLocked
+	}
+
+	// 发布过的版本已经推送给对应渠道的客户端，改渠道会让渠道的最新版本和发布历史对不上
+	if param.Channel != nil && (existsVersion.Channel == nil || *param.Channel != *existsVersion.Channel) {
+		if *existsVersion.Status != model.NormalStatus {
+			return ecode.VersionChannelLocked
+		}
+
+		published, err := s.dao.HasVersionPublishHistory(param.Id)
 		if err != nil {
-			log.Error("写版本文件到本地临时目录时出现错误", zap.String("file", localFileLoc), zap.Error(err))
-			return ecode.InternalError
+			return err
+		} else if published {
+			return ecode.VersionChannelLocked
 		}
+	}
 
-		// 如果原来有文件就删除
-		resourceStorageOption := storageOption.ResourceStorageOption
-		ossObjectKey := fmt.Sprintf("%s/%s", resourceStorageOption.VersionFileStorageBasePath, *existsVersion.File)
-		if version.File != nil && *version.File != "" {
-			err = s.ossBucket.SetObjectACL(ossObjectKey, oss.ACLPrivate)
-			if err != nil {
-				log.Warn("删除已存在的oss文件出现错误", zap.String("oss_key", ossObjectKey), zap.Error(err))
-			} else {
-				log.Info("删除已存在的oss文件完成", zap.String("oss_key", ossObjectKey))
-			}
+	// 新版本文件需要修改
+	localFile := ""
+	var fileInfo *versionFileInfo
+	if param.UploadFile != nil {
+		platform, versionText := *existsVersion.Platform, *existsVersion.VersionText
+		if param.Platform != nil {
+			platform = *param.Platform
 		}
+		if param.Version != nil {
+			versionText = *param.Version
+		}
+
+		fileInfo, err = s.inspectVersionFile(param.UploadFile, platform, versionText)
+		if err != nil {
+			return err
+		}
+		fileInfo.fill(&version)
+
+		// 新文件和版本信息一起写入前才保存到本地
+		storageOption := s.config.Server.FileStorageOption
+		fileId := idgen.NextId()
+		localFileLoc := fmt.Sprintf("%s/%d-%s", storageOption.UploadFileLocalTmpPath, fileId, param.UploadFile.FileName)
 
-		fileKey := fmt.Sprintf("%d/%s", idgen.NextId(), param.UploadFile.FileName)
+		// 旧文件不再被引用后由存储清理任务回收，新文件上传成功前旧文件仍然可以下载
+		fileKey := fmt.Sprintf("%d/%s", fileId, param.UploadFile.FileName)
 		version.File = &fileKey
 
-		// 上传新文件
-		go func() {
-			log.Info("新版本文件处理后台任务开始")
-			ossObjectKey = fmt.Sprintf("%s/%s", resourceStorageOption.VersionFileStorageBasePath, fileKey)
-			err = s.ossBucket.PutObjectFromFile(ossObjectKey, localFileLoc)
-			if err != nil {
-				log.Warn("版本文件上传oss时出现错误",
-					zap.String("oss_key", ossObjectKey),
-					zap.String("local_source", localFileLoc),
-					zap.Error(err),
-				)
-				fileKey = ""
-				_, _ = s.dao.UpdateVersion(&model.Version{ID: param.Id, File: &fileKey})
-			} else {
-				err = os.Remove(localFileLoc)
-				if err != nil {
-					log.Warn("移除本地版本文件时出现异常", zap.String("file", localFileLoc), zap.Error(err))
-				}
-				log.Info("版本文件上传oss成功",
-					zap.String("oss_key", ossObjectKey),
-					zap.String("local_source", localFileLoc),
-				)
-			}
-			log.Info("新版本文件处理后台任务完成")
-		}()
+		localFile = localFileLoc
+	}
+
+	// 上传新文件的任务、ipa的manifest和版本信息一起写入
+	artifacts, jobs := make([]model.VersionArtifact, 0, 1), make([]model.Job, 0, 2)
+	if localFile != "" {
+		job, err := s.newJob(JobTypeVersionFileUpload, versionFileUploadJob{
+			VersionId: version.ID,
+			FileKey:   *version.File,
+			LocalFile: localFile,
+		})
+		if err != nil {
+			return err
+		}
+		jobs = append(jobs, *job)
+	}
+
+	if fileInfo != nil && fileInfo.Ipa != nil {
+		manifest, manifestJob, err := s.newIosManifest(version.ID, *version.File, fileInfo.Ipa)
+		if err != nil {
+			return err
+		}
+		artifacts, jobs = append(artifacts, *manifest), append(jobs, *manifestJob)
+	}
+
+	err = s.saveJobUploadFile(param.UploadFile, localFile, jobs)
+	if err != nil {
+		return err
 	}
 
 	*version.UpdateTime = time.Now()
-	_, err := s.dao.UpdateVersion(&version)
+	_, err = s.dao.UpdateVersion(&version, artifacts, jobs...)
 	if err != nil {
+		s.removeJobLocalFiles(jobs)
 		return err
 	}
 
+	s.releaseUploadFile(param.UploadFile)
+
 	return nil
 }
 
@@ -210,7 +383,7 @@ func (s *Service) DeleteVersion(id int64) error {
 	if existsVersion.File != nil && *existsVersion.File != "" {
 		resourceStorageOption := s.config.Server.FileStorageOption.ResourceStorageOption
 		ossObjectKey := fmt.Sprintf("%s/%s", resourceStorageOption.VersionFileStorageBasePath, *existsVersion.File)
-		err = s.ossBucket.SetObjectACL(ossObjectKey, oss.ACLPrivate)
+		err = s.storage.Hide(ossObjectKey)
 		if err != nil {
 			log.Warn("删除oss文件出现错误", zap.String("oss_key", ossObjectKey), zap.Error(err))
 		} else {
@@ -222,33 +395,294 @@ func (s *Service) DeleteVersion(id int64) error {
 }
 
 func (s *Service) PublishVersion(id int64) error {
-	_, err := s.dao.PublishVersion(id)
+	now := time.Now()
+	history := model.VersionPublishHistory{ID: idgen.NextId(), CreateTime: &now}
+	_, err := s.dao.PublishVersion(id, &history)
 	if err != nil {
 		return err
 	}
 
-	go func() {
-		log.Info("新版本发布后台任务开始")
-		version, err := s.dao.GetVersion(id)
+	return s.addJob(JobTypeReleaseFileLink, releaseFileLinkJob{VersionId: id})
+}
+
+type versionFileInfo struct {
+	Size   int64
+	Sha256 string
+	Apk    *apk.Info // 非android平台为空
+	Ipa    *ipa.Info // ios平台上传ipa时才有
+}
+
+// fill 将版本文件信息填到版本记录中
+func (info *versionFileInfo) fill(version *model.Version) {
+	packageName, versionCode, minSdk := "", int32(0), int32(0)
+	if info.Apk != nil {
+		packageName, versionCode, minSdk = info.Apk.PackageName, info.Apk.VersionCode, info.Apk.MinSdk
+	} else if info.Ipa != nil {
+		packageName = info.Ipa.BundleId
+	}
+
+	version.FileSize = &info.Size
+	version.FileSha256 = &info.Sha256
+	version.PackageName = &packageName
+	version.VersionCode = &versionCode
+	version.MinSdk = &minSdk
+}
+
+// inspectVersionFile 计算版本文件的大小和sha256，android平台还会解析apk，ios平台上传ipa时解析Info.plist，校验其中的版本号和包名
+func (s *Service) inspectVersionFile(file *File, platform, versionText string) (*versionFileInfo, error) {
+	var reader io.ReaderAt
+	var size int64
+	if file.LocalPath != "" {
+		f, err := os.Open(file.LocalPath)
 		if err != nil {
-			return
+			log.Error("打开版本文件时出现错误", zap.String("file", file.LocalPath), zap.Error(err))
+			return nil, ecode.InternalError
 		}
+		defer f.Close()
 
-		if version.File == nil || *version.File == "" {
-			log.Info("该平台版本无文件，不需处理", zap.Int64("id", id))
-			return
+		stat, err := f.Stat()
+		if err != nil {
+			log.Error("获取版本文件信息时出现错误", zap.String("file", file.LocalPath), zap.Error(err))
+			return nil, ecode.InternalError
 		}
+		reader, size = f, stat.Size()
+	} else {
+		reader, size = bytes.NewReader(*file.Data), int64(len(*file.Data))
+	}
 
-		resourceStorageOption := s.config.Server.FileStorageOption.ResourceStorageOption
-		ossObjectKey := fmt.Sprintf("%s/%s", resourceStorageOption.VersionFileStorageBasePath, *version.File)
-		err = s.ossBucket.PutSymlink(resourceStorageOption.WusthelperReleaseFileKey, ossObjectKey)
+	hash := sha256.New()
+	if _, err := io.Copy(hash, io.NewSectionReader(reader, 0, size)); err != nil {
+		log.Error("计算版本文件sha256时出现错误", zap.String("file", file.FileName), zap.Error(err))
+		return nil, ecode.InternalError
+	}
+
+	info := &versionFileInfo{
+		Size:   size,
+		Sha256: hex.EncodeToString(hash.Sum(nil)),
+	}
+
+	var err error
+
+	if platform == _platformIos && strings.HasSuffix(strings.ToLower(file.FileName), ".ipa") {
+		info.Ipa, err = s.inspectIpa(reader, size, file.FileName, versionText)
 		if err != nil {
-			log.Error("创建助手网页发布文件oss软连接时出现错误", zap.Int64("id", id), zap.Error(err))
-			return
+			return nil, err
+		}
+		return info, nil
+	} else if platform != _platformAndroid {
+		return info, nil
+	}
+
+	apkInfo, err := apk.Parse(reader, size)
+	if err != nil {
+		log.Warn("解析apk文件时出现错误", zap.String("file", file.FileName), zap.Error(err))
+		return nil, ecode.VersionFileInvalid
+	}
+
+	if apkInfo.VersionName != versionText {
+		log.Warn("apk中的版本号与填写的版本号不一致",
+			zap.String("apk_version", apkInfo.VersionName),
+			zap.String("version", versionText),
+		)
+		return nil, ecode.VersionNameMismatch
+	}
+
+	packageName := s.config.Server.VersionOption.AndroidPackageName
+	if packageName != "" && apkInfo.PackageName != packageName {
+		log.Warn("apk的包名不正确", zap.String("package_name", apkInfo.PackageName))
+		return nil, ecode.PackageNameMismatch
+	}
+
+	err = s.verifyApkSigner(reader, size)
+	if err != nil {
+		return nil, err
+	}
+
+	info.Apk = apkInfo
+	return info, nil
+}
+
+// inspectIpa 解析ipa中的Info.plist，校验版本号和bundle id
+func (s *Service) inspectIpa(reader io.ReaderAt, size int64, fileName, versionText string) (*ipa.Info, error) {
+	ipaInfo, err := ipa.Parse(reader, size)
+	if err != nil {
+		log.Warn("解析ipa文件时出现错误", zap.String("file", fileName), zap.Error(err))
+		return nil, ecode.VersionFileInvalid
+	}
+
+	if ipaInfo.ShortVersion != versionText {
+		log.Warn("ipa中的版本号与填写的版本号不一致",
+			zap.String("ipa_version", ipaInfo.ShortVersion),
+			zap.String("version", versionText),
+		)
+		return nil, ecode.VersionNameMismatch
+	}
+
+	bundleId := s.config.Server.VersionOption.IosBundleId
+	if bundleId != "" && ipaInfo.BundleId != bundleId {
+		log.Warn("ipa的bundle id不正确", zap.String("bundle_id", ipaInfo.BundleId))
+		return nil, ecode.PackageNameMismatch
+	}
+
+	return ipaInfo, nil
+}
+
+// verifyApkSigner 校验apk签名，且所有签名者的证书都需要在配置的指纹列表中，避免上传非正式签名的安装包
+func (s *Service) verifyApkSigner(reader io.ReaderAt, size int64) error {
+	fingerprints := s.config.Server.VersionOption.AndroidSignerFingerprints
+	if len(fingerprints) == 0 {
+		return nil
+	}
+
+	certs, err := apk.VerifySignature(reader, size)
+	if err != nil {
+		log.Warn("apk签名校验失败", zap.Error(err))
+		return ecode.ApkSignatureInvalid
+	}
+
+	for _, cert := range certs {
+		fingerprint := apk.SignerFingerprint(cert)
+		trusted := false
+		for _, pinned := range fingerprints {
+			if strings.EqualFold(strings.ReplaceAll(pinned, ":", ""), fingerprint) {
+				trusted = true
+				break
+			}
 		}
 
-		log.Info("新版本发布后台任务完毕")
-	}()
+		if !trusted {
+			log.Warn("apk的签名证书不在允许列表中",
+				zap.String("subject", cert.Subject.String()),
+				zap.String("fingerprint", fingerprint),
+			)
+			return ecode.ApkSignerNotTrusted
+		}
+	}
+
+	return nil
+}
+
+type versionFileUploadJob struct {
+	VersionId int64  `json:"version_id"`
+	FileKey   string `json:"file_key"`
+	LocalFile string `json:"local_file"`
+}
+
+func (s *Service) runVersionFileUploadJob(payload []byte) error {
+	job := new(versionFileUploadJob)
+	if err := jsoniter.Unmarshal(payload, job); err != nil {
+		return err
+	}
+
+	resourceStorageOption := s.config.Server.FileStorageOption.ResourceStorageOption
+	ossObjectKey := fmt.Sprintf("%s/%s", resourceStorageOption.VersionFileStorageBasePath, job.FileKey)
+	err := s.uploadLocalFile(ossObjectKey, job.LocalFile, "")
+	if err != nil {
+		return err
+	}
+
+	// 任务失败时文件记录会被清空，手动重试成功后要恢复
+	_, err = s.dao.RestoreVersionFile(job.VersionId, job.FileKey)
+	if err != nil {
+		return err
+	}
+
+	// 增量包生成比较慢，单独作为任务，以免上传任务重试时重复生成
+	return s.addJob(JobTypeVersionPatchGen, versionPatchGenerateJob{VersionId: job.VersionId})
+}
+
+// onVersionFileUploadJobFail 文件最终上传失败时，清空版本的文件记录，避免指向不存在的文件
+func (s *Service) onVersionFileUploadJobFail(payload []byte) {
+	job := new(versionFileUploadJob)
+	if err := jsoniter.Unmarshal(payload, job); err != nil {
+		return
+	}
+
+	version, err := s.dao.GetVersion(job.VersionId)
+	if err != nil || version == nil || version.File == nil || *version.File != job.FileKey {
+		return
+	}
+
+	fileKey := ""
+	_, _ = s.dao.UpdateVersion(&model.Version{ID: job.VersionId, File: &fileKey}, nil)
+}
+
+type releaseFileLinkJob struct {
+	VersionId int64 `json:"version_id"`
+}
+
+func (s *Service) runReleaseFileLinkJob(payload []byte) error {
+	job := new(releaseFileLinkJob)
+	if err := jsoniter.Unmarshal(payload, job); err != nil {
+		return err
+	}
+
+	version, err := s.dao.GetVersion(job.VersionId)
+	if err != nil {
+		return err
+	}
+
+	// 任务执行前又发布了别的版本，或者版本已经被删除，以后来的发布为准
+	if version == nil || *version.Status != model.VersionPublishedStatus {
+		log.Info("版本已不是发布状态，不需处理", zap.Int64("id", job.VersionId))
+		return nil
+	}
+
+	if version.File == nil || *version.File == "" {
+		log.Info("该平台版本无文件，不需处理", zap.Int64("id", job.VersionId))
+		return nil
+	}
+
+	// 发布文件链接只给稳定渠道使用
+	if *version.Channel != model.VersionChannelStable {
+		log.Info("非稳定渠道版本，不需处理", zap.Int64("id", job.VersionId), zap.String("channel", *version.Channel))
+		return nil
+	}
+
+	return s.linkReleaseFile(version)
+}
+
+// ReleaseFileKey 获取平台最新版本文件的固定链接，没有配置时返回空
+func (s *Service) ReleaseFileKey(platform string) string {
+	resourceStorageOption := s.config.Server.FileStorageOption.ResourceStorageOption
+	if linkKey, ok := resourceStorageOption.ReleaseFileKeys[platform]; ok {
+		return linkKey
+	} else if platform == _platformAndroid {
+		return resourceStorageOption.WusthelperReleaseFileKey
+	}
+
+	return ""
+}
+
+// linkReleaseFile 将版本所在平台的发布文件链接指向版本文件，并确认存储中的链接已经指向该文件，平台没有配置固定链接时不处理
+func (s *Service) linkReleaseFile(version *model.Version) error {
+	linkKey := s.ReleaseFileKey(*version.Platform)
+	if linkKey == "" {
+		log.Info("该平台没有配置发布文件链接，不需处理", zap.Int64("id", version.ID), zap.String("platform", *version.Platform))
+		return nil
+	}
+
+	resourceStorageOption := s.config.Server.FileStorageOption.ResourceStorageOption
+	ossObjectKey := fmt.Sprintf("%s/%s", resourceStorageOption.VersionFileStorageBasePath, *version.File)
+	err := s.storage.Link(linkKey, ossObjectKey)
+	if err != nil {
+		log.Error("创建助手网页发布文件oss软连接时出现错误", zap.Int64("id", version.ID), zap.Error(err))
+		return ecode.ReleaseFileLinkFailed
+	}
+
+	target, err := s.storage.LinkTarget(linkKey)
+	if err != nil {
+		log.Error("确认助手网页发布文件oss软连接时出现错误", zap.Int64("id", version.ID), zap.Error(err))
+		return ecode.ReleaseFileLinkFailed
+	} else if target != ossObjectKey {
+		log.Error("助手网页发布文件oss软连接指向不正确",
+			zap.Int64("id", version.ID),
+			zap.String("expected", ossObjectKey),
+			zap.String("actual", target),
+		)
+		return ecode.ReleaseFileLinkFailed
+	}
 
+	log.Info("助手网页发布文件oss软连接已更新", zap.Int64("id", version.ID), zap.String("target", ossObjectKey))
 	return nil
 }
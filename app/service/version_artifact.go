@@ -0,0 +1,335 @@
+package service
+
+import (
+	"fmt"
+	jsoniter "github.com/json-iterator/go"
+	"github.com/yitter/idgenerator-go/idgen"
+	"go.uber.org/zap"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+	"time"
+	"wusthelper-manager-go/app/model"
+	"wusthelper-manager-go/library/ecode"
+	"wusthelper-manager-go/library/ipa"
+	"wusthelper-manager-go/library/log"
+)
+
+// _artifactPlatforms 各类型文件所属的平台，外部链接不限平台
+var _artifactPlatforms = map[string]string{
+	model.VersionArtifactApkUniversal: _platformAndroid,
+	model.VersionArtifactApkArm64:     _platformAndroid,
+	model.VersionArtifactApkArmv7:     _platformAndroid,
+	model.VersionArtifactIpa:          _platformIos,
+	model.VersionArtifactIosManifest:  _platformIos,
+	model.VersionArtifactStoreLink:    "",
+}
+
+// _abiArtifactTypes 客户端ABI对应的安装包类型
+var _abiArtifactTypes = map[string]string{
+	"arm64-v8a":   model.VersionArtifactApkArm64,
+	"armeabi-v7a": model.VersionArtifactApkArmv7,
+}
+
+// GetVersionArtifactList 获取版本已上传完成的下载文件
+func (s *Service) GetVersionArtifactList(versionId int64) (*[]model.VersionArtifact, error) {
+	return s.dao.GetVersionArtifactList(versionId, true)
+}
+
+type VersionArtifactAddParam struct {
+	VersionId  int64
+	Type       string
+	Url        string // 外部链接，只有store_link类型使用
+	Mirrors    []string
+	UploadFile *File // 除store_link外都需要上传文件
+}
+
+// AddVersionArtifact 给版本添加一个下载文件，每种类型只能有一个，安装包和主文件一样会校验版本号、包名和签名
+func (s *Service) AddVersionArtifact(param *VersionArtifactAddParam) error {
+	platform, ok := _artifactPlatforms[param.Type]
+	if !ok {
+		return ecode.ArtifactTypeInvalid
+	}
+
+	for _, mirror := range param.Mirrors {
+		if !isHttpUrl(mirror) {
+			return ecode.ParamWrong
+		}
+	}
+
+	version, err := s.dao.GetVersion(param.VersionId)
+	if err != nil {
+		return err
+	} else if version == nil {
+		return ecode.VersionOperationFailed
+	}
+
+	if platform != "" && platform != *version.Platform {
+		return ecode.ArtifactTypeInvalid
+	}
+
+	// 正在上传的文件也算，避免同一类型上传两次
+	artifactList, err := s.dao.GetVersionArtifactList(param.VersionId, false)
+	if err != nil {
+		return err
+	}
+	for _, artifact := range *artifactList {
+		if *artifact.Type == param.Type {
+			return ecode.ArtifactExists
+		}
+	}
+
+	now, empty, size, ready := time.Now(), "", int64(0), false
+	artifact := model.VersionArtifact{
+		ID:         idgen.NextId(),
+		VersionId:  &param.VersionId,
+		Type:       &param.Type,
+		File:       &empty,
+		Url:        &empty,
+		FileSize:   &size,
+		FileSha256: &empty,
+		Mirrors:    param.Mirrors,
+		Ready:      &ready,
+		CreateTime: &now,
+		Status:     new(int8),
+	}
+
+	if param.Type == model.VersionArtifactStoreLink {
+		if param.UploadFile != nil || param.Url == "" {
+			return ecode.ParamWrong
+		}
+		if !isStoreLinkUrl(param.Url) {
+			return ecode.ParamWrong
+		}
+
+		ready = true
+		artifact.Url = &param.Url
+		_, err = s.dao.AddVersionArtifact(&artifact)
+		if err != nil {
+			return err
+		}
+
+		log.Info("版本文件已添加", zap.Int64("version_id", param.VersionId), zap.String("type", param.Type))
+		return nil
+	}
+
+	if param.UploadFile == nil {
+		return ecode.ParamWrong
+	}
+
+	fileInfo, err := s.inspectVersionFile(param.UploadFile, platform, *version.VersionText)
+	if err != nil {
+		return err
+	}
+
+	storageOption := s.config.Server.FileStorageOption
+	localFile := fmt.Sprintf("%s/%d-%s", storageOption.UploadFileLocalTmpPath, artifact.ID, param.UploadFile.FileName)
+
+	fileKey := fmt.Sprintf("%d/%s", idgen.NextId(), param.UploadFile.FileName)
+	artifact.File, artifact.FileSize, artifact.FileSha256 = &fileKey, &fileInfo.Size, &fileInfo.Sha256
+	job, err := s.newJob(JobTypeArtifactUpload, versionArtifactUploadJob{
+		ArtifactId: artifact.ID,
+		FileKey:    fileKey,
+		LocalFile:  localFile,
+	})
+	if err != nil {
+		return err
+	}
+
+	artifacts, jobs := []model.VersionArtifact{artifact}, []model.Job{*job}
+	if fileInfo.Ipa != nil {
+		manifest, manifestJob, err := s.newIosManifest(param.VersionId, fileKey, fileInfo.Ipa)
+		if err != nil {
+			return err
+		}
+		artifacts, jobs = append(artifacts, *manifest), append(jobs, *manifestJob)
+	}
+
+	err = s.saveJobUploadFile(param.UploadFile, localFile, jobs)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.dao.AddVersionArtifacts(param.VersionId, artifacts, jobs...)
+	if err != nil {
+		s.removeJobLocalFiles(jobs)
+		return err
+	}
+
+	s.releaseUploadFile(param.UploadFile)
+
+	log.Info("版本文件已添加", zap.Int64("version_id", param.VersionId), zap.String("type", param.Type))
+	return nil
+}
+
+// newIosManifest 为上传的ipa生成itms-services安装用的manifest.plist并写到本地临时目录，返回ios_manifest文件记录和上传任务。
+// 由调用方和版本信息一起写入数据库，写入时替换原有的manifest，原有的文件由存储清理任务删除
+func (s *Service) newIosManifest(versionId int64, ipaFileKey string, ipaInfo *ipa.Info) (*model.VersionArtifact, *model.Job, error) {
+	resourceStorageOption := s.config.Server.FileStorageOption.ResourceStorageOption
+	ipaUrl := s.GetFileUrl(path.Join(resourceStorageOption.VersionFileStorageBasePath, ipaFileKey))
+	data, err := ipa.Manifest(ipaInfo, ipaUrl)
+	if err != nil {
+		log.Error("生成manifest.plist时出现错误", zap.Int64("version_id", versionId), zap.Error(err))
+		return nil, nil, ecode.InternalError
+	}
+
+	now, artifactType, empty, ready := time.Now(), model.VersionArtifactIosManifest, "", false
+	size, sha256Text := int64(len(data)), sha256Hex(data)
+	fileKey := fmt.Sprintf("%s/manifest.plist", path.Dir(ipaFileKey))
+	artifact := model.VersionArtifact{
+		ID:         idgen.NextId(),
+		VersionId:  &versionId,
+		Type:       &artifactType,
+		File:       &fileKey,
+		Url:        &empty,
+		FileSize:   &size,
+		FileSha256: &sha256Text,
+		Ready:      &ready,
+		CreateTime: &now,
+		Status:     new(int8),
+	}
+
+	localFile := fmt.Sprintf("%s/%d-manifest.plist", s.config.Server.FileStorageOption.UploadFileLocalTmpPath, artifact.ID)
+	if err = os.WriteFile(localFile, data, 0644); err != nil {
+		log.Error("写manifest.plist到本地临时目录时出现错误", zap.String("file", localFile), zap.Error(err))
+		return nil, nil, ecode.InternalError
+	}
+
+	job, err := s.newJob(JobTypeArtifactUpload, versionArtifactUploadJob{
+		ArtifactId:  artifact.ID,
+		FileKey:     fileKey,
+		LocalFile:   localFile,
+		ContentType: "application/xml",
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return &artifact, job, nil
+}
+
+// DeleteVersionArtifact 删除版本的一个下载文件，存储中的文件设置为不可访问
+func (s *Service) DeleteVersionArtifact(id int64) error {
+	artifact, err := s.dao.GetVersionArtifact(id)
+	if err != nil {
+		return err
+	} else if artifact == nil {
+		return ecode.VersionOperationFailed
+	}
+
+	_, err = s.dao.DeleteVersionArtifact(id)
+	if err != nil {
+		return err
+	}
+
+	if artifact.File != nil && *artifact.File != "" {
+		resourceStorageOption := s.config.Server.FileStorageOption.ResourceStorageOption
+		ossObjectKey := fmt.Sprintf("%s/%s", resourceStorageOption.VersionFileStorageBasePath, *artifact.File)
+		err = s.storage.Hide(ossObjectKey)
+		if err != nil {
+			log.Warn("删除oss文件出现错误", zap.String("oss_key", ossObjectKey), zap.Error(err))
+		}
+	}
+
+	log.Info("版本文件已删除", zap.Int64("id", id), zap.Int64("version_id", *artifact.VersionId))
+	return nil
+}
+
+// SelectVersionArtifact 按平台和客户端支持的ABI（按优先级排列）选择下载文件，没有合适的文件时返回nil，此时使用版本的主文件。
+// android优先使用ABI对应的安装包，其次通用安装包；ios优先使用manifest.plist安装
+func (s *Service) SelectVersionArtifact(version *model.Version, abis []string) (*model.VersionArtifact, error) {
+	if version == nil {
+		return nil, nil
+	}
+
+	artifactList, err := s.dao.GetVersionArtifactList(version.ID, true)
+	if err != nil {
+		return nil, err
+	}
+
+	artifacts := make(map[string]*model.VersionArtifact, len(*artifactList))
+	for i := range *artifactList {
+		artifact := &(*artifactList)[i]
+		artifacts[*artifact.Type] = artifact
+	}
+
+	preferred := make([]string, 0)
+	switch *version.Platform {
+	case _platformAndroid:
+		for _, abi := range abis {
+			if artifactType, ok := _abiArtifactTypes[abi]; ok {
+				preferred = append(preferred, artifactType)
+			}
+		}
+		preferred = append(preferred, model.VersionArtifactApkUniversal)
+	case _platformIos:
+		preferred = append(preferred, model.VersionArtifactIosManifest)
+	}
+	preferred = append(preferred, model.VersionArtifactStoreLink)
+
+	for _, artifactType := range preferred {
+		if artifact, ok := artifacts[artifactType]; ok {
+			return artifact, nil
+		}
+	}
+
+	return nil, nil
+}
+
+type versionArtifactUploadJob struct {
+	ArtifactId  int64  `json:"artifact_id"`
+	FileKey     string `json:"file_key"`
+	LocalFile   string `json:"local_file"`
+	ContentType string `json:"content_type,omitempty"`
+}
+
+func (s *Service) runVersionArtifactUploadJob(payload []byte) error {
+	job := new(versionArtifactUploadJob)
+	if err := jsoniter.Unmarshal(payload, job); err != nil {
+		return err
+	}
+
+	resourceStorageOption := s.config.Server.FileStorageOption.ResourceStorageOption
+	ossObjectKey := fmt.Sprintf("%s/%s", resourceStorageOption.VersionFileStorageBasePath, job.FileKey)
+	err := s.uploadLocalFile(ossObjectKey, job.LocalFile, job.ContentType)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.dao.SetVersionArtifactReady(job.ArtifactId)
+	return err
+}
+
+// onVersionArtifactUploadJobFail 文件最终上传失败时删除该文件记录，避免下发不存在的文件
+func (s *Service) onVersionArtifactUploadJobFail(payload []byte) {
+	job := new(versionArtifactUploadJob)
+	if err := jsoniter.Unmarshal(payload, job); err != nil {
+		return
+	}
+
+	_, _ = s.dao.DeleteVersionArtifact(job.ArtifactId)
+}
+
+func isHttpUrl(text string) bool {
+	parsed, err := url.Parse(text)
+	return err == nil && (parsed.Scheme == "http" || parsed.Scheme == "https") && parsed.Host != ""
+}
+
+// _storeLinkSchemes 除http(s)外，应用商店链接允许的协议
+var _storeLinkSchemes = map[string]bool{
+	"market":     true, // android应用市场
+	"itms-apps":  true,
+	"itms-beta":  true, // TestFlight
+	"itms-appss": true,
+}
+
+// isStoreLinkUrl 外部链接只能是http(s)地址或应用商店的链接，不能是javascript:等会在网页中执行的链接
+func isStoreLinkUrl(text string) bool {
+	if isHttpUrl(text) {
+		return true
+	}
+
+	parsed, err := url.Parse(text)
+	return err == nil && _storeLinkSchemes[strings.ToLower(parsed.Scheme)] && (parsed.Host != "" || parsed.Opaque != "")
+}
@@ -0,0 +1,274 @@
+package service
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"github.com/gabstv/go-bsdiff/pkg/bsdiff"
+	jsoniter "github.com/json-iterator/go"
+	"github.com/yitter/idgenerator-go/idgen"
+	"go.uber.org/zap"
+	"os"
+	"path"
+	"path/filepath"
+	"sync"
+	"time"
+	"wusthelper-manager-go/app/model"
+	"wusthelper-manager-go/library/log"
+)
+
+const (
+	_defaultPatchBaseCount   = 3
+	_defaultPatchMaxFileSize = 100 // 单位MB
+)
+
+// _patchGenerateLock bsdiff需要把新旧文件都读到内存中，占用的内存是文件大小的十几倍，同时只生成一个版本的增量包
+var _patchGenerateLock sync.Mutex
+
+type versionPatchGenerateJob struct {
+	VersionId int64 `json:"version_id"`
+}
+
+// runVersionPatchGenerateJob 对最近发布过的几个android版本生成到该版本的bsdiff增量包，增量包不比完整安装包小时不保存。
+// 已有对应当前文件的增量包时跳过，保证重试时不会重复生成。新旧版本文件超过大小上限时不生成
+func (s *Service) runVersionPatchGenerateJob(payload []byte) error {
+	job := new(versionPatchGenerateJob)
+	if err := jsoniter.Unmarshal(payload, job); err != nil {
+		return err
+	}
+
+	baseCount := s.config.Server.VersionOption.PatchBaseCount
+	if baseCount == 0 {
+		baseCount = _defaultPatchBaseCount
+	} else if baseCount < 0 {
+		return nil
+	}
+
+	version, err := s.dao.GetVersion(job.VersionId)
+	if err != nil {
+		return err
+	}
+
+	if version == nil || *version.Platform != _platformAndroid ||
+		isEmptyString(version.File) || isEmptyString(version.FileSha256) {
+		log.Info("版本不存在或不是android安装包，不需生成增量包", zap.Int64("id", job.VersionId))
+		return nil
+	}
+
+	maxFileSize := s.patchMaxFileSize()
+	if version.FileSize != nil && *version.FileSize > maxFileSize {
+		log.Info("版本文件超过大小上限，不生成增量包", zap.Int64("id", version.ID), zap.Int64("size", *version.FileSize))
+		return nil
+	}
+
+	_patchGenerateLock.Lock()
+	defer _patchGenerateLock.Unlock()
+
+	bases, err := s.getPatchBaseVersions(version, baseCount, maxFileSize)
+	if err != nil {
+		return err
+	} else if len(bases) == 0 {
+		return nil
+	}
+
+	tmpDir, err := os.MkdirTemp(s.config.Server.FileStorageOption.UploadFileLocalTmpPath, "patch-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmpDir)
+
+	// 版本文件在任务执行前被替换时，新文件上传后会重新生成
+	target, err := s.downloadVersionFile(version, filepath.Join(tmpDir, "target"), maxFileSize)
+	if err != nil {
+		return err
+	} else if target == nil {
+		log.Info("版本文件已变化或超过大小上限，不需生成增量包", zap.Int64("id", version.ID))
+		return nil
+	}
+
+	for i := range bases {
+		err = s.generateVersionPatch(version, &bases[i], target, tmpDir, maxFileSize)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// patchMaxFileSize 生成增量包的文件大小上限，单位字节
+func (s *Service) patchMaxFileSize() int64 {
+	maxFileSize := s.config.Server.VersionOption.PatchMaxFileSize
+	if maxFileSize <= 0 {
+		maxFileSize = _defaultPatchMaxFileSize
+	}
+
+	return int64(maxFileSize) << 20
+}
+
+// getPatchBaseVersions 获取需要生成增量包的基础版本：最近发布过、比该版本低、文件不超过大小上限、且没有对应当前文件的增量包的版本
+func (s *Service) getPatchBaseVersions(version *model.Version, count int, maxFileSize int64) ([]model.Version, error) {
+	recentList, err := s.dao.GetRecentPublishedVersionList(*version.Platform, count)
+	if err != nil {
+		return nil, err
+	}
+
+	patchList, err := s.dao.GetVersionPatchList(version.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	versionSemver := parseVersionSemver(version)
+	bases := make([]model.Version, 0, len(*recentList))
+	for _, base := range *recentList {
+		if base.ID == version.ID || isEmptyString(base.File) || isEmptyString(base.FileSha256) ||
+			(base.FileSize != nil && *base.FileSize > maxFileSize) ||
+			compareSemver(parseVersionSemver(&base), versionSemver) >= 0 {
+			continue
+		}
+
+		generated := false
+		for _, patch := range *patchList {
+			if *patch.BaseVersionId == base.ID &&
+				*patch.BaseSha256 == *base.FileSha256 && *patch.TargetSha256 == *version.FileSha256 {
+				generated = true
+				break
+			}
+		}
+
+		if !generated {
+			bases = append(bases, base)
+		}
+	}
+
+	return bases, nil
+}
+
+// downloadVersionFile 下载版本文件并读到内存，文件超过maxFileSize或sha256与版本记录不一致时返回nil
+func (s *Service) downloadVersionFile(version *model.Version, localFile string, maxFileSize int64) ([]byte, error) {
+	resourceStorageOption := s.config.Server.FileStorageOption.ResourceStorageOption
+	ossObjectKey := fmt.Sprintf("%s/%s", resourceStorageOption.VersionFileStorageBasePath, *version.File)
+	err := s.storage.GetFile(ossObjectKey, localFile)
+	if err != nil {
+		log.Warn("下载版本文件时出现错误", zap.String("oss_key", ossObjectKey), zap.Error(err))
+		return nil, err
+	}
+
+	// 旧版本记录可能没有文件大小，读到内存前再按实际大小检查一次
+	info, err := os.Stat(localFile)
+	if err != nil {
+		return nil, err
+	} else if info.Size() > maxFileSize {
+		return nil, nil
+	}
+
+	data, err := os.ReadFile(localFile)
+	if err != nil {
+		return nil, err
+	}
+
+	if sha256Hex(data) != *version.FileSha256 {
+		return nil, nil
+	}
+
+	return data, nil
+}
+
+func (s *Service) generateVersionPatch(version, base *model.Version, target []byte, tmpDir string, maxFileSize int64) error {
+	baseData, err := s.downloadVersionFile(base, filepath.Join(tmpDir, fmt.Sprintf("base-%d", base.ID)), maxFileSize)
+	if err != nil {
+		return err
+	} else if baseData == nil {
+		log.Warn("基础版本文件与记录不一致或超过大小上限，跳过生成增量包", zap.Int64("id", version.ID), zap.Int64("base_id", base.ID))
+		return nil
+	}
+
+	patchData, err := bsdiff.Bytes(baseData, target)
+	if err != nil {
+		log.Error("生成增量包时出现错误", zap.Int64("id", version.ID), zap.Int64("base_id", base.ID), zap.Error(err))
+		return err
+	}
+
+	if len(patchData) >= len(target) {
+		log.Info("增量包不比完整安装包小，不保存",
+			zap.Int64("id", version.ID),
+			zap.Int64("base_id", base.ID),
+			zap.Int("patch_size", len(patchData)),
+		)
+		return nil
+	}
+
+	localFile := filepath.Join(tmpDir, fmt.Sprintf("patch-%d", base.ID))
+	if err = os.WriteFile(localFile, patchData, 0644); err != nil {
+		return err
+	}
+
+	// 增量包和完整安装包放在同一目录下
+	fileKey := fmt.Sprintf("%s/patch_from_%d.bsdiff", path.Dir(*version.File), base.ID)
+	resourceStorageOption := s.config.Server.FileStorageOption.ResourceStorageOption
+	ossObjectKey := fmt.Sprintf("%s/%s", resourceStorageOption.VersionFileStorageBasePath, fileKey)
+	if err = s.uploadLocalFile(ossObjectKey, localFile, "application/octet-stream"); err != nil {
+		return err
+	}
+
+	now, size, patchSha256 := time.Now(), int64(len(patchData)), sha256Hex(patchData)
+	patch := model.VersionPatch{
+		ID:              idgen.NextId(),
+		VersionId:       &version.ID,
+		BaseVersionId:   &base.ID,
+		BaseVersionText: base.VersionText,
+		BaseSha256:      base.FileSha256,
+		TargetSha256:    version.FileSha256,
+		File:            &fileKey,
+		FileSize:        &size,
+		FileSha256:      &patchSha256,
+		CreateTime:      &now,
+		Status:          new(int8),
+	}
+
+	err = s.dao.ReplaceVersionPatch(&patch)
+	if err != nil {
+		return err
+	}
+
+	log.Info("版本增量包已生成",
+		zap.Int64("id", version.ID),
+		zap.Int64("base_id", base.ID),
+		zap.Int64("size", size),
+	)
+	return nil
+}
+
+// GetVersionPatch 获取从客户端当前版本升级到版本的增量包，没有或增量包已经和两个版本的文件对不上时返回nil
+func (s *Service) GetVersionPatch(version *model.Version, currentVersion string) (*model.VersionPatch, error) {
+	if currentVersion == "" || version == nil || isEmptyString(version.FileSha256) {
+		return nil, nil
+	}
+
+	patch, err := s.dao.GetVersionPatch(version.ID, currentVersion)
+	if err != nil || patch == nil {
+		return nil, err
+	}
+
+	if *patch.TargetSha256 != *version.FileSha256 {
+		return nil, nil
+	}
+
+	base, err := s.dao.GetVersion(*patch.BaseVersionId)
+	if err != nil {
+		return nil, err
+	} else if base == nil || isEmptyString(base.FileSha256) || *base.FileSha256 != *patch.BaseSha256 {
+		return nil, nil
+	}
+
+	return patch, nil
+}
+
+func isEmptyString(s *string) bool {
+	return s == nil || *s == ""
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,134 @@
+package service
+
+import (
+	"github.com/yitter/idgenerator-go/idgen"
+	"go.uber.org/zap"
+	"time"
+	"wusthelper-manager-go/app/model"
+	"wusthelper-manager-go/library/ecode"
+	"wusthelper-manager-go/library/log"
+	"wusthelper-manager-go/library/semver"
+)
+
+const (
+	VersionUpdateNone     = "none"     // 不需要更新
+	VersionUpdateOptional = "optional" // 有新版本，可选更新
+	VersionUpdateForced   = "forced"   // 必须更新
+)
+
+const (
+	VersionUpdateReasonUpToDate         = "up_to_date"        // 已经是最新版本
+	VersionUpdateReasonNewVersion       = "new_version"       // 有新版本
+	VersionUpdateReasonMandatoryRelease = "mandatory_release" // 当前版本之后有强制更新的版本
+	VersionUpdateReasonBelowMinVersion  = "below_min_version" // 当前版本低于最低支持版本
+)
+
+func (s *Service) GetVersionPolicyList() (*[]model.VersionPolicy, error) {
+	return s.dao.GetVersionPolicyList()
+}
+
+// SetVersionPolicy 设置平台的最低支持版本，minVersion为空时取消限制
+func (s *Service) SetVersionPolicy(platform, minVersion string) error {
+	if minVersion != "" {
+		if _, err := semver.Parse(minVersion); err != nil {
+			return ecode.VersionTextInvalid
+		}
+	}
+
+	existsPolicy, err := s.dao.GetVersionPolicy(platform)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	policy := model.VersionPolicy{
+		Platform:   &platform,
+		MinVersion: &minVersion,
+		UpdateTime: &now,
+	}
+
+	if existsPolicy != nil {
+		_, err = s.dao.UpdateVersionPolicy(&policy)
+	} else {
+		policy.ID = idgen.NextId()
+		policy.CreateTime = &now
+		_, err = s.dao.AddVersionPolicy(&policy)
+	}
+	if err != nil {
+		return err
+	}
+
+	log.Info("版本策略已修改", zap.String("platform", platform), zap.String("min_version", minVersion))
+	return nil
+}
+
+type VersionCheckResult struct {
+	Update     string         // VersionUpdateNone、VersionUpdateOptional或VersionUpdateForced
+	Reason     string         // VersionUpdateReasonXxx
+	MinVersion string         // 平台的最低支持版本，没有限制时为空
+	Latest     *model.Version // 最新版本，没有已发布的版本时为nil
+}
+
+// CheckVersion 根据客户端当前版本判断是否需要更新：
+// 低于最低支持版本，或当前版本到最新版本之间有强制更新的版本时需要强制更新，低于最新版本时可选更新。
+// client用于匹配测试人员和灰度发布，见 GetLatestVersionForClient
+func (s *Service) CheckVersion(platform, currentVersion string, client VersionClient) (*VersionCheckResult, error) {
+	current, err := semver.Parse(currentVersion)
+	if err != nil {
+		return nil, ecode.VersionTextInvalid
+	}
+
+	latest, err := s.GetLatestVersionForClient(platform, client)
+	if err != nil {
+		return nil, err
+	}
+
+	policy, err := s.dao.GetVersionPolicy(platform)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &VersionCheckResult{
+		Update: VersionUpdateNone,
+		Reason: VersionUpdateReasonUpToDate,
+		Latest: latest,
+	}
+
+	if policy != nil && policy.MinVersion != nil && *policy.MinVersion != "" {
+		result.MinVersion = *policy.MinVersion
+		minVersion, err := semver.Parse(*policy.MinVersion)
+		if err == nil && current.Compare(minVersion) < 0 {
+			result.Update, result.Reason = VersionUpdateForced, VersionUpdateReasonBelowMinVersion
+			return result, nil
+		}
+	}
+
+	latestSemver := (*semver.Version)(nil)
+	if latest != nil {
+		latestSemver = parseVersionSemver(latest)
+	}
+	if latestSemver == nil || current.Compare(latestSemver) >= 0 {
+		return result, nil
+	}
+
+	channels, err := s.getClientChannels(client)
+	if err != nil {
+		return nil, err
+	}
+
+	mandatoryVersionList, err := s.dao.GetMandatoryVersionList(platform, channels...)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range *mandatoryVersionList {
+		mandatorySemver := parseVersionSemver(&(*mandatoryVersionList)[i])
+		if mandatorySemver != nil && current.Compare(mandatorySemver) < 0 && mandatorySemver.Compare(latestSemver) <= 0 {
+			result.Update, result.Reason = VersionUpdateForced, VersionUpdateReasonMandatoryRelease
+			return result, nil
+		}
+	}
+
+	result.Update, result.Reason = VersionUpdateOptional, VersionUpdateReasonNewVersion
+	return result, nil
+}
@@ -0,0 +1,57 @@
+package service
+
+import (
+	"github.com/yitter/idgenerator-go/idgen"
+	"go.uber.org/zap"
+	"time"
+	"wusthelper-manager-go/app/model"
+	"wusthelper-manager-go/common"
+	"wusthelper-manager-go/library/log"
+)
+
+func (s *Service) GetVersionPublishHistoryList(pagination common.Pagination, platform, channel string) (*[]model.VersionPublishHistory, int64, error) {
+	return s.dao.GetVersionPublishHistoryList(pagination, platform, channel)
+}
+
+// RollbackVersion 将平台某个渠道回滚到上一次发布的版本，可连续回滚。
+// 稳定渠道的发布文件链接在事务提交前同步修改并确认，链接修改失败时数据库不会变化；
+// 链接已修改但事务提交失败时，会尝试把链接改回原来的版本
+func (s *Service) RollbackVersion(platform, channel string) (*model.Version, error) {
+	if err := checkVersionChannel(channel); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	history := model.VersionPublishHistory{ID: idgen.NextId(), CreateTime: &now}
+
+	var linkedFrom *model.Version
+	target, err := s.dao.RollbackVersion(platform, channel, &history, func(current, target *model.Version) error {
+		if channel != model.VersionChannelStable {
+			return nil
+		}
+
+		if target.File == nil || *target.File == "" {
+			log.Info("该平台版本无文件，不需处理", zap.Int64("id", target.ID))
+			return nil
+		}
+
+		err := s.linkReleaseFile(target)
+		if err != nil {
+			return err
+		}
+
+		linkedFrom = current
+		return nil
+	})
+	if err != nil {
+		if linkedFrom != nil && linkedFrom.File != nil && *linkedFrom.File != "" {
+			if linkErr := s.linkReleaseFile(linkedFrom); linkErr != nil {
+				log.Error("回滚失败后恢复发布文件链接时出现错误", zap.Int64("id", linkedFrom.ID), zap.Error(linkErr))
+			}
+		}
+		return nil, err
+	}
+
+	log.Info("版本已回滚", zap.String("platform", platform), zap.String("channel", channel), zap.Int64("id", target.ID))
+	return target, nil
+}
@@ -0,0 +1,144 @@
+package service
+
+import (
+	"fmt"
+	"go.uber.org/zap"
+	"hash/fnv"
+	"wusthelper-manager-go/app/model"
+	"wusthelper-manager-go/library/ecode"
+	"wusthelper-manager-go/library/log"
+)
+
+const _fullRolloutPercent = 100
+
+// GetLatestVersionForClient 获取客户端应使用的最新版本：测试人员可以获取其所在渠道的版本，
+// 客户端落在稳定渠道灰度范围内时返回灰度中的版本，否则返回已发布的版本。设备id和学号都为空时不参与灰度
+func (s *Service) GetLatestVersionForClient(platform string, client VersionClient) (*model.Version, error) {
+	channels, err := s.getClientChannels(client)
+	if err != nil {
+		return nil, err
+	}
+
+	latest, err := s.GetLatestVersion(platform, channels...)
+	if err != nil {
+		return nil, err
+	}
+
+	// 有灰度中的版本时才需要客户端标识，避免每次检查更新都查询学号
+	rolling, err := s.dao.GetRollingVersion(platform, model.VersionChannelStable)
+	if err != nil {
+		return nil, err
+	}
+
+	if rolling == nil || (rolling.RolloutPaused != nil && *rolling.RolloutPaused) || rolling.RolloutPercent == nil {
+		return latest, nil
+	}
+
+	// 灰度版本不比已发布的版本新时没有意义
+	if latest != nil && compareSemver(parseVersionSemver(rolling), parseVersionSemver(latest)) <= 0 {
+		return latest, nil
+	}
+
+	clientId := s.clientRolloutId(client)
+	if clientId == "" || !inRolloutBucket(rolling.ID, clientId, *rolling.RolloutPercent) {
+		return latest, nil
+	}
+
+	return rolling, nil
+}
+
+// inRolloutBucket 按版本id和客户端标识哈希分桶，同一客户端对同一版本的结果固定，灰度比例调高时原来在范围内的客户端仍在范围内
+func inRolloutBucket(versionId int64, clientId string, percent int) bool {
+	hash := fnv.New32a()
+	_, _ = hash.Write([]byte(fmt.Sprintf("%d:%s", versionId, clientId)))
+	return int(hash.Sum32()%_fullRolloutPercent) < percent
+}
+
+// RolloutVersion 开始灰度发布版本或调高灰度比例，比例达到100时转为正式发布。
+// 只有稳定渠道的版本可以灰度发布，其他渠道只面向测试人员，直接发布即可
+func (s *Service) RolloutVersion(id int64, percent int) error {
+	if percent <= 0 || percent > _fullRolloutPercent {
+		return ecode.RolloutPercentWrong
+	}
+
+	version, err := s.dao.GetVersion(id)
+	if err != nil {
+		return err
+	} else if version == nil {
+		return ecode.VersionOperationFailed
+	}
+
+	if version.Channel != nil && *version.Channel != model.VersionChannelStable {
+		return ecode.ChannelInvalid
+	}
+
+	switch *version.Status {
+	case model.NormalStatus:
+		if percent == _fullRolloutPercent {
+			return s.PublishVersion(id)
+		}
+
+		count, err := s.dao.StartVersionRollout(id, percent)
+		if err != nil {
+			return err
+		} else if count == 0 {
+			return ecode.VersionOperationFailed
+		}
+	case model.VersionRollingStatus:
+		// 只能调高，调低会让已经拿到新版本的客户端又被提示旧版本
+		if version.RolloutPercent != nil && percent <= *version.RolloutPercent {
+			return ecode.RolloutPercentWrong
+		}
+
+		if percent == _fullRolloutPercent {
+			return s.PublishVersion(id)
+		}
+
+		count, err := s.dao.UpdateVersionRollout(id, &percent, nil)
+		if err != nil {
+			return err
+		} else if count == 0 {
+			return ecode.VersionNotRolling
+		}
+	default:
+		return ecode.VersionOperationFailed
+	}
+
+	log.Info("版本灰度比例已修改", zap.Int64("id", id), zap.Int("percent", percent))
+	return nil
+}
+
+// PauseVersionRollout 暂停灰度，暂停期间所有客户端都使用已发布的版本
+func (s *Service) PauseVersionRollout(id int64) error {
+	return s.setVersionRolloutPaused(id, true)
+}
+
+// ResumeVersionRollout 恢复暂停的灰度
+func (s *Service) ResumeVersionRollout(id int64) error {
+	return s.setVersionRolloutPaused(id, false)
+}
+
+func (s *Service) setVersionRolloutPaused(id int64, paused bool) error {
+	count, err := s.dao.UpdateVersionRollout(id, nil, &paused)
+	if err != nil {
+		return err
+	} else if count == 0 {
+		return ecode.VersionNotRolling
+	}
+
+	log.Info("版本灰度暂停状态已修改", zap.Int64("id", id), zap.Bool("paused", paused))
+	return nil
+}
+
+// StopVersionRollout 停止灰度，版本恢复为普通状态，所有客户端回到已发布的版本
+func (s *Service) StopVersionRollout(id int64) error {
+	count, err := s.dao.StopVersionRollout(id)
+	if err != nil {
+		return err
+	} else if count == 0 {
+		return ecode.VersionNotRolling
+	}
+
+	log.Info("版本灰度已停止", zap.Int64("id", id))
+	return nil
+}
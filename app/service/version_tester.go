@@ -0,0 +1,140 @@
+package service
+
+import (
+	"github.com/yitter/idgenerator-go/idgen"
+	"go.uber.org/zap"
+	"time"
+	"wusthelper-manager-go/app/model"
+	"wusthelper-manager-go/common"
+	"wusthelper-manager-go/library/ecode"
+	"wusthelper-manager-go/library/log"
+)
+
+// VersionClient 请求版本信息的客户端，用于匹配测试人员和灰度分桶，字段都可以为空。
+// 学号不信任客户端传的值，通过助手token从上游获取
+type VersionClient struct {
+	DeviceId string
+	Token    string // 助手token
+}
+
+// clientStudentNum 获取助手token对应的学号，没有token或token无效时返回空
+func (s *Service) clientStudentNum(client VersionClient) string {
+	if client.Token == "" {
+		return ""
+	}
+
+	profile := s.getStudentProfile(client.Token)
+	if profile == nil {
+		return ""
+	}
+
+	return profile.StudentNum
+}
+
+// clientRolloutId 灰度分桶使用的客户端标识，优先使用设备id，其次学号
+func (s *Service) clientRolloutId(client VersionClient) string {
+	if client.DeviceId != "" {
+		return client.DeviceId
+	}
+
+	return s.clientStudentNum(client)
+}
+
+// getClientChannels 获取客户端可以使用的发布渠道，测试人员可以使用其所在渠道及更稳定的渠道，其他客户端只能使用稳定渠道
+func (s *Service) getClientChannels(client VersionClient) ([]string, error) {
+	channels := []string{model.VersionChannelStable}
+
+	identifiers := make([]string, 0, 2)
+	if client.DeviceId != "" {
+		identifiers = append(identifiers, client.DeviceId)
+	}
+	// 没有测试人员时不需要通过token获取学号，避免每次检查更新都请求上游
+	if client.Token != "" {
+		hasTester, err := s.dao.HasAnyVersionTester()
+		if err != nil {
+			return nil, err
+		}
+
+		if hasTester {
+			if studentNum := s.clientStudentNum(client); studentNum != "" {
+				identifiers = append(identifiers, studentNum)
+			}
+		}
+	}
+	if len(identifiers) == 0 {
+		return channels, nil
+	}
+
+	testerChannels, err := s.dao.GetVersionTesterChannels(identifiers...)
+	if err != nil {
+		return nil, err
+	}
+
+	// 学号和设备id都匹配到时取范围更大的渠道
+	beta, internal := false, false
+	for _, channel := range testerChannels {
+		switch channel {
+		case model.VersionChannelBeta:
+			beta = true
+		case model.VersionChannelInternal:
+			internal = true
+		}
+	}
+
+	if beta || internal {
+		channels = append(channels, model.VersionChannelBeta)
+	}
+	if internal {
+		channels = append(channels, model.VersionChannelInternal)
+	}
+
+	return channels, nil
+}
+
+func (s *Service) GetVersionTesterList(pagination common.Pagination, identifier string) (*[]model.VersionTester, int64, error) {
+	return s.dao.GetVersionTesterList(pagination, identifier)
+}
+
+// AddVersionTester 添加测试人员，identifier为学号或设备id，channel只能为beta或internal
+func (s *Service) AddVersionTester(identifier, channel, remark string) error {
+	if channel != model.VersionChannelBeta && channel != model.VersionChannelInternal {
+		return ecode.ChannelInvalid
+	}
+
+	has, err := s.dao.HasVersionTester(identifier)
+	if err != nil {
+		return err
+	} else if has {
+		return ecode.TesterExists
+	}
+
+	now := time.Now()
+	tester := model.VersionTester{
+		ID:         idgen.NextId(),
+		Identifier: &identifier,
+		Channel:    &channel,
+		Remark:     &remark,
+		CreateTime: &now,
+		Status:     new(int8),
+	}
+
+	_, err = s.dao.AddVersionTester(&tester)
+	if err != nil {
+		return err
+	}
+
+	log.Info("测试人员已添加", zap.String("identifier", identifier), zap.String("channel", channel))
+	return nil
+}
+
+func (s *Service) DeleteVersionTester(id int64) error {
+	count, err := s.dao.DeleteVersionTester(id)
+	if err != nil {
+		return err
+	} else if count == 0 {
+		return ecode.QueryFailed
+	}
+
+	log.Info("测试人员已删除", zap.Int64("id", id))
+	return nil
+}
@@ -1 +1,2 @@
-GOOS=linux go build wusthelper-manager-go
+# webp编码依赖cgo（libwebp），交叉编译时需要配置对应平台的C编译器，如 CC=x86_64-linux-gnu-gcc
+CGO_ENABLED=1 GOOS=linux go build wusthelper-manager-go
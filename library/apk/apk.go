@@ -0,0 +1,41 @@
+package apk
+
+import (
+	"fmt"
+	"github.com/shogo82148/androidbinary/apk"
+	"io"
+)
+
+// Info apk的基本信息，来自二进制的AndroidManifest.xml
+type Info struct {
+	PackageName string
+	VersionName string
+	VersionCode int32
+	MinSdk      int32
+}
+
+// Parse 解析apk文件中的AndroidManifest.xml，r为整个apk文件
+func Parse(r io.ReaderAt, size int64) (*Info, error) {
+	pkg, err := apk.OpenZipReader(r, size)
+	if err != nil {
+		return nil, err
+	}
+
+	manifest := pkg.Manifest()
+	info := &Info{PackageName: pkg.PackageName()}
+	if info.VersionName, err = manifest.VersionName.String(); err != nil {
+		return nil, fmt.Errorf("解析versionName失败：%w", err)
+	}
+	if info.VersionCode, err = manifest.VersionCode.Int32(); err != nil {
+		return nil, fmt.Errorf("解析versionCode失败：%w", err)
+	}
+	if info.MinSdk, err = manifest.SDK.Min.Int32(); err != nil {
+		return nil, fmt.Errorf("解析minSdkVersion失败：%w", err)
+	}
+
+	if info.PackageName == "" {
+		return nil, fmt.Errorf("AndroidManifest.xml中缺少包名")
+	}
+
+	return info, nil
+}
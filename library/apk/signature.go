@@ -0,0 +1,429 @@
+package apk
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/subtle"
+	"crypto/x509"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// 参考 https://source.android.com/docs/security/features/apksigning/v2
+
+const (
+	_eocdSignature      = 0x06054b50
+	_eocdMinSize        = 22
+	_eocdMaxCommentSize = 0xffff
+
+	_signingBlockMagic        = "APK Sig Block 42"
+	_signingBlockFooterSize   = 24 // uint64 size + 16字节magic
+	_signatureSchemeV2BlockId = 0x7109871a
+
+	_contentDigestChunkSize = 1024 * 1024
+)
+
+var ErrNoSignature = errors.New("apk没有签名")
+
+// 签名算法id
+const (
+	_sigRsaPssSha256      = 0x0101
+	_sigRsaPssSha512      = 0x0102
+	_sigRsaPkcs1v15Sha256 = 0x0103
+	_sigRsaPkcs1v15Sha512 = 0x0104
+	_sigEcdsaSha256       = 0x0201
+	_sigEcdsaSha512       = 0x0202
+)
+
+// SignerFingerprint 签名证书的sha256指纹，小写十六进制
+func SignerFingerprint(cert *x509.Certificate) string {
+	return fmt.Sprintf("%x", sha256.Sum256(cert.Raw))
+}
+
+// VerifySignature 校验apk签名，返回签名者的证书。
+// 有v2签名块时只校验v2签名（与android 7.0及以上的行为一致），否则校验v1（jar）签名
+func VerifySignature(r io.ReaderAt, size int64) ([]*x509.Certificate, error) {
+	certs, err := verifyV2(r, size)
+	if err == nil {
+		return certs, nil
+	} else if !errors.Is(err, ErrNoSignature) {
+		return nil, fmt.Errorf("v2签名校验失败：%w", err)
+	}
+
+	certs, err = verifyV1(r, size)
+	if err != nil && !errors.Is(err, ErrNoSignature) {
+		return nil, fmt.Errorf("v1签名校验失败：%w", err)
+	}
+
+	return certs, err
+}
+
+type zipSections struct {
+	cdOffset   int64
+	cdSize     int64
+	eocdOffset int64
+	eocd       []byte
+}
+
+func findZipSections(r io.ReaderAt, size int64) (*zipSections, error) {
+	tailSize := int64(_eocdMinSize + _eocdMaxCommentSize)
+	if tailSize > size {
+		tailSize = size
+	}
+
+	tail := make([]byte, tailSize)
+	if _, err := r.ReadAt(tail, size-tailSize); err != nil {
+		return nil, err
+	}
+
+	// 从后往前找eocd，注释长度需要刚好对上
+	for i := len(tail) - _eocdMinSize; i >= 0; i-- {
+		if binary.LittleEndian.Uint32(tail[i:]) != _eocdSignature {
+			continue
+		}
+
+		commentSize := int(binary.LittleEndian.Uint16(tail[i+20:]))
+		if i+_eocdMinSize+commentSize != len(tail) {
+			continue
+		}
+
+		sections := &zipSections{
+			cdSize:     int64(binary.LittleEndian.Uint32(tail[i+12:])),
+			cdOffset:   int64(binary.LittleEndian.Uint32(tail[i+16:])),
+			eocdOffset: size - tailSize + int64(i),
+			eocd:       tail[i:],
+		}
+		if sections.cdOffset+sections.cdSize != sections.eocdOffset {
+			return nil, errors.New("zip中央目录位置不正确")
+		}
+
+		return sections, nil
+	}
+
+	return nil, errors.New("不是有效的zip文件")
+}
+
+// findSigningBlock 查找中央目录前的APK签名块，返回签名块起始位置和其中的键值对
+func findSigningBlock(r io.ReaderAt, sections *zipSections) (int64, map[uint32][]byte, error) {
+	if sections.cdOffset < _signingBlockFooterSize {
+		return 0, nil, ErrNoSignature
+	}
+
+	footer := make([]byte, _signingBlockFooterSize)
+	if _, err := r.ReadAt(footer, sections.cdOffset-_signingBlockFooterSize); err != nil {
+		return 0, nil, err
+	}
+	if string(footer[8:]) != _signingBlockMagic {
+		return 0, nil, ErrNoSignature
+	}
+
+	blockSize := binary.LittleEndian.Uint64(footer)
+	if blockSize < _signingBlockFooterSize || blockSize > uint64(sections.cdOffset-8) {
+		return 0, nil, errors.New("签名块大小不正确")
+	}
+
+	blockOffset := sections.cdOffset - int64(blockSize) - 8
+	block := make([]byte, blockSize+8)
+	if _, err := r.ReadAt(block, blockOffset); err != nil {
+		return 0, nil, err
+	}
+	if binary.LittleEndian.Uint64(block) != blockSize {
+		return 0, nil, errors.New("签名块头尾大小不一致")
+	}
+
+	pairs := make(map[uint32][]byte)
+	data := block[8 : len(block)-_signingBlockFooterSize]
+	for len(data) > 0 {
+		if len(data) < 8 {
+			return 0, nil, errors.New("签名块格式不正确")
+		}
+
+		pairSize := binary.LittleEndian.Uint64(data)
+		data = data[8:]
+		if pairSize < 4 || pairSize > uint64(len(data)) {
+			return 0, nil, errors.New("签名块格式不正确")
+		}
+
+		pairs[binary.LittleEndian.Uint32(data)] = data[4:pairSize]
+		data = data[pairSize:]
+	}
+
+	return blockOffset, pairs, nil
+}
+
+func verifyV2(r io.ReaderAt, size int64) ([]*x509.Certificate, error) {
+	sections, err := findZipSections(r, size)
+	if err != nil {
+		return nil, err
+	}
+
+	blockOffset, pairs, err := findSigningBlock(r, sections)
+	if err != nil {
+		return nil, err
+	}
+
+	v2Block, ok := pairs[_signatureSchemeV2BlockId]
+	if !ok {
+		return nil, ErrNoSignature
+	}
+
+	signers, err := readLengthPrefixed(&v2Block)
+	if err != nil {
+		return nil, err
+	}
+
+	// 同一种摘要只计算一次
+	contentDigests := make(map[crypto.Hash][]byte)
+	certs := make([]*x509.Certificate, 0, 1)
+	for len(signers) > 0 {
+		signer, err := readLengthPrefixed(&signers)
+		if err != nil {
+			return nil, err
+		}
+
+		cert, digestHash, digest, err := verifyV2Signer(signer)
+		if err != nil {
+			return nil, err
+		}
+
+		computed, ok := contentDigests[digestHash]
+		if !ok {
+			computed, err = computeContentDigest(r, sections, blockOffset, digestHash)
+			if err != nil {
+				return nil, err
+			}
+			contentDigests[digestHash] = computed
+		}
+
+		if subtle.ConstantTimeCompare(computed, digest) != 1 {
+			return nil, errors.New("apk内容摘要不匹配")
+		}
+
+		certs = append(certs, cert)
+	}
+
+	if len(certs) == 0 {
+		return nil, errors.New("v2签名块中没有签名者")
+	}
+
+	return certs, nil
+}
+
+// verifyV2Signer 校验单个签名者对signed data的签名，返回证书和需要比对的apk内容摘要
+func verifyV2Signer(signer []byte) (*x509.Certificate, crypto.Hash, []byte, error) {
+	signedData, err := readLengthPrefixed(&signer)
+	if err != nil {
+		return nil, 0, nil, err
+	}
+	signatures, err := readLengthPrefixed(&signer)
+	if err != nil {
+		return nil, 0, nil, err
+	}
+	publicKeyData, err := readLengthPrefixed(&signer)
+	if err != nil {
+		return nil, 0, nil, err
+	}
+
+	publicKey, err := x509.ParsePKIXPublicKey(publicKeyData)
+	if err != nil {
+		return nil, 0, nil, fmt.Errorf("解析公钥失败：%w", err)
+	}
+
+	// 选择支持的最强的签名算法校验
+	bestAlgorithm, bestSignature := uint32(0), []byte(nil)
+	for len(signatures) > 0 {
+		signature, err := readLengthPrefixed(&signatures)
+		if err != nil {
+			return nil, 0, nil, err
+		}
+		if len(signature) < 4 {
+			return nil, 0, nil, errors.New("签名格式不正确")
+		}
+
+		algorithm := binary.LittleEndian.Uint32(signature)
+		if _, ok := signatureAlgorithmHash(algorithm); !ok {
+			continue
+		}
+
+		if bestSignature == nil || compareSignatureAlgorithm(algorithm, bestAlgorithm) > 0 {
+			signature = signature[4:]
+			bestSignature, err = readLengthPrefixed(&signature)
+			if err != nil {
+				return nil, 0, nil, err
+			}
+			bestAlgorithm = algorithm
+		}
+	}
+	if bestSignature == nil {
+		return nil, 0, nil, errors.New("没有支持的签名算法")
+	}
+
+	err = verifySignatureData(bestAlgorithm, publicKey, signedData, bestSignature)
+	if err != nil {
+		return nil, 0, nil, err
+	}
+
+	digests, err := readLengthPrefixed(&signedData)
+	if err != nil {
+		return nil, 0, nil, err
+	}
+	certificates, err := readLengthPrefixed(&signedData)
+	if err != nil {
+		return nil, 0, nil, err
+	}
+
+	var digest []byte
+	for len(digests) > 0 {
+		item, err := readLengthPrefixed(&digests)
+		if err != nil {
+			return nil, 0, nil, err
+		}
+		if len(item) < 4 {
+			return nil, 0, nil, errors.New("摘要格式不正确")
+		}
+
+		if binary.LittleEndian.Uint32(item) == bestAlgorithm {
+			item = item[4:]
+			digest, err = readLengthPrefixed(&item)
+			if err != nil {
+				return nil, 0, nil, err
+			}
+		}
+	}
+	if digest == nil {
+		return nil, 0, nil, errors.New("签名算法没有对应的内容摘要")
+	}
+
+	certData, err := readLengthPrefixed(&certificates)
+	if err != nil {
+		return nil, 0, nil, errors.New("签名者没有证书")
+	}
+	cert, err := x509.ParseCertificate(certData)
+	if err != nil {
+		return nil, 0, nil, fmt.Errorf("解析证书失败：%w", err)
+	}
+	if !bytes.Equal(cert.RawSubjectPublicKeyInfo, publicKeyData) {
+		return nil, 0, nil, errors.New("证书公钥与签名公钥不一致")
+	}
+
+	digestHash, _ := signatureAlgorithmHash(bestAlgorithm)
+	return cert, digestHash, digest, nil
+}
+
+func signatureAlgorithmHash(algorithm uint32) (crypto.Hash, bool) {
+	switch algorithm {
+	case _sigRsaPssSha256, _sigRsaPkcs1v15Sha256, _sigEcdsaSha256:
+		return crypto.SHA256, true
+	case _sigRsaPssSha512, _sigRsaPkcs1v15Sha512, _sigEcdsaSha512:
+		return crypto.SHA512, true
+	default:
+		return 0, false
+	}
+}
+
+// compareSignatureAlgorithm 摘要更长的算法更强
+func compareSignatureAlgorithm(a, b uint32) int {
+	hashA, _ := signatureAlgorithmHash(a)
+	hashB, _ := signatureAlgorithmHash(b)
+	return hashA.Size() - hashB.Size()
+}
+
+func verifySignatureData(algorithm uint32, publicKey any, data, signature []byte) error {
+	digestHash, _ := signatureAlgorithmHash(algorithm)
+	hash := digestHash.New()
+	hash.Write(data)
+	hashed := hash.Sum(nil)
+
+	switch algorithm {
+	case _sigRsaPssSha256, _sigRsaPssSha512:
+		key, ok := publicKey.(*rsa.PublicKey)
+		if !ok {
+			return errors.New("公钥类型与签名算法不匹配")
+		}
+		return rsa.VerifyPSS(key, digestHash, hashed, signature, &rsa.PSSOptions{SaltLength: digestHash.Size()})
+	case _sigRsaPkcs1v15Sha256, _sigRsaPkcs1v15Sha512:
+		key, ok := publicKey.(*rsa.PublicKey)
+		if !ok {
+			return errors.New("公钥类型与签名算法不匹配")
+		}
+		return rsa.VerifyPKCS1v15(key, digestHash, hashed, signature)
+	case _sigEcdsaSha256, _sigEcdsaSha512:
+		key, ok := publicKey.(*ecdsa.PublicKey)
+		if !ok {
+			return errors.New("公钥类型与签名算法不匹配")
+		}
+		if !ecdsa.VerifyASN1(key, hashed, signature) {
+			return errors.New("签名不正确")
+		}
+		return nil
+	default:
+		return errors.New("不支持的签名算法")
+	}
+}
+
+// computeContentDigest 按v2签名的规则计算apk内容摘要：zip条目、中央目录、eocd三部分按1MB分块摘要后再整体摘要
+func computeContentDigest(r io.ReaderAt, sections *zipSections, blockOffset int64, digestHash crypto.Hash) ([]byte, error) {
+	// eocd中的中央目录偏移量按签名块的起始位置计算
+	eocd := make([]byte, len(sections.eocd))
+	copy(eocd, sections.eocd)
+	binary.LittleEndian.PutUint32(eocd[16:], uint32(blockOffset))
+
+	contents := []*io.SectionReader{
+		io.NewSectionReader(r, 0, blockOffset),
+		io.NewSectionReader(r, sections.cdOffset, sections.cdSize),
+		io.NewSectionReader(bytes.NewReader(eocd), 0, int64(len(eocd))),
+	}
+
+	chunkDigests := make([]byte, 0)
+	chunkCount := uint32(0)
+	chunk := make([]byte, _contentDigestChunkSize)
+	prefix := make([]byte, 5)
+	for _, content := range contents {
+		for {
+			n, err := io.ReadFull(content, chunk)
+			if n > 0 {
+				prefix[0] = 0xa5
+				binary.LittleEndian.PutUint32(prefix[1:], uint32(n))
+				hash := digestHash.New()
+				hash.Write(prefix)
+				hash.Write(chunk[:n])
+				chunkDigests = hash.Sum(chunkDigests)
+				chunkCount++
+			}
+
+			if err == io.EOF || err == io.ErrUnexpectedEOF {
+				break
+			} else if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	prefix[0] = 0x5a
+	binary.LittleEndian.PutUint32(prefix[1:], chunkCount)
+	hash := digestHash.New()
+	hash.Write(prefix)
+	hash.Write(chunkDigests)
+	return hash.Sum(nil), nil
+}
+
+// readLengthPrefixed 读取一个uint32长度前缀的数据，并移动data
+func readLengthPrefixed(data *[]byte) ([]byte, error) {
+	if len(*data) < 4 {
+		return nil, errors.New("签名数据格式不正确")
+	}
+
+	size := binary.LittleEndian.Uint32(*data)
+	if uint64(size) > uint64(len(*data)-4) {
+		return nil, errors.New("签名数据格式不正确")
+	}
+
+	value := (*data)[4 : 4+size]
+	*data = (*data)[4+size:]
+	return value, nil
+}
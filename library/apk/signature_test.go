@@ -0,0 +1,332 @@
+package apk
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"go.mozilla.org/pkcs7"
+	"math/big"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+// 测试用的apk只是普通的zip，签名按v1（jar）和v2的格式在测试中生成，不依赖apksigner
+
+type testSigner struct {
+	key  *rsa.PrivateKey
+	cert *x509.Certificate
+}
+
+func newTestSigner(t *testing.T, name string) *testSigner {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour * 24 * 365),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &testSigner{key: key, cert: cert}
+}
+
+var testApkFiles = map[string]string{
+	"AndroidManifest.xml": "manifest",
+	"classes.dex":         "dex-content-0001",
+	"res/layout/main.xml": strings.Repeat("layout", 100),
+}
+
+// buildZip 按文件名顺序写入，内容不压缩，方便篡改测试直接修改字节
+func buildZip(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	buf := new(bytes.Buffer)
+	w := zip.NewWriter(buf)
+	for _, name := range names {
+		f, err := w.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Store})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err = f.Write([]byte(files[name])); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	return buf.Bytes()
+}
+
+// v1Files 生成jar签名后的文件列表，signed为需要写入MANIFEST.MF的文件
+func v1Files(t *testing.T, signer *testSigner, signed map[string]string) map[string]string {
+	t.Helper()
+
+	names := make([]string, 0, len(signed))
+	for name := range signed {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	manifest := "Manifest-Version: 1.0\r\nCreated-By: test\r\n\r\n"
+	for _, name := range names {
+		digest := sha256.Sum256([]byte(signed[name]))
+		manifest += fmt.Sprintf("Name: %s\r\nSHA-256-Digest: %s\r\n\r\n", name, base64.StdEncoding.EncodeToString(digest[:]))
+	}
+
+	manifestDigest := sha256.Sum256([]byte(manifest))
+	sf := fmt.Sprintf("Signature-Version: 1.0\r\nSHA-256-Digest-Manifest: %s\r\nCreated-By: test\r\n\r\n",
+		base64.StdEncoding.EncodeToString(manifestDigest[:]))
+
+	signedData, err := pkcs7.NewSignedData([]byte(sf))
+	if err != nil {
+		t.Fatal(err)
+	}
+	signedData.SetDigestAlgorithm(pkcs7.OIDDigestAlgorithmSHA256)
+	if err = signedData.AddSigner(signer.cert, signer.key, pkcs7.SignerInfoConfig{}); err != nil {
+		t.Fatal(err)
+	}
+	signedData.Detach()
+	block, err := signedData.Finish()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files := make(map[string]string, len(signed)+3)
+	for name, content := range signed {
+		files[name] = content
+	}
+	files[_manifestName] = manifest
+	files["META-INF/CERT.SF"] = sf
+	files["META-INF/CERT.RSA"] = string(block)
+
+	return files
+}
+
+func lengthPrefixed(data ...[]byte) []byte {
+	result := make([]byte, 0)
+	for _, item := range data {
+		result = binary.LittleEndian.AppendUint32(result, uint32(len(item)))
+		result = append(result, item...)
+	}
+
+	return result
+}
+
+// testContentDigest 按v2签名的规则独立计算内容摘要，cdOffset为签名块插入前中央目录的位置
+func testContentDigest(zipData []byte, cdOffset, eocdOffset int) []byte {
+	chunkDigests, chunkCount := make([]byte, 0), uint32(0)
+	for _, content := range [][]byte{zipData[:cdOffset], zipData[cdOffset:eocdOffset], zipData[eocdOffset:]} {
+		for len(content) > 0 {
+			n := min(len(content), _contentDigestChunkSize)
+			prefix := binary.LittleEndian.AppendUint32([]byte{0xa5}, uint32(n))
+			digest := sha256.Sum256(append(prefix, content[:n]...))
+			chunkDigests = append(chunkDigests, digest[:]...)
+			chunkCount++
+			content = content[n:]
+		}
+	}
+
+	prefix := binary.LittleEndian.AppendUint32([]byte{0x5a}, chunkCount)
+	digest := sha256.Sum256(append(prefix, chunkDigests...))
+	return digest[:]
+}
+
+// signV2 在中央目录前插入v2签名块，zipData不能有注释
+func signV2(t *testing.T, signer *testSigner, zipData []byte) []byte {
+	t.Helper()
+
+	eocdOffset := len(zipData) - _eocdMinSize
+	if binary.LittleEndian.Uint32(zipData[eocdOffset:]) != _eocdSignature {
+		t.Fatal("测试zip的eocd位置不正确")
+	}
+	cdOffset := int(binary.LittleEndian.Uint32(zipData[eocdOffset+16:]))
+
+	digest := testContentDigest(zipData, cdOffset, eocdOffset)
+	algorithm := binary.LittleEndian.AppendUint32(nil, _sigRsaPkcs1v15Sha256)
+	signedData := lengthPrefixed(
+		lengthPrefixed(append(algorithm, lengthPrefixed(digest)...)),
+		lengthPrefixed(signer.cert.Raw),
+		nil,
+	)
+
+	hashed := sha256.Sum256(signedData)
+	signature, err := rsa.SignPKCS1v15(rand.Reader, signer.key, crypto.SHA256, hashed[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signerData := lengthPrefixed(
+		signedData,
+		lengthPrefixed(append(algorithm, lengthPrefixed(signature)...)),
+		signer.cert.RawSubjectPublicKeyInfo,
+	)
+	value := lengthPrefixed(lengthPrefixed(signerData))
+
+	pairs := binary.LittleEndian.AppendUint64(nil, uint64(4+len(value)))
+	pairs = binary.LittleEndian.AppendUint32(pairs, _signatureSchemeV2BlockId)
+	pairs = append(pairs, value...)
+
+	blockSize := uint64(len(pairs) + _signingBlockFooterSize)
+	block := binary.LittleEndian.AppendUint64(nil, blockSize)
+	block = append(block, pairs...)
+	block = binary.LittleEndian.AppendUint64(block, blockSize)
+	block = append(block, _signingBlockMagic...)
+
+	result := make([]byte, 0, len(zipData)+len(block))
+	result = append(result, zipData[:cdOffset]...)
+	result = append(result, block...)
+	result = append(result, zipData[cdOffset:]...)
+	binary.LittleEndian.PutUint32(result[len(result)-_eocdMinSize+16:], uint32(cdOffset+len(block)))
+
+	return result
+}
+
+// replaceOnce 修改第一处出现的一段字节，长度不变，zip结构仍然有效
+func replaceOnce(t *testing.T, data []byte, old, new string) []byte {
+	t.Helper()
+	return replaceAt(t, data, bytes.Index(data, []byte(old)), old, new)
+}
+
+// replaceLast 修改最后一处出现的一段字节，用于修改中央目录中的文件名
+func replaceLast(t *testing.T, data []byte, old, new string) []byte {
+	t.Helper()
+	return replaceAt(t, data, bytes.LastIndex(data, []byte(old)), old, new)
+}
+
+func replaceAt(t *testing.T, data []byte, i int, old, new string) []byte {
+	t.Helper()
+
+	if i < 0 || len(old) != len(new) {
+		t.Fatalf("测试数据中找不到%q", old)
+	}
+
+	result := bytes.Clone(data)
+	copy(result[i:], new)
+	return result
+}
+
+func TestVerifySignature(t *testing.T) {
+	signer, other := newTestSigner(t, "release"), newTestSigner(t, "other")
+
+	v1Apk := buildZip(t, v1Files(t, signer, testApkFiles))
+	v2Apk := signV2(t, signer, buildZip(t, testApkFiles))
+	v1v2Apk := signV2(t, signer, v1Apk)
+
+	// v1签名中漏掉一个文件
+	partial := make(map[string]string)
+	for name, content := range testApkFiles {
+		if name != "classes.dex" {
+			partial[name] = content
+		}
+	}
+	unsignedEntry := v1Files(t, signer, partial)
+	unsignedEntry["classes.dex"] = testApkFiles["classes.dex"]
+
+	// 签名后修改文件内容和MANIFEST.MF，重新打包，zip的crc是正确的，只能靠签名中的摘要发现
+	tamperedEntry := v1Files(t, signer, testApkFiles)
+	tamperedEntry["classes.dex"] = "dex-content-0002"
+	tamperedManifest := v1Files(t, signer, testApkFiles)
+	tamperedManifest[_manifestName] = strings.Replace(tamperedManifest[_manifestName], "Created-By: test", "Created-By: evil", 1)
+
+	// 用其他证书的公钥替换签名者的证书，签名校验不通过
+	mismatched := &testSigner{key: signer.key, cert: other.cert}
+
+	cases := []struct {
+		name    string
+		data    []byte
+		want    *x509.Certificate
+		wantErr error // 为nil时只要求有错误
+	}{
+		{name: "v1", data: v1Apk, want: signer.cert},
+		{name: "v2", data: v2Apk, want: signer.cert},
+		{name: "v1和v2", data: v1v2Apk, want: signer.cert},
+		{name: "没有签名", data: buildZip(t, testApkFiles), wantErr: ErrNoSignature},
+		{name: "不是zip", data: []byte("not a zip file at all, definitely not"), wantErr: nil},
+		{name: "v1篡改文件", data: buildZip(t, tamperedEntry)},
+		{name: "v1篡改MANIFEST.MF", data: buildZip(t, tamperedManifest)},
+		{name: "v1篡改文件但crc不对", data: replaceOnce(t, v1Apk, "dex-content-0001", "dex-content-0002")},
+		{name: "v1有未签名的文件", data: buildZip(t, unsignedEntry)},
+		{name: "v2篡改文件", data: replaceOnce(t, v2Apk, "dex-content-0001", "dex-content-0002")},
+		{name: "v2篡改中央目录", data: replaceLast(t, v2Apk, "res/layout/main.xml", "res/layout/mai0.xml")},
+		{name: "v2证书与签名不一致", data: signV2(t, mismatched, buildZip(t, testApkFiles))},
+		// v2校验失败时不回退到v1，避免去掉v2签名内容后用v1绕过
+		{name: "v1和v2时篡改文件", data: replaceOnce(t, v1v2Apk, "dex-content-0001", "dex-content-0002")},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			certs, err := VerifySignature(bytes.NewReader(c.data), int64(len(c.data)))
+			if c.want == nil {
+				if err == nil {
+					t.Fatalf("期望校验失败，但返回了%d个证书", len(certs))
+				}
+				if c.wantErr != nil && !errors.Is(err, c.wantErr) {
+					t.Fatalf("错误为%v，期望%v", err, c.wantErr)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("校验出现错误：%v", err)
+			}
+			if len(certs) != 1 || !certs[0].Equal(c.want) {
+				t.Fatalf("返回的证书不正确：%v", certs)
+			}
+		})
+	}
+}
+
+func TestVerifySignatureSignerChanged(t *testing.T) {
+	signer, other := newTestSigner(t, "release"), newTestSigner(t, "other")
+
+	data := signV2(t, other, buildZip(t, testApkFiles))
+	certs, err := VerifySignature(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("校验出现错误：%v", err)
+	}
+
+	if SignerFingerprint(certs[0]) == SignerFingerprint(signer.cert) {
+		t.Fatal("不同证书的指纹不应该相同")
+	}
+}
+
+func TestSignerFingerprint(t *testing.T) {
+	signer := newTestSigner(t, "release")
+
+	digest := sha256.Sum256(signer.cert.Raw)
+	want := fmt.Sprintf("%x", digest)
+	if got := SignerFingerprint(signer.cert); got != want || len(got) != 64 {
+		t.Fatalf("SignerFingerprint = %q，期望 %q", got, want)
+	}
+}
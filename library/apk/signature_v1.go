@@ -0,0 +1,233 @@
+package apk
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"go.mozilla.org/pkcs7"
+	"hash"
+	"io"
+	"path"
+	"strings"
+)
+
+const _manifestName = "META-INF/MANIFEST.MF"
+
+// verifyV1 校验jar签名：签名块校验.SF文件，.SF校验MANIFEST.MF，MANIFEST.MF校验每个文件
+func verifyV1(r io.ReaderAt, size int64) ([]*x509.Certificate, error) {
+	zipReader, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, err
+	}
+
+	files := make(map[string]*zip.File, len(zipReader.File))
+	signatureBlocks := make([]string, 0, 1)
+	for _, file := range zipReader.File {
+		files[file.Name] = file
+		if isSignatureBlockFile(file.Name) {
+			signatureBlocks = append(signatureBlocks, file.Name)
+		}
+	}
+	if len(signatureBlocks) == 0 {
+		return nil, ErrNoSignature
+	}
+
+	manifestFile, ok := files[_manifestName]
+	if !ok {
+		return nil, errors.New("缺少MANIFEST.MF")
+	}
+	manifest, err := readZipFile(manifestFile)
+	if err != nil {
+		return nil, err
+	}
+
+	certs := make([]*x509.Certificate, 0, len(signatureBlocks))
+	for _, blockName := range signatureBlocks {
+		sfName := strings.TrimSuffix(blockName, path.Ext(blockName)) + ".SF"
+		sfFile, ok := files[sfName]
+		if !ok {
+			return nil, fmt.Errorf("缺少签名文件：%s", sfName)
+		}
+
+		cert, err := verifySignatureFile(blockName, files[blockName], sfFile, manifest)
+		if err != nil {
+			return nil, err
+		}
+		certs = append(certs, cert)
+	}
+
+	sections := parseManifest(manifest)
+	entries := make(map[string]map[string]string, len(sections))
+	for _, section := range sections[1:] {
+		entries[section["Name"]] = section
+	}
+
+	for _, file := range zipReader.File {
+		if strings.HasSuffix(file.Name, "/") || file.Name == _manifestName ||
+			isSignatureBlockFile(file.Name) || isSignatureFile(file.Name) {
+			continue
+		}
+
+		entry, ok := entries[file.Name]
+		if !ok {
+			return nil, fmt.Errorf("文件未签名：%s", file.Name)
+		}
+
+		err = verifyEntryDigest(file, entry)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return certs, nil
+}
+
+func verifySignatureFile(blockName string, blockFile, sfFile *zip.File, manifest []byte) (*x509.Certificate, error) {
+	block, err := readZipFile(blockFile)
+	if err != nil {
+		return nil, err
+	}
+	sf, err := readZipFile(sfFile)
+	if err != nil {
+		return nil, err
+	}
+
+	p7, err := pkcs7.Parse(block)
+	if err != nil {
+		return nil, fmt.Errorf("解析签名块%s失败：%w", blockName, err)
+	}
+
+	p7.Content = sf
+	if err = p7.Verify(); err != nil {
+		return nil, fmt.Errorf("签名块%s校验失败：%w", blockName, err)
+	}
+
+	cert := p7.GetOnlySigner()
+	if cert == nil {
+		return nil, fmt.Errorf("签名块%s的签名者不唯一", blockName)
+	}
+
+	// 只支持对整个MANIFEST.MF的摘要，apksigner和jarsigner都会生成
+	mainSection := parseManifest(sf)[0]
+	if !matchDigest(mainSection, "-Digest-Manifest", manifest) {
+		return nil, fmt.Errorf("%s中的MANIFEST.MF摘要不匹配", sfFile.Name)
+	}
+
+	return cert, nil
+}
+
+func verifyEntryDigest(file *zip.File, entry map[string]string) error {
+	expected, h, ok := digestOf(entry, "-Digest")
+	if !ok {
+		return fmt.Errorf("文件没有支持的摘要：%s", file.Name)
+	}
+
+	rc, err := file.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	if _, err = io.Copy(h, rc); err != nil {
+		return err
+	}
+
+	if base64.StdEncoding.EncodeToString(h.Sum(nil)) != expected {
+		return fmt.Errorf("文件摘要不匹配：%s", file.Name)
+	}
+
+	return nil
+}
+
+// matchDigest 校验data的摘要是否与section中记录的一致
+func matchDigest(section map[string]string, suffix string, data []byte) bool {
+	expected, h, ok := digestOf(section, suffix)
+	if !ok {
+		return false
+	}
+
+	h.Write(data)
+	return base64.StdEncoding.EncodeToString(h.Sum(nil)) == expected
+}
+
+// digestOf 获取section中 SHA-256{suffix} 或 SHA1{suffix} 属性记录的摘要，以及对应的hash
+func digestOf(section map[string]string, suffix string) (string, hash.Hash, bool) {
+	if expected, ok := section["SHA-256"+suffix]; ok {
+		return expected, sha256.New(), true
+	} else if expected, ok = section["SHA1"+suffix]; ok {
+		return expected, sha1.New(), true
+	}
+
+	return "", nil, false
+}
+
+// parseManifest 解析MANIFEST.MF和.SF，第一个section为主section，之后为各文件的section
+func parseManifest(data []byte) []map[string]string {
+	sections := []map[string]string{make(map[string]string)}
+	current, lastKey := sections[0], ""
+
+	data = bytes.ReplaceAll(data, []byte("\r\n"), []byte("\n"))
+	for _, line := range strings.Split(string(data), "\n") {
+		if line == "" {
+			if len(current) > 0 {
+				current, lastKey = make(map[string]string), ""
+				sections = append(sections, current)
+			}
+			continue
+		}
+
+		// 超过72字节的行会折行，续行以一个空格开头
+		if line[0] == ' ' {
+			if lastKey != "" {
+				current[lastKey] += line[1:]
+			}
+			continue
+		}
+
+		key, value, ok := strings.Cut(line, ": ")
+		if !ok {
+			continue
+		}
+		current[key], lastKey = value, key
+	}
+
+	// 去掉末尾空的section
+	if len(sections) > 1 && len(sections[len(sections)-1]) == 0 {
+		sections = sections[:len(sections)-1]
+	}
+
+	return sections
+}
+
+func readZipFile(file *zip.File) ([]byte, error) {
+	rc, err := file.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+
+	return io.ReadAll(rc)
+}
+
+// isSignatureBlockFile META-INF下的签名块文件
+func isSignatureBlockFile(name string) bool {
+	if path.Dir(name) != "META-INF" {
+		return false
+	}
+
+	switch strings.ToUpper(path.Ext(name)) {
+	case ".RSA", ".DSA", ".EC":
+		return true
+	default:
+		return false
+	}
+}
+
+func isSignatureFile(name string) bool {
+	return path.Dir(name) == "META-INF" && strings.ToUpper(path.Ext(name)) == ".SF"
+}
@@ -26,9 +26,49 @@ var (
 	ContentCannotBeEmpty      = add(20300) // 内容不能都为空
 	LogNotFound               = add(20301) // 找不到此日志
 	AnnouncementPublishFailed = add(20302) // 发布公告失败
+	BannerImgSizeInvalid      = add(20303) // 轮播图图片尺寸不符合展示位要求
+	BannerImgFormatInvalid    = add(20304) // 图片格式不支持
+	BannerImgTooLarge         = add(20305) // 图片尺寸超出限制
+	BannerSlotInvalid         = add(20306) // 轮播图展示位不存在
+	BannerScheduleInvalid     = add(20307) // 轮播图展示时间段不合法
+	AudienceRuleInvalid       = add(20308) // 投放规则不合法
+	BannerLinkInvalid         = add(20309) // 轮播图链接不合法
+
+	ExperimentInvalid     = add(20400) // 实验配置不合法
+	ExperimentRunning     = add(20401) // 实验进行中，不能修改
+	ExperimentConflict    = add(20402) // 变体已在其他进行中的实验中
+	ExperimentStatusWrong = add(20403) // 实验状态不允许该操作
 
 	AddAdminLogFailed = add(40102) // 管理端日志添加失败
 
 	VersionOperationFailed = add(50100) // 版本信息操作失败
 	ParamWrong             = add(50101) // 请求的参数不正确
+	VersionFileInvalid     = add(50102) // 版本文件无法解析
+	VersionNameMismatch    = add(50103) // 安装包中的版本号与填写的版本号不一致
+	PackageNameMismatch    = add(50104) // 安装包的包名不正确
+	ApkSignatureInvalid    = add(50105) // 安装包签名校验失败
+	ApkSignerNotTrusted    = add(50106) // 安装包的签名证书不在允许列表中
+	VersionTextInvalid     = add(50107) // 版本号格式不正确
+	VersionNotRolling      = add(50108) // 版本不在灰度发布中
+	RolloutPercentWrong    = add(50109) // 灰度比例不正确
+	NoVersionToRollback    = add(50110) // 没有可以回滚到的版本
+	ReleaseFileLinkFailed  = add(50111) // 更新发布文件链接失败
+	ChannelInvalid         = add(50112) // 发布渠道不正确
+	TesterExists           = add(50113) // 测试人员已存在
+	VersionFileNotFound    = add(50114) // 版本不存在或没有安装包
+	ArtifactTypeInvalid    = add(50115) // 版本文件类型不正确
+	ArtifactExists         = add(50116) // 版本已有该类型的文件
+	VersionPlatformLocked  = add(50117) // 已有安装包的版本不能修改平台
+	VersionChannelLocked   = add(50118) // 发布过的版本不能修改渠道
+
+	UploadSessionInvalid = add(50200) // 上传会话不存在或已过期
+	UploadPartWrong      = add(50201) // 分片参数不正确
+	UploadNotCompleted   = add(50202) // 文件分片未全部上传
+
+	BundleOperationFailed = add(50300) // 资源包操作失败
+	BundleFileInvalid     = add(50301) // 资源包文件无法解析
+	BundleNotReady        = add(50302) // 资源包文件还未上传完成
+	BundleVersionExists   = add(50303) // 资源包版本已存在
+
+	StorageGcRunning = add(50400) // 正在清理存储
 )
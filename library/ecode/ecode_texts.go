@@ -26,11 +26,51 @@ func InitEcodeText() {
 	texts[ContentCannotBeEmpty] = "内容不能都为空"
 	texts[LogNotFound] = "找不到此日志"
 	texts[AnnouncementPublishFailed] = "发布公告失败"
+	texts[BannerImgSizeInvalid] = "轮播图图片的宽高比或分辨率不符合该平台展示位的要求"
+	texts[BannerImgFormatInvalid] = "图片格式不支持，只能为jpeg、png、gif或webp"
+	texts[BannerImgTooLarge] = "图片的宽、高或像素数超出限制"
+	texts[BannerSlotInvalid] = "轮播图展示位不存在，只能为home、schedule或splash"
+	texts[BannerScheduleInvalid] = "轮播图的结束展示时间需要晚于开始展示时间"
+	texts[AudienceRuleInvalid] = "投放规则不合法，学生类型只能为undergraduate或graduate，版本号需要为语义化版本号且最低版本不能高于最高版本"
+	texts[BannerLinkInvalid] = "轮播图链接不合法，链接类型不被该平台支持，或者链接不在允许的域名、页面或路由中"
+
+	texts[ExperimentInvalid] = "实验配置不合法，需要至少两个同一平台、未删除且不重复的变体，权重需要大于0"
+	texts[ExperimentRunning] = "实验进行中，不能修改"
+	texts[ExperimentConflict] = "实验的变体已在其他进行中的实验中"
+	texts[ExperimentStatusWrong] = "实验当前的状态不允许该操作"
 
 	texts[AddAdminLogFailed] = "管理端日志添加失败"
 
 	texts[VersionOperationFailed] = "版本信息操作失败"
 	texts[ParamWrong] = "参数错误"
+	texts[VersionFileInvalid] = "版本文件无法解析"
+	texts[VersionNameMismatch] = "安装包中的版本号与填写的版本号不一致"
+	texts[PackageNameMismatch] = "安装包的包名不正确"
+	texts[ApkSignatureInvalid] = "安装包签名校验失败"
+	texts[ApkSignerNotTrusted] = "安装包的签名证书不在允许列表中"
+	texts[VersionTextInvalid] = "版本号格式不正确，需要为语义化版本号，如1.2.3"
+	texts[VersionNotRolling] = "版本不在灰度发布中"
+	texts[RolloutPercentWrong] = "灰度比例不正确"
+	texts[NoVersionToRollback] = "没有可以回滚到的版本"
+	texts[ReleaseFileLinkFailed] = "更新发布文件链接失败"
+	texts[ChannelInvalid] = "发布渠道不正确，只能为stable、beta或internal"
+	texts[TesterExists] = "该学号或设备id已经是测试人员"
+	texts[VersionFileNotFound] = "版本不存在或没有安装包"
+	texts[ArtifactTypeInvalid] = "版本文件类型不正确或与版本的平台不符"
+	texts[ArtifactExists] = "版本已有该类型的文件，需要先删除"
+	texts[VersionPlatformLocked] = "已有安装包的版本不能修改平台，需要同时上传新的安装包"
+	texts[VersionChannelLocked] = "只有未发布过的版本可以修改发布渠道"
+
+	texts[UploadSessionInvalid] = "上传会话不存在或已过期"
+	texts[UploadPartWrong] = "分片参数不正确"
+	texts[UploadNotCompleted] = "文件分片未全部上传"
+
+	texts[BundleOperationFailed] = "资源包操作失败"
+	texts[BundleFileInvalid] = "资源包文件无法解析，需要为不含非法路径的zip文件"
+	texts[BundleNotReady] = "资源包文件还未上传完成"
+	texts[BundleVersionExists] = "资源包的该版本已存在"
+
+	texts[StorageGcRunning] = "正在清理存储，请稍后再试"
 
 	Register(texts)
 }
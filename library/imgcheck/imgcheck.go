@@ -0,0 +1,92 @@
+// Package imgcheck 在解码前校验上传的图片，避免伪造格式的文件和声明了超大尺寸的“解压炸弹”耗尽内存
+package imgcheck
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/sunshineplan/imgconv"
+	_ "golang.org/x/image/webp"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+)
+
+const (
+	FormatJpeg = "jpeg"
+	FormatPng  = "png"
+	FormatGif  = "gif"
+	FormatWebp = "webp"
+)
+
+var (
+	ErrFormatNotAllowed = errors.New("图片格式不在允许列表中")
+	ErrTooLarge         = errors.New("图片尺寸超出限制")
+)
+
+// Limit 图片尺寸限制，为0的项不限制
+type Limit struct {
+	MaxWidth  int
+	MaxHeight int
+	MaxPixels int64
+}
+
+// _magics 允许的格式及其文件头
+var _magics = []struct {
+	format string
+	match  func(header []byte) bool
+}{
+	{FormatJpeg, func(header []byte) bool { return bytes.HasPrefix(header, []byte{0xFF, 0xD8, 0xFF}) }},
+	{FormatPng, func(header []byte) bool { return bytes.HasPrefix(header, []byte("\x89PNG\r\n\x1a\n")) }},
+	{FormatGif, func(header []byte) bool {
+		return bytes.HasPrefix(header, []byte("GIF87a")) || bytes.HasPrefix(header, []byte("GIF89a"))
+	}},
+	{FormatWebp, func(header []byte) bool {
+		return len(header) >= 12 && bytes.Equal(header[:4], []byte("RIFF")) && bytes.Equal(header[8:12], []byte("WEBP"))
+	}},
+}
+
+// DetectFormat 按文件头识别图片格式，不是允许的格式时返回空字符串
+func DetectFormat(header []byte) string {
+	for _, magic := range _magics {
+		if magic.match(header) {
+			return magic.format
+		}
+	}
+
+	return ""
+}
+
+// Check 只读取图片头，校验文件头与声明的格式一致，且宽高和像素数不超过限制，返回格式和尺寸
+func Check(r io.ReaderAt, size int64, limit Limit) (string, image.Config, error) {
+	header := make([]byte, 12)
+	n, _ := r.ReadAt(header, 0)
+	format := DetectFormat(header[:n])
+	if format == "" {
+		return "", image.Config{}, ErrFormatNotAllowed
+	}
+
+	config, decodedFormat, err := image.DecodeConfig(io.NewSectionReader(r, 0, size))
+	if err != nil {
+		return "", image.Config{}, err
+	} else if decodedFormat != format {
+		return "", image.Config{}, ErrFormatNotAllowed
+	}
+
+	if config.Width <= 0 || config.Height <= 0 ||
+		(limit.MaxWidth > 0 && config.Width > limit.MaxWidth) ||
+		(limit.MaxHeight > 0 && config.Height > limit.MaxHeight) ||
+		(limit.MaxPixels > 0 && int64(config.Width)*int64(config.Height) > limit.MaxPixels) {
+		return format, config, fmt.Errorf("%w：%dx%d", ErrTooLarge, config.Width, config.Height)
+	}
+
+	return format, config, nil
+}
+
+// Decode 校验通过后解码图片，按EXIF中的方向旋转为正向。
+// 解码得到的只有像素数据，重新编码保存时EXIF、GPS等元数据不会被带上
+func Decode(r io.ReaderAt, size int64) (image.Image, error) {
+	return imgconv.Decode(io.NewSectionReader(r, 0, size), imgconv.AutoOrientation(true))
+}
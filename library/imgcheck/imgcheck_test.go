@@ -0,0 +1,177 @@
+package imgcheck
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"image"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"testing"
+)
+
+func TestDetectFormat(t *testing.T) {
+	cases := []struct {
+		name   string
+		header []byte
+		want   string
+	}{
+		{"jpeg", []byte{0xFF, 0xD8, 0xFF, 0xE0}, FormatJpeg},
+		{"png", []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\x0d"), FormatPng},
+		{"gif87a", []byte("GIF87a"), FormatGif},
+		{"gif89a", []byte("GIF89a"), FormatGif},
+		{"webp", []byte("RIFF\x24\x00\x00\x00WEBP"), FormatWebp},
+		{"空文件", nil, ""},
+		{"jpeg文件头不完整", []byte{0xFF, 0xD8}, ""},
+		{"riff但不是webp", []byte("RIFF\x24\x00\x00\x00WAVE"), ""},
+		{"webp文件头不完整", []byte("RIFF\x24\x00\x00\x00WEB"), ""},
+		{"bmp", []byte("BM\x36\x00\x00\x00\x00\x00\x00\x00\x36\x00"), ""},
+		{"svg", []byte("<svg xmlns="), ""},
+	}
+
+	for _, c := range cases {
+		if got := DetectFormat(c.header); got != c.want {
+			t.Errorf("%s：DetectFormat() = %q，期望 %q", c.name, got, c.want)
+		}
+	}
+}
+
+func TestCheck(t *testing.T) {
+	pngData := encodePng(t, 40, 30)
+	jpegData := encodeJpeg(t, 40, 30)
+	gifData := encodeGif(t, 40, 30)
+
+	cases := []struct {
+		name       string
+		data       []byte
+		limit      Limit
+		wantFormat string
+		wantErr    error // 为nil时只要求返回错误，配合failed使用
+		failed     bool
+	}{
+		{name: "png", data: pngData, wantFormat: FormatPng},
+		{name: "jpeg", data: jpegData, wantFormat: FormatJpeg},
+		{name: "gif", data: gifData, wantFormat: FormatGif},
+
+		// 文件头和内容不符
+		{name: "空文件", data: nil, wantErr: ErrFormatNotAllowed, failed: true},
+		{name: "文本文件", data: []byte("hello, world"), wantErr: ErrFormatNotAllowed, failed: true},
+		{name: "bmp不在允许列表中", data: []byte("BM\x36\x00\x00\x00\x00\x00\x00\x00\x36\x00\x00\x00"), wantErr: ErrFormatNotAllowed, failed: true},
+		{name: "jpeg文件头后面不是jpeg", data: append([]byte{0xFF, 0xD8, 0xFF}, pngData...), failed: true},
+		{name: "webp文件头后面不是webp", data: []byte("RIFF\x24\x00\x00\x00WEBPVP8 garbage"), failed: true},
+		{name: "png只有文件头", data: pngData[:8], failed: true},
+		{name: "png的IHDR校验和不对", data: corruptPngIhdr(pngHeader(40, 30)), failed: true},
+
+		// 只有文件头声明了超大尺寸，不读像素就能拒绝
+		{name: "声明超大宽高的png", data: pngHeader(100000, 100000), limit: Limit{MaxPixels: 40000000}, wantFormat: FormatPng, wantErr: ErrTooLarge, failed: true},
+		{name: "声明超宽的png", data: pngHeader(1<<30, 1), limit: Limit{MaxWidth: 4096}, wantFormat: FormatPng, wantErr: ErrTooLarge, failed: true},
+		{name: "声明超高的png", data: pngHeader(1, 1<<30), limit: Limit{MaxHeight: 4096}, wantFormat: FormatPng, wantErr: ErrTooLarge, failed: true},
+		{name: "没有限制时只有文件头的png也能通过", data: pngHeader(100000, 100000), wantFormat: FormatPng},
+
+		// 限制的边界，等于限制时通过
+		{name: "宽度等于限制", data: pngData, limit: Limit{MaxWidth: 40}, wantFormat: FormatPng},
+		{name: "宽度超过限制", data: pngData, limit: Limit{MaxWidth: 39}, wantFormat: FormatPng, wantErr: ErrTooLarge, failed: true},
+		{name: "高度等于限制", data: pngData, limit: Limit{MaxHeight: 30}, wantFormat: FormatPng},
+		{name: "高度超过限制", data: pngData, limit: Limit{MaxHeight: 29}, wantFormat: FormatPng, wantErr: ErrTooLarge, failed: true},
+		{name: "像素数等于限制", data: jpegData, limit: Limit{MaxPixels: 1200}, wantFormat: FormatJpeg},
+		{name: "像素数超过限制", data: jpegData, limit: Limit{MaxPixels: 1199}, wantFormat: FormatJpeg, wantErr: ErrTooLarge, failed: true},
+		{name: "宽高都在限制内但像素数超过", data: gifData, limit: Limit{MaxWidth: 40, MaxHeight: 30, MaxPixels: 1000}, wantFormat: FormatGif, wantErr: ErrTooLarge, failed: true},
+		{name: "宽高为0", data: pngHeader(0, 30), failed: true},
+	}
+
+	for _, c := range cases {
+		format, config, err := Check(bytes.NewReader(c.data), int64(len(c.data)), c.limit)
+		if !c.failed {
+			if err != nil {
+				t.Errorf("%s：Check() 出现错误：%v", c.name, err)
+			} else if format != c.wantFormat {
+				t.Errorf("%s：Check() 格式为 %q，期望 %q", c.name, format, c.wantFormat)
+			} else if config.Width <= 0 || config.Height <= 0 {
+				t.Errorf("%s：Check() 尺寸为 %dx%d", c.name, config.Width, config.Height)
+			}
+			continue
+		}
+
+		if err == nil {
+			t.Errorf("%s：Check() 没有返回错误", c.name)
+			continue
+		}
+		if c.wantErr != nil && !errors.Is(err, c.wantErr) {
+			t.Errorf("%s：Check() 错误为 %v，期望 %v", c.name, err, c.wantErr)
+		}
+		if c.wantFormat != "" && format != c.wantFormat {
+			t.Errorf("%s：Check() 格式为 %q，期望 %q", c.name, format, c.wantFormat)
+		}
+	}
+}
+
+func TestDecode(t *testing.T) {
+	data := encodePng(t, 40, 30)
+	img, err := Decode(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("Decode() 出现错误：%v", err)
+	}
+
+	if bounds := img.Bounds(); bounds.Dx() != 40 || bounds.Dy() != 30 {
+		t.Errorf("Decode() 尺寸为 %dx%d，期望 40x30", bounds.Dx(), bounds.Dy())
+	}
+}
+
+func encodePng(t *testing.T, width, height int) []byte {
+	t.Helper()
+
+	buf := new(bytes.Buffer)
+	if err := png.Encode(buf, image.NewRGBA(image.Rect(0, 0, width, height))); err != nil {
+		t.Fatalf("生成png出现错误：%v", err)
+	}
+
+	return buf.Bytes()
+}
+
+func encodeJpeg(t *testing.T, width, height int) []byte {
+	t.Helper()
+
+	buf := new(bytes.Buffer)
+	if err := jpeg.Encode(buf, image.NewRGBA(image.Rect(0, 0, width, height)), nil); err != nil {
+		t.Fatalf("生成jpeg出现错误：%v", err)
+	}
+
+	return buf.Bytes()
+}
+
+func encodeGif(t *testing.T, width, height int) []byte {
+	t.Helper()
+
+	buf := new(bytes.Buffer)
+	if err := gif.Encode(buf, image.NewRGBA(image.Rect(0, 0, width, height)), nil); err != nil {
+		t.Fatalf("生成gif出现错误：%v", err)
+	}
+
+	return buf.Bytes()
+}
+
+// pngHeader 只有png签名和IHDR块的文件，宽高可以任意声明，用于模拟解压炸弹
+func pngHeader(width, height uint32) []byte {
+	ihdr := make([]byte, 17)
+	copy(ihdr, "IHDR")
+	binary.BigEndian.PutUint32(ihdr[4:], width)
+	binary.BigEndian.PutUint32(ihdr[8:], height)
+	ihdr[12] = 8 // 位深
+	ihdr[13] = 2 // 真彩色
+
+	buf := new(bytes.Buffer)
+	buf.WriteString("\x89PNG\r\n\x1a\n")
+	_ = binary.Write(buf, binary.BigEndian, uint32(len(ihdr)-4))
+	buf.Write(ihdr)
+	_ = binary.Write(buf, binary.BigEndian, crc32.ChecksumIEEE(ihdr))
+
+	return buf.Bytes()
+}
+
+func corruptPngIhdr(data []byte) []byte {
+	result := bytes.Clone(data)
+	result[len(result)-1] ^= 0xFF
+	return result
+}
@@ -0,0 +1,121 @@
+package ipa
+
+import (
+	"archive/zip"
+	"fmt"
+	"howett.net/plist"
+	"io"
+	"path"
+	"strings"
+)
+
+// _maxInfoPlistSize Info.plist的大小上限
+const _maxInfoPlistSize = 1 << 20
+
+// Info ipa的基本信息，来自应用包中的Info.plist
+type Info struct {
+	BundleId      string // CFBundleIdentifier
+	ShortVersion  string // CFBundleShortVersionString，即展示给用户的版本号
+	BundleVersion string // CFBundleVersion，即构建号
+	Title         string // CFBundleDisplayName，没有时为CFBundleName
+}
+
+type infoPlist struct {
+	BundleId      string `plist:"CFBundleIdentifier"`
+	ShortVersion  string `plist:"CFBundleShortVersionString"`
+	BundleVersion string `plist:"CFBundleVersion"`
+	DisplayName   string `plist:"CFBundleDisplayName"`
+	Name          string `plist:"CFBundleName"`
+}
+
+// Parse 解析ipa文件中 Payload/xxx.app/Info.plist，r为整个ipa文件，支持xml和二进制格式的plist
+func Parse(r io.ReaderAt, size int64) (*Info, error) {
+	reader, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, err
+	}
+
+	var infoFile *zip.File
+	for _, file := range reader.File {
+		dir, name := path.Split(file.Name)
+		if name == "Info.plist" && strings.HasPrefix(dir, "Payload/") &&
+			strings.Count(dir, "/") == 2 && strings.HasSuffix(dir, ".app/") {
+			infoFile = file
+			break
+		}
+	}
+	if infoFile == nil {
+		return nil, fmt.Errorf("ipa中没有找到Payload/*.app/Info.plist")
+	}
+
+	rc, err := infoFile.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+
+	// Info.plist正常只有几KB，限制读取大小，避免压缩炸弹
+	data, err := io.ReadAll(io.LimitReader(rc, _maxInfoPlistSize+1))
+	if err != nil {
+		return nil, err
+	} else if len(data) > _maxInfoPlistSize {
+		return nil, fmt.Errorf("Info.plist超过%d字节", _maxInfoPlistSize)
+	}
+
+	content := new(infoPlist)
+	if _, err = plist.Unmarshal(data, content); err != nil {
+		return nil, fmt.Errorf("解析Info.plist失败：%w", err)
+	}
+
+	if content.BundleId == "" || content.ShortVersion == "" {
+		return nil, fmt.Errorf("Info.plist中缺少CFBundleIdentifier或CFBundleShortVersionString")
+	}
+
+	title := content.DisplayName
+	if title == "" {
+		title = content.Name
+	}
+
+	return &Info{
+		BundleId:      content.BundleId,
+		ShortVersion:  content.ShortVersion,
+		BundleVersion: content.BundleVersion,
+		Title:         title,
+	}, nil
+}
+
+type manifest struct {
+	Items []manifestItem `plist:"items"`
+}
+
+type manifestItem struct {
+	Assets   []manifestAsset  `plist:"assets"`
+	Metadata manifestMetadata `plist:"metadata"`
+}
+
+type manifestAsset struct {
+	Kind string `plist:"kind"`
+	Url  string `plist:"url"`
+}
+
+type manifestMetadata struct {
+	BundleIdentifier string `plist:"bundle-identifier"`
+	BundleVersion    string `plist:"bundle-version"`
+	Kind             string `plist:"kind"`
+	Title            string `plist:"title"`
+}
+
+// Manifest 生成 itms-services://?action=download-manifest 使用的manifest.plist，ipaUrl需要是https地址
+func Manifest(info *Info, ipaUrl string) ([]byte, error) {
+	return plist.MarshalIndent(manifest{
+		Items: []manifestItem{{
+			Assets: []manifestAsset{{Kind: "software-package", Url: ipaUrl}},
+			Metadata: manifestMetadata{
+				BundleIdentifier: info.BundleId,
+				BundleVersion:    info.ShortVersion,
+				Kind:             "software",
+				Title:            info.Title,
+			},
+		}},
+	}, plist.XMLFormat, "\t")
+}
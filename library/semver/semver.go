@@ -0,0 +1,166 @@
+package semver
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Version 语义化版本号，见 https://semver.org/lang/zh-CN/
+type Version struct {
+	Major uint64
+	Minor uint64
+	Patch uint64
+	Pre   string // 先行版本号，如 beta.1，正式版本为空
+	Build string // 版本编译信息，不参与比较
+}
+
+// Parse 解析形如 1.2.3、1.2.3-beta.1、1.2.3+build.5 的版本号
+func Parse(text string) (*Version, error) {
+	rest, build, hasBuild := strings.Cut(text, "+")
+	if hasBuild && !validIdentifiers(build, false) {
+		return nil, fmt.Errorf("版本号%q的编译信息不正确", text)
+	}
+
+	core, pre, hasPre := strings.Cut(rest, "-")
+	if hasPre && !validIdentifiers(pre, true) {
+		return nil, fmt.Errorf("版本号%q的先行版本号不正确", text)
+	}
+
+	parts := strings.Split(core, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("版本号%q需要为 主版本号.次版本号.修订号 的格式", text)
+	}
+
+	numbers := make([]uint64, 3)
+	for i, part := range parts {
+		if !isNumeric(part) || len(part) > 1 && part[0] == '0' {
+			return nil, fmt.Errorf("版本号%q中的%q不是合法的数字", text, part)
+		}
+
+		number, err := strconv.ParseUint(part, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("版本号%q中的%q不是合法的数字", text, part)
+		}
+		numbers[i] = number
+	}
+
+	return &Version{
+		Major: numbers[0],
+		Minor: numbers[1],
+		Patch: numbers[2],
+		Pre:   pre,
+		Build: build,
+	}, nil
+}
+
+func (v *Version) String() string {
+	text := fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+	if v.Pre != "" {
+		text += "-" + v.Pre
+	}
+	if v.Build != "" {
+		text += "+" + v.Build
+	}
+
+	return text
+}
+
+// Compare 比较两个版本号，v小于、等于、大于o时分别返回-1、0、1，编译信息不参与比较
+func (v *Version) Compare(o *Version) int {
+	if c := compareUint(v.Major, o.Major); c != 0 {
+		return c
+	}
+	if c := compareUint(v.Minor, o.Minor); c != 0 {
+		return c
+	}
+	if c := compareUint(v.Patch, o.Patch); c != 0 {
+		return c
+	}
+
+	// 有先行版本号的版本比正式版本低
+	switch {
+	case v.Pre == o.Pre:
+		return 0
+	case v.Pre == "":
+		return 1
+	case o.Pre == "":
+		return -1
+	}
+
+	return comparePre(v.Pre, o.Pre)
+}
+
+// comparePre 先行版本号按点分隔的标识符逐个比较，纯数字按数值比较且比非数字低，前面都相同时字段多的更高
+func comparePre(a, b string) int {
+	as, bs := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(as) && i < len(bs); i++ {
+		aNumeric, bNumeric := isNumeric(as[i]), isNumeric(bs[i])
+		switch {
+		case aNumeric && bNumeric:
+			// 数字没有前导零，长度长的更大
+			if c := compareUint(uint64(len(as[i])), uint64(len(bs[i]))); c != 0 {
+				return c
+			}
+			if c := strings.Compare(as[i], bs[i]); c != 0 {
+				return c
+			}
+		case aNumeric:
+			return -1
+		case bNumeric:
+			return 1
+		default:
+			if c := strings.Compare(as[i], bs[i]); c != 0 {
+				return c
+			}
+		}
+	}
+
+	return compareUint(uint64(len(as)), uint64(len(bs)))
+}
+
+func compareUint(a, b uint64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// validIdentifiers 点分隔的标识符只能包含[0-9A-Za-z-]且不能为空，先行版本号中的数字标识符不能有前导零
+func validIdentifiers(text string, noLeadingZero bool) bool {
+	for _, identifier := range strings.Split(text, ".") {
+		if identifier == "" {
+			return false
+		}
+
+		for _, c := range identifier {
+			if !(c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c == '-') {
+				return false
+			}
+		}
+
+		if noLeadingZero && isNumeric(identifier) && len(identifier) > 1 && identifier[0] == '0' {
+			return false
+		}
+	}
+
+	return true
+}
+
+func isNumeric(text string) bool {
+	if text == "" {
+		return false
+	}
+
+	for _, c := range text {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+
+	return true
+}
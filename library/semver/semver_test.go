@@ -0,0 +1,123 @@
+package semver
+
+import "testing"
+
+func TestParse(t *testing.T) {
+	cases := []struct {
+		text string
+		want Version
+	}{
+		{"0.0.0", Version{}},
+		{"1.2.3", Version{Major: 1, Minor: 2, Patch: 3}},
+		{"10.20.30", Version{Major: 10, Minor: 20, Patch: 30}},
+		{"1.2.3-beta.1", Version{Major: 1, Minor: 2, Patch: 3, Pre: "beta.1"}},
+		{"1.2.3-beta-1", Version{Major: 1, Minor: 2, Patch: 3, Pre: "beta-1"}},
+		{"1.2.3-0", Version{Major: 1, Minor: 2, Patch: 3, Pre: "0"}},
+		{"1.2.3+build.5", Version{Major: 1, Minor: 2, Patch: 3, Build: "build.5"}},
+		{"1.2.3-rc.1+001", Version{Major: 1, Minor: 2, Patch: 3, Pre: "rc.1", Build: "001"}},
+		{"18446744073709551615.0.0", Version{Major: 18446744073709551615}},
+	}
+
+	for _, c := range cases {
+		got, err := Parse(c.text)
+		if err != nil {
+			t.Errorf("Parse(%q) 出现错误：%v", c.text, err)
+			continue
+		}
+		if *got != c.want {
+			t.Errorf("Parse(%q) = %+v，期望 %+v", c.text, *got, c.want)
+		}
+		if got.String() != c.text {
+			t.Errorf("Parse(%q).String() = %q", c.text, got.String())
+		}
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	cases := []string{
+		"",
+		"1",
+		"1.2",
+		"1.2.3.4",
+		"v1.2.3",
+		"1.2.x",
+		" 1.2.3",
+		"1.2.3 ",
+		"01.2.3",
+		"1.02.3",
+		"1.2.03",
+		"-1.2.3",
+		"1..3",
+		"1.2.3-",
+		"1.2.3-beta..1",
+		"1.2.3-beta.01",
+		"1.2.3-beta_1",
+		"1.2.3+",
+		"1.2.3+build..1",
+		"1.2.3+build!",
+		"18446744073709551616.0.0",
+	}
+
+	for _, text := range cases {
+		if v, err := Parse(text); err == nil {
+			t.Errorf("Parse(%q) = %+v，期望返回错误", text, *v)
+		}
+	}
+}
+
+func TestCompare(t *testing.T) {
+	// 按semver.org中的优先级示例从低到高排列
+	ordered := []string{
+		"1.0.0-alpha",
+		"1.0.0-alpha.1",
+		"1.0.0-alpha.beta",
+		"1.0.0-beta",
+		"1.0.0-beta.2",
+		"1.0.0-beta.11",
+		"1.0.0-rc.1",
+		"1.0.0",
+		"1.0.1",
+		"1.1.0",
+		"1.10.0",
+		"2.0.0-0",
+		"2.0.0-1",
+		"2.0.0-a",
+		"2.0.0",
+		"10.0.0",
+	}
+
+	for i := range ordered {
+		for j := range ordered {
+			a, b := mustParse(t, ordered[i]), mustParse(t, ordered[j])
+			want := compareUint(uint64(i), uint64(j))
+			if got := a.Compare(b); got != want {
+				t.Errorf("%q.Compare(%q) = %d，期望 %d", ordered[i], ordered[j], got, want)
+			}
+		}
+	}
+}
+
+func TestCompareIgnoreBuild(t *testing.T) {
+	cases := [][2]string{
+		{"1.0.0+1", "1.0.0+2"},
+		{"1.0.0", "1.0.0+build"},
+		{"1.0.0-beta+1", "1.0.0-beta+exp.sha"},
+	}
+
+	for _, c := range cases {
+		if got := mustParse(t, c[0]).Compare(mustParse(t, c[1])); got != 0 {
+			t.Errorf("%q.Compare(%q) = %d，期望 0", c[0], c[1], got)
+		}
+	}
+}
+
+func mustParse(t *testing.T, text string) *Version {
+	t.Helper()
+
+	v, err := Parse(text)
+	if err != nil {
+		t.Fatalf("Parse(%q) 出现错误：%v", text, err)
+	}
+
+	return v
+}
@@ -0,0 +1,120 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"github.com/aliyun/aliyun-oss-go-sdk/oss"
+	"net/http"
+	"net/url"
+)
+
+type AliyunOssConfig struct {
+	AccessKeyId      string
+	AccessKeySecret  string
+	Endpoint         string
+	Bucket           string
+	BucketBindDomain string
+}
+
+type AliyunOss struct {
+	bucket *oss.Bucket
+	domain string
+}
+
+func NewAliyunOss(c *AliyunOssConfig) (*AliyunOss, error) {
+	client, err := oss.New(c.Endpoint, c.AccessKeyId, c.AccessKeySecret)
+	if err != nil {
+		return nil, fmt.Errorf("阿里云oss客户端初始化失败：%s", err.Error())
+	}
+
+	bucket, err := client.Bucket(c.Bucket)
+	if err != nil {
+		return nil, fmt.Errorf("阿里云oss bucket初始化失败，bucket: %s，err: %s", c.Bucket, err.Error())
+	}
+
+	// 没有绑定域名时使用bucket默认的访问域名
+	domain := c.BucketBindDomain
+	if domain == "" {
+		domain = fmt.Sprintf("%s.%s", c.Bucket, c.Endpoint)
+	}
+
+	return &AliyunOss{bucket: bucket, domain: domain}, nil
+}
+
+func (s *AliyunOss) PutFile(key, localFile, contentType string) error {
+	options := make([]oss.Option, 0, 1)
+	if contentType != "" {
+		options = append(options, oss.Meta("Content-Type", contentType))
+	}
+
+	return s.bucket.PutObjectFromFile(key, localFile, options...)
+}
+
+func (s *AliyunOss) GetFile(key, localFile string) error {
+	return s.bucket.GetObjectToFile(key, localFile)
+}
+
+func (s *AliyunOss) Hide(key string) error {
+	return s.bucket.SetObjectACL(key, oss.ACLPrivate)
+}
+
+func (s *AliyunOss) Link(linkKey, targetKey string) error {
+	return s.bucket.PutSymlink(linkKey, targetKey)
+}
+
+func (s *AliyunOss) LinkTarget(linkKey string) (string, error) {
+	header, err := s.bucket.GetSymlink(linkKey)
+	if err != nil {
+		var serviceErr oss.ServiceError
+		if errors.As(err, &serviceErr) && serviceErr.StatusCode == http.StatusNotFound {
+			return "", nil
+		}
+		return "", err
+	}
+
+	return header.Get(oss.HTTPHeaderOssSymlinkTarget), nil
+}
+
+func (s *AliyunOss) Exists(key string) (bool, error) {
+	return s.bucket.IsObjectExist(key)
+}
+
+func (s *AliyunOss) List(prefix string, fn func(object ObjectInfo) error) error {
+	token := ""
+	for {
+		result, err := s.bucket.ListObjectsV2(oss.Prefix(prefix), oss.ContinuationToken(token), oss.MaxKeys(1000))
+		if err != nil {
+			return err
+		}
+
+		for _, object := range result.Objects {
+			err = fn(ObjectInfo{Key: object.Key, Size: object.Size, LastModified: object.LastModified})
+			if err != nil {
+				return err
+			}
+		}
+
+		if !result.IsTruncated {
+			return nil
+		}
+		token = result.NextContinuationToken
+	}
+}
+
+func (s *AliyunOss) Copy(srcKey, dstKey string) error {
+	_, err := s.bucket.CopyObject(srcKey, dstKey, oss.ObjectACL(oss.ACLPrivate))
+	return err
+}
+
+func (s *AliyunOss) Delete(key string) error {
+	return s.bucket.DeleteObject(key)
+}
+
+func (s *AliyunOss) Url(key string) string {
+	u := url.URL{
+		Scheme: "https",
+		Host:   s.domain,
+	}
+
+	return u.JoinPath(key).String()
+}
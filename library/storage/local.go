@@ -0,0 +1,241 @@
+package storage
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// LocalConfig 本地文件存储配置，主要用于开发和测试环境
+// RootPath为文件存放的根目录（不能为空），BaseUrl为对外访问的地址前缀，ServePath不为空时，由本服务在该路由下提供静态文件访问
+type LocalConfig struct {
+	RootPath  string
+	BaseUrl   string
+	ServePath string
+}
+
+type Local struct {
+	root    string
+	baseUrl string
+}
+
+func NewLocal(c *LocalConfig) (*Local, error) {
+	if c.RootPath == "" {
+		return nil, fmt.Errorf("本地文件存储根目录未配置")
+	}
+
+	root := c.RootPath
+	if err := os.MkdirAll(root, 0755); err != nil {
+		return nil, fmt.Errorf("初始化本地文件存储目录失败：%s", err.Error())
+	}
+
+	return &Local{root: root, baseUrl: c.BaseUrl}, nil
+}
+
+func (s *Local) path(key string) string {
+	return filepath.Join(s.root, filepath.FromSlash(filepath.Clean("/"+key)))
+}
+
+func (s *Local) PutFile(key, localFile, _ string) error {
+	dst := s.path(key)
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+
+	src, err := os.Open(localFile)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	// 先写临时文件再重命名，避免上传一半时被读到不完整的文件
+	tmp := dst + ".uploading"
+	out, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(out, src)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+
+	return os.Rename(tmp, dst)
+}
+
+func (s *Local) GetFile(key, localFile string) error {
+	src, err := os.Open(s.path(key))
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	out, err := os.Create(localFile)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(out, src)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+
+	return err
+}
+
+// Hide 本地存储没有访问权限控制，直接删除文件
+func (s *Local) Hide(key string) error {
+	err := os.Remove(s.path(key))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	return nil
+}
+
+func (s *Local) Link(linkKey, targetKey string) error {
+	linkPath, targetPath := s.path(linkKey), s.path(targetKey)
+	if err := os.MkdirAll(filepath.Dir(linkPath), 0755); err != nil {
+		return err
+	}
+
+	target, err := filepath.Rel(filepath.Dir(linkPath), targetPath)
+	if err != nil {
+		return err
+	}
+
+	// 先建临时软链接再重命名覆盖，保证链接替换是原子的
+	tmp := linkPath + ".linking"
+	_ = os.Remove(tmp)
+	if err = os.Symlink(target, tmp); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, linkPath)
+}
+
+func (s *Local) LinkTarget(linkKey string) (string, error) {
+	linkPath := s.path(linkKey)
+	target, err := os.Readlink(linkPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", nil
+		}
+		return "", err
+	}
+
+	if !filepath.IsAbs(target) {
+		target = filepath.Join(filepath.Dir(linkPath), target)
+	}
+
+	targetKey, err := filepath.Rel(s.root, target)
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.ToSlash(targetKey), nil
+}
+
+func (s *Local) Exists(key string) (bool, error) {
+	_, err := os.Stat(s.path(key))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	return true, nil
+}
+
+// List 上传和创建链接时的临时文件不列出
+func (s *Local) List(prefix string, fn func(object ObjectInfo) error) error {
+	dir := s.path(prefix)
+	if !strings.HasSuffix(prefix, "/") {
+		dir = filepath.Dir(dir)
+	}
+
+	err := filepath.WalkDir(dir, func(filePath string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		} else if entry.IsDir() {
+			return nil
+		}
+
+		if strings.HasSuffix(filePath, ".uploading") || strings.HasSuffix(filePath, ".linking") {
+			return nil
+		}
+
+		key, err := filepath.Rel(s.root, filePath)
+		if err != nil {
+			return err
+		}
+		key = filepath.ToSlash(key)
+		if !strings.HasPrefix(key, prefix) {
+			return nil
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+
+		return fn(ObjectInfo{Key: key, Size: info.Size(), LastModified: info.ModTime()})
+	})
+	if os.IsNotExist(err) {
+		return nil
+	}
+
+	return err
+}
+
+func (s *Local) Copy(srcKey, dstKey string) error {
+	src, err := os.Open(s.path(srcKey))
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst := s.path(dstKey)
+	if err = os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return err
+	}
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(out, src)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+
+	return err
+}
+
+func (s *Local) Delete(key string) error {
+	err := os.Remove(s.path(key))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	return nil
+}
+
+func (s *Local) Url(key string) string {
+	u, err := url.Parse(s.baseUrl)
+	if err != nil {
+		return ""
+	}
+
+	return u.JoinPath(key).String()
+}
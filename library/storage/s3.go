@@ -0,0 +1,172 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+	"net/url"
+)
+
+// S3Config S3协议兼容的对象存储配置（MinIO、腾讯云COS、Cloudflare R2等）
+// PathStyle为true时使用 endpoint/bucket/key 形式访问，否则使用 bucket.endpoint/key；
+// PublicUrl为对外访问的地址前缀（如CDN域名），为空时按endpoint和访问形式拼出
+type S3Config struct {
+	Endpoint        string
+	Region          string
+	AccessKeyId     string
+	SecretAccessKey string
+	Bucket          string
+	UseSSL          bool
+	PathStyle       bool
+	PublicUrl       string
+}
+
+// _linkTargetMetaKey 固定链接对象记录目标对象的自定义元数据
+const _linkTargetMetaKey = "Link-Target"
+
+type S3 struct {
+	client    *minio.Client
+	bucket    string
+	publicUrl string
+}
+
+func NewS3(c *S3Config) (*S3, error) {
+	lookup := minio.BucketLookupDNS
+	if c.PathStyle {
+		lookup = minio.BucketLookupPath
+	}
+
+	client, err := minio.New(c.Endpoint, &minio.Options{
+		Creds:        credentials.NewStaticV4(c.AccessKeyId, c.SecretAccessKey, ""),
+		Secure:       c.UseSSL,
+		Region:       c.Region,
+		BucketLookup: lookup,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("s3客户端初始化失败：%s", err.Error())
+	}
+
+	publicUrl := c.PublicUrl
+	if publicUrl == "" {
+		scheme := "http"
+		if c.UseSSL {
+			scheme = "https"
+		}
+
+		if c.PathStyle {
+			publicUrl = fmt.Sprintf("%s://%s/%s", scheme, c.Endpoint, c.Bucket)
+		} else {
+			publicUrl = fmt.Sprintf("%s://%s.%s", scheme, c.Bucket, c.Endpoint)
+		}
+	}
+
+	return &S3{client: client, bucket: c.Bucket, publicUrl: publicUrl}, nil
+}
+
+func (s *S3) PutFile(key, localFile, contentType string) error {
+	_, err := s.client.FPutObject(context.Background(), s.bucket, key, localFile, minio.PutObjectOptions{
+		ContentType: contentType,
+	})
+
+	return err
+}
+
+func (s *S3) GetFile(key, localFile string) error {
+	return s.client.FGetObject(context.Background(), s.bucket, key, localFile, minio.GetObjectOptions{})
+}
+
+// Hide S3协议下各家对对象ACL的支持不一（如R2不支持），这里直接删除对象
+func (s *S3) Hide(key string) error {
+	return s.client.RemoveObject(context.Background(), s.bucket, key, minio.RemoveObjectOptions{})
+}
+
+// Link S3协议没有软链接，通过服务端复制将目标对象复制到linkKey，并在元数据中记录目标对象
+func (s *S3) Link(linkKey, targetKey string) error {
+	ctx := context.Background()
+	target, err := s.client.StatObject(ctx, s.bucket, targetKey, minio.StatObjectOptions{})
+	if err != nil {
+		return err
+	}
+
+	_, err = s.client.CopyObject(ctx,
+		minio.CopyDestOptions{
+			Bucket:          s.bucket,
+			Object:          linkKey,
+			ReplaceMetadata: true,
+			UserMetadata: map[string]string{
+				"Content-Type":     target.ContentType,
+				_linkTargetMetaKey: targetKey,
+			},
+		},
+		minio.CopySrcOptions{Bucket: s.bucket, Object: targetKey},
+	)
+
+	return err
+}
+
+func (s *S3) LinkTarget(linkKey string) (string, error) {
+	info, err := s.client.StatObject(context.Background(), s.bucket, linkKey, minio.StatObjectOptions{})
+	if err != nil {
+		if minio.ToErrorResponse(err).Code == "NoSuchKey" {
+			return "", nil
+		}
+		return "", err
+	}
+
+	return info.UserMetadata[_linkTargetMetaKey], nil
+}
+
+func (s *S3) Exists(key string) (bool, error) {
+	_, err := s.client.StatObject(context.Background(), s.bucket, key, minio.StatObjectOptions{})
+	if err != nil {
+		if minio.ToErrorResponse(err).Code == "NoSuchKey" {
+			return false, nil
+		}
+		return false, err
+	}
+
+	return true, nil
+}
+
+func (s *S3) List(prefix string, fn func(object ObjectInfo) error) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	objects := s.client.ListObjects(ctx, s.bucket, minio.ListObjectsOptions{Prefix: prefix, Recursive: true})
+	for object := range objects {
+		if object.Err != nil {
+			return object.Err
+		}
+
+		err := fn(ObjectInfo{Key: object.Key, Size: object.Size, LastModified: object.LastModified})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Copy 同Hide，S3协议下不设置ACL，归档的对象是否可访问取决于bucket的策略
+func (s *S3) Copy(srcKey, dstKey string) error {
+	_, err := s.client.CopyObject(context.Background(),
+		minio.CopyDestOptions{Bucket: s.bucket, Object: dstKey},
+		minio.CopySrcOptions{Bucket: s.bucket, Object: srcKey},
+	)
+
+	return err
+}
+
+func (s *S3) Delete(key string) error {
+	return s.client.RemoveObject(context.Background(), s.bucket, key, minio.RemoveObjectOptions{})
+}
+
+func (s *S3) Url(key string) string {
+	u, err := url.Parse(s.publicUrl)
+	if err != nil {
+		return ""
+	}
+
+	return u.JoinPath(key).String()
+}
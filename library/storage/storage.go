@@ -0,0 +1,78 @@
+package storage
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	TypeAliyunOss = "aliyun-oss"
+	TypeLocal     = "local"
+	TypeS3        = "s3"
+)
+
+// Storage 对象存储的抽象，key均为相对于存储根的对象路径，如 resource/update-files/xxx/app.apk
+type Storage interface {
+	// PutFile 将本地文件上传到key，contentType为空时由实现自行决定
+	PutFile(key, localFile, contentType string) error
+	// GetFile 将key下载到本地文件localFile，本地文件已存在时覆盖
+	GetFile(key, localFile string) error
+	// Hide 将对象设置为不可公开访问（原来的“删除”语义，阿里云oss下仅设置为私有）
+	Hide(key string) error
+	// Link 创建或覆盖一个指向targetKey的固定链接对象linkKey，用于“最新版本”之类的固定下载地址
+	Link(linkKey, targetKey string) error
+	// LinkTarget 获取固定链接对象当前指向的targetKey，linkKey不存在时返回空字符串
+	LinkTarget(linkKey string) (string, error)
+	// Exists 判断对象是否存在
+	Exists(key string) (bool, error)
+	// List 列举prefix下的所有对象，每个对象调用一次fn，fn返回错误时停止列举并返回该错误
+	List(prefix string, fn func(object ObjectInfo) error) error
+	// Copy 在存储内将srcKey复制到dstKey，用于归档，目标对象不可公开访问（存储支持访问控制时）
+	Copy(srcKey, dstKey string) error
+	// Delete 彻底删除对象，对象不存在时不返回错误
+	Delete(key string) error
+	// Url 获取对象的公开访问地址
+	Url(key string) string
+}
+
+// ObjectInfo 列举对象时得到的对象信息
+type ObjectInfo struct {
+	Key          string
+	Size         int64
+	LastModified time.Time
+}
+
+// Config 文件存储配置，Type为空时，若配置了阿里云oss的AccessKeyId则使用阿里云oss，否则使用本地存储
+type Config struct {
+	Type string
+
+	AliyunOssOption    AliyunOssConfig
+	S3Option           S3Config
+	LocalStorageOption LocalConfig
+}
+
+// ActualType 获取实际使用的存储类型
+func (c *Config) ActualType() string {
+	if c.Type != "" {
+		return c.Type
+	}
+
+	if c.AliyunOssOption.AccessKeyId != "" {
+		return TypeAliyunOss
+	}
+
+	return TypeLocal
+}
+
+func New(c *Config) (Storage, error) {
+	switch c.ActualType() {
+	case TypeAliyunOss:
+		return NewAliyunOss(&c.AliyunOssOption)
+	case TypeS3:
+		return NewS3(&c.S3Option)
+	case TypeLocal:
+		return NewLocal(&c.LocalStorageOption)
+	default:
+		return nil, fmt.Errorf("不支持的文件存储类型：%s", c.Type)
+	}
+}
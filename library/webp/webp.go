@@ -0,0 +1,19 @@
+//go:build cgo
+
+// Package webp WebP编码，依赖cgo（libwebp），没有cgo时Supported为false，Encode总是返回错误，
+// 这时服务启动会失败，除非配置中关闭了轮播图的webp
+package webp
+
+import (
+	"github.com/chai2010/webp"
+	"image"
+	"io"
+)
+
+// Supported 当前构建是否支持WebP编码
+const Supported = true
+
+// Encode 以有损压缩编码图片，quality为0~100
+func Encode(w io.Writer, img image.Image, quality float32) error {
+	return webp.Encode(w, img, &webp.Options{Quality: quality})
+}
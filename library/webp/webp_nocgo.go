@@ -0,0 +1,15 @@
+//go:build !cgo
+
+package webp
+
+import (
+	"errors"
+	"image"
+	"io"
+)
+
+const Supported = false
+
+func Encode(io.Writer, image.Image, float32) error {
+	return errors.New("没有cgo，不支持WebP编码")
+}
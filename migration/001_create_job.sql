@@ -0,0 +1,17 @@
+-- 后台任务表，见 app/model/job.go
+CREATE TABLE IF NOT EXISTS `job`
+(
+    `id`            BIGINT        NOT NULL,
+    `type`          VARCHAR(64)   NOT NULL,
+    `payload`       TEXT          NOT NULL,
+    `attempts`      INT           NOT NULL DEFAULT 0,
+    `max_attempts`  INT           NOT NULL DEFAULT 8,
+    `last_error`    VARCHAR(1024) NOT NULL DEFAULT '',
+    `next_run_time` DATETIME      NOT NULL,
+    `create_time`   DATETIME      NOT NULL,
+    `update_time`   DATETIME      NOT NULL,
+    `status`        TINYINT       NOT NULL DEFAULT 0 COMMENT '0待执行 2执行中 3成功 4失败',
+    PRIMARY KEY (`id`),
+    KEY `idx_status_next_run_time` (`status`, `next_run_time`)
+) ENGINE = InnoDB
+  DEFAULT CHARSET = utf8mb4;
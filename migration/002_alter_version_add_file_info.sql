@@ -0,0 +1,7 @@
+-- 版本文件信息，见 app/model/version.go
+ALTER TABLE `version`
+    ADD COLUMN `package_name` VARCHAR(255) NULL DEFAULT NULL AFTER `platform`,
+    ADD COLUMN `version_code` INT          NULL DEFAULT NULL AFTER `package_name`,
+    ADD COLUMN `min_sdk`      INT          NULL DEFAULT NULL AFTER `version_code`,
+    ADD COLUMN `file_size`    BIGINT       NULL DEFAULT NULL AFTER `min_sdk`,
+    ADD COLUMN `file_sha256`  CHAR(64)     NULL DEFAULT NULL AFTER `file_size`;
@@ -0,0 +1,16 @@
+-- 语义化版本号，见 app/model/version.go
+ALTER TABLE `version`
+    ADD COLUMN `version_major` BIGINT UNSIGNED NULL DEFAULT NULL AFTER `version_text`,
+    ADD COLUMN `version_minor` BIGINT UNSIGNED NULL DEFAULT NULL AFTER `version_major`,
+    ADD COLUMN `version_patch` BIGINT UNSIGNED NULL DEFAULT NULL AFTER `version_minor`,
+    ADD COLUMN `version_pre`   VARCHAR(64)     NULL DEFAULT NULL AFTER `version_patch`;
+
+-- 回填已有的数据，不符合语义化版本号格式的保持为NULL，排序时视为最低版本
+UPDATE `version`
+SET `version_major` = CAST(SUBSTRING_INDEX(`version_text`, '.', 1) AS UNSIGNED),
+    `version_minor` = CAST(SUBSTRING_INDEX(SUBSTRING_INDEX(`version_text`, '.', 2), '.', -1) AS UNSIGNED),
+    `version_patch` = CAST(SUBSTRING_INDEX(SUBSTRING_INDEX(SUBSTRING_INDEX(`version_text`, '+', 1), '-', 1), '.', -1) AS UNSIGNED),
+    `version_pre`   = IF(LOCATE('-', SUBSTRING_INDEX(`version_text`, '+', 1)) > 0,
+                         SUBSTRING(SUBSTRING_INDEX(`version_text`, '+', 1), LOCATE('-', `version_text`) + 1),
+                         '')
+WHERE `version_text` REGEXP '^(0|[1-9][0-9]*)\\.(0|[1-9][0-9]*)\\.(0|[1-9][0-9]*)(-[0-9A-Za-z.-]+)?(\\+[0-9A-Za-z.-]+)?$';
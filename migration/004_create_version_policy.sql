@@ -0,0 +1,15 @@
+-- 强制更新，见 app/model/version.go、app/model/version_policy.go
+ALTER TABLE `version`
+    ADD COLUMN `mandatory` TINYINT(1) NOT NULL DEFAULT 0 AFTER `file_sha256`;
+
+CREATE TABLE IF NOT EXISTS `version_policy`
+(
+    `id`          BIGINT      NOT NULL,
+    `platform`    VARCHAR(32) NOT NULL,
+    `min_version` VARCHAR(64) NOT NULL DEFAULT '',
+    `create_time` DATETIME    NOT NULL,
+    `update_time` DATETIME    NOT NULL,
+    PRIMARY KEY (`id`),
+    UNIQUE KEY `uk_platform` (`platform`)
+) ENGINE = InnoDB
+  DEFAULT CHARSET = utf8mb4;
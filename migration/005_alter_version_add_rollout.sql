@@ -0,0 +1,4 @@
+-- 灰度发布，见 app/model/version.go，灰度发布中的版本status为3
+ALTER TABLE `version`
+    ADD COLUMN `rollout_percent` TINYINT    NOT NULL DEFAULT 0 AFTER `mandatory`,
+    ADD COLUMN `rollout_paused`  TINYINT(1) NOT NULL DEFAULT 0 AFTER `rollout_percent`;
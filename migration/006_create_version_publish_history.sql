@@ -0,0 +1,14 @@
+-- 版本发布记录，用于回滚，见 app/model/version_publish_history.go
+CREATE TABLE IF NOT EXISTS `version_publish_history`
+(
+    `id`                  BIGINT      NOT NULL,
+    `platform`            VARCHAR(32) NOT NULL,
+    `version_id`          BIGINT      NOT NULL,
+    `previous_version_id` BIGINT      NOT NULL DEFAULT 0,
+    `action`              VARCHAR(16) NOT NULL COMMENT 'publish或rollback',
+    `create_time`         DATETIME    NOT NULL,
+    PRIMARY KEY (`id`),
+    KEY `idx_platform` (`platform`),
+    KEY `idx_version_id` (`version_id`)
+) ENGINE = InnoDB
+  DEFAULT CHARSET = utf8mb4;
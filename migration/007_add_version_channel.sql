@@ -0,0 +1,20 @@
+-- 发布渠道和测试人员，见 app/model/version.go、app/model/version_tester.go
+ALTER TABLE `version`
+    ADD COLUMN `channel` VARCHAR(16) NOT NULL DEFAULT 'stable' AFTER `platform`,
+    ADD KEY `idx_platform_channel` (`platform`, `channel`);
+
+ALTER TABLE `version_publish_history`
+    ADD COLUMN `channel` VARCHAR(16) NOT NULL DEFAULT 'stable' AFTER `platform`;
+
+CREATE TABLE IF NOT EXISTS `version_tester`
+(
+    `id`          BIGINT       NOT NULL,
+    `identifier`  VARCHAR(64)  NOT NULL COMMENT '学号或设备id',
+    `channel`     VARCHAR(16)  NOT NULL COMMENT 'beta或internal',
+    `remark`      VARCHAR(255) NOT NULL DEFAULT '',
+    `create_time` DATETIME     NOT NULL,
+    `status`      TINYINT      NOT NULL DEFAULT 0,
+    PRIMARY KEY (`id`),
+    KEY `idx_identifier` (`identifier`)
+) ENGINE = InnoDB
+  DEFAULT CHARSET = utf8mb4;
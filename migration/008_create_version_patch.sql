@@ -0,0 +1,18 @@
+-- 版本增量包，见 app/model/version_patch.go
+CREATE TABLE IF NOT EXISTS `version_patch`
+(
+    `id`                BIGINT       NOT NULL,
+    `version_id`        BIGINT       NOT NULL,
+    `base_version_id`   BIGINT       NOT NULL,
+    `base_version_text` VARCHAR(64)  NOT NULL,
+    `base_sha256`       CHAR(64)     NOT NULL,
+    `target_sha256`     CHAR(64)     NOT NULL,
+    `file`              VARCHAR(255) NOT NULL,
+    `file_size`         BIGINT       NOT NULL,
+    `file_sha256`       CHAR(64)     NOT NULL,
+    `create_time`       DATETIME     NOT NULL,
+    `status`            TINYINT      NOT NULL DEFAULT 0,
+    PRIMARY KEY (`id`),
+    KEY `idx_version_id` (`version_id`)
+) ENGINE = InnoDB
+  DEFAULT CHARSET = utf8mb4;
@@ -0,0 +1,14 @@
+-- 版本下载统计，见 app/model/download_stat.go
+CREATE TABLE IF NOT EXISTS `download_stat`
+(
+    `id`          BIGINT      NOT NULL,
+    `version_id`  BIGINT      NOT NULL,
+    `platform`    VARCHAR(32) NOT NULL,
+    `date`        DATE        NOT NULL,
+    `count`       BIGINT      NOT NULL DEFAULT 0,
+    `update_time` DATETIME    NOT NULL,
+    PRIMARY KEY (`id`),
+    UNIQUE KEY `uk_version_platform_date` (`version_id`, `platform`, `date`),
+    KEY `idx_date` (`date`)
+) ENGINE = InnoDB
+  DEFAULT CHARSET = utf8mb4;
@@ -0,0 +1,17 @@
+-- 版本的多个下载文件，见 app/model/version_artifact.go
+CREATE TABLE IF NOT EXISTS `version_artifact`
+(
+    `id`          BIGINT        NOT NULL,
+    `version_id`  BIGINT        NOT NULL,
+    `type`        VARCHAR(32)   NOT NULL,
+    `file`        VARCHAR(255)  NOT NULL DEFAULT '',
+    `url`         VARCHAR(1024) NOT NULL DEFAULT '',
+    `file_size`   BIGINT        NOT NULL DEFAULT 0,
+    `file_sha256` CHAR(64)      NOT NULL DEFAULT '',
+    `mirrors`     TEXT          NULL,
+    `create_time` DATETIME      NOT NULL,
+    `status`      TINYINT       NOT NULL DEFAULT 0,
+    PRIMARY KEY (`id`),
+    KEY `idx_version_id` (`version_id`)
+) ENGINE = InnoDB
+  DEFAULT CHARSET = utf8mb4;
@@ -0,0 +1,21 @@
+-- 热更新资源包，见 app/model/bundle.go
+CREATE TABLE IF NOT EXISTS `bundle`
+(
+    `id`                 BIGINT        NOT NULL,
+    `name`               VARCHAR(64)   NOT NULL,
+    `version_text`       VARCHAR(64)   NOT NULL,
+    `platform`           VARCHAR(32)   NOT NULL,
+    `min_native_version` VARCHAR(64)   NOT NULL DEFAULT '',
+    `max_native_version` VARCHAR(64)   NOT NULL DEFAULT '',
+    `summary`            VARCHAR(1024) NOT NULL DEFAULT '',
+    `manifest`           MEDIUMTEXT    NOT NULL,
+    `file_count`         INT           NOT NULL DEFAULT 0,
+    `total_size`         BIGINT        NOT NULL DEFAULT 0,
+    `ready`              TINYINT(1)    NOT NULL DEFAULT 0,
+    `create_time`        DATETIME      NOT NULL,
+    `update_time`        DATETIME      NOT NULL,
+    `status`             TINYINT       NOT NULL DEFAULT 0,
+    PRIMARY KEY (`id`),
+    KEY `idx_name_platform` (`name`, `platform`)
+) ENGINE = InnoDB
+  DEFAULT CHARSET = utf8mb4;
@@ -0,0 +1,15 @@
+-- 存储中没有被引用的对象，见 app/model/storage_orphan.go
+CREATE TABLE IF NOT EXISTS `storage_orphan`
+(
+    `id`              BIGINT       NOT NULL,
+    `object_key`      VARCHAR(512) NOT NULL,
+    `size`            BIGINT       NOT NULL DEFAULT 0,
+    `last_modified`   DATETIME     NOT NULL,
+    `first_seen_time` DATETIME     NOT NULL,
+    `update_time`     DATETIME     NOT NULL,
+    `status`          TINYINT      NOT NULL DEFAULT 0,
+    PRIMARY KEY (`id`),
+    KEY `idx_object_key` (`object_key`(191)),
+    KEY `idx_status` (`status`)
+) ENGINE = InnoDB
+  DEFAULT CHARSET = utf8mb4;
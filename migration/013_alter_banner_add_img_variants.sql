@@ -0,0 +1,3 @@
+-- 轮播图图片生成的各宽度和格式版本，见 app/model/banner.go
+ALTER TABLE `banner`
+    ADD COLUMN `img_variants` VARCHAR(1024) NULL DEFAULT NULL AFTER `img`;
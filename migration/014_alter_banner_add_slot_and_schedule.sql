@@ -0,0 +1,8 @@
+-- 轮播图展示位、排序权重和展示时间段，见 app/model/banner.go
+-- 已有的轮播图都放在首页轮播位
+ALTER TABLE `banner`
+    ADD COLUMN `slot`        VARCHAR(32) NOT NULL DEFAULT 'home' AFTER `platform`,
+    ADD COLUMN `sort_weight` INT         NOT NULL DEFAULT 0 AFTER `slot`,
+    ADD COLUMN `start_at`    DATETIME    NULL     DEFAULT NULL AFTER `sort_weight`,
+    ADD COLUMN `end_at`      DATETIME    NULL     DEFAULT NULL AFTER `start_at`,
+    ADD INDEX `idx_platform_slot` (`platform`, `slot`, `status`);
@@ -0,0 +1,15 @@
+-- 轮播图展示和点击统计，见 app/model/banner_stat.go
+CREATE TABLE IF NOT EXISTS `banner_stat`
+(
+    `id`          BIGINT      NOT NULL,
+    `banner_id`   BIGINT      NOT NULL,
+    `platform`    VARCHAR(32) NOT NULL,
+    `date`        DATE        NOT NULL,
+    `impressions` BIGINT      NOT NULL DEFAULT 0,
+    `clicks`      BIGINT      NOT NULL DEFAULT 0,
+    `update_time` DATETIME    NOT NULL,
+    PRIMARY KEY (`id`),
+    UNIQUE KEY `uk_banner_platform_date` (`banner_id`, `platform`, `date`),
+    KEY `idx_date` (`date`)
+) ENGINE = InnoDB
+  DEFAULT CHARSET = utf8mb4;
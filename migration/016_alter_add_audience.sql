@@ -0,0 +1,6 @@
+-- 公告和轮播图的投放规则，见 app/model/audience.go
+ALTER TABLE `announcement`
+    ADD COLUMN `audience` VARCHAR(2048) NULL DEFAULT NULL AFTER `platform`;
+
+ALTER TABLE `banner`
+    ADD COLUMN `audience` VARCHAR(2048) NULL DEFAULT NULL AFTER `end_at`;
@@ -0,0 +1,10 @@
+-- 轮播图链接类型，见 app/model/banner.go
+ALTER TABLE `banner`
+    ADD COLUMN `link_type` VARCHAR(16) NOT NULL DEFAULT '' AFTER `link`;
+
+-- 旧数据只能确定http链接和空链接的类型，其余的保持为空
+UPDATE `banner`
+SET `link_type` = CASE
+                      WHEN `link` IS NULL OR `link` = '' THEN 'none'
+                      WHEN `link` LIKE 'http://%' OR `link` LIKE 'https://%' THEN 'url'
+                      ELSE '' END;
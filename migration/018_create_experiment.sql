@@ -0,0 +1,28 @@
+-- 轮播图和公告的A/B实验，见 app/model/experiment.go
+CREATE TABLE IF NOT EXISTS `experiment`
+(
+    `id`          BIGINT        NOT NULL,
+    `name`        VARCHAR(128)  NOT NULL,
+    `target_type` VARCHAR(16)   NOT NULL,
+    `variants`    VARCHAR(2048) NOT NULL,
+    `create_time` DATETIME      NOT NULL,
+    `update_time` DATETIME      NOT NULL,
+    `status`      TINYINT       NOT NULL DEFAULT 0,
+    PRIMARY KEY (`id`),
+    KEY `idx_target_type_status` (`target_type`, `status`)
+) ENGINE = InnoDB
+  DEFAULT CHARSET = utf8mb4;
+
+-- 实验各变体累计的曝光和点击次数
+CREATE TABLE IF NOT EXISTS `experiment_stat`
+(
+    `id`            BIGINT   NOT NULL,
+    `experiment_id` BIGINT   NOT NULL,
+    `target_id`     BIGINT   NOT NULL,
+    `exposures`     BIGINT   NOT NULL DEFAULT 0,
+    `clicks`        BIGINT   NOT NULL DEFAULT 0,
+    `update_time`   DATETIME NOT NULL,
+    PRIMARY KEY (`id`),
+    UNIQUE KEY `uk_experiment_target` (`experiment_id`, `target_id`)
+) ENGINE = InnoDB
+  DEFAULT CHARSET = utf8mb4;
@@ -0,0 +1,5 @@
+-- 版本下载文件的上传状态，见 app/model/version_artifact.go，已有的文件都已上传完成
+ALTER TABLE `version_artifact`
+    ADD COLUMN `ready` TINYINT(1) NOT NULL DEFAULT 0 AFTER `mirrors`;
+UPDATE `version_artifact`
+SET `ready` = 1;